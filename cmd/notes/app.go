@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
@@ -10,10 +11,16 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/config"
 	"github.com/subtlepseudonym/notes/dal"
-	"github.com/subtlepseudonym/notes/dal/cache"
+	"github.com/subtlepseudonym/notes/dal/crypt"
+	"github.com/subtlepseudonym/notes/dal/git"
+	"github.com/subtlepseudonym/notes/dal/remote"
+	"github.com/subtlepseudonym/notes/dal/search"
+	"github.com/subtlepseudonym/notes/server"
 
 	"github.com/Masterminds/semver"
 	"github.com/chzyer/readline"
@@ -31,20 +38,31 @@ var (
 )
 
 const (
-	defaultNotesDirectory  = ".notes"
-	defaultHistoryFilePath = ".nts_history"
-	defaultLogFilePath     = ".nts_log"
-	defaultCacheCapacity   = 16
+	defaultNotesDirectory   = ".notes"
+	defaultHistoryFilePath  = ".nts_history"
+	defaultLogFilePath      = ".nts_log"
+	defaultNotebookFilePath = ".nts_notebook"
+	defaultGCFilePath       = ".nts_gc"
+	defaultCacheCapacity    = "16"
+	defaultDALType          = "local"
+	defaultRemoteTimeout    = 10 * time.Second
+	defaultRemoteRetries    = 2
+	defaultGCInterval       = 24 * time.Hour
 )
 
 type App struct {
 	*cli.App
 
-	homeDir   string
+	storage   storage
 	setupOnce sync.Once
 
 	logger *zap.Logger
+	config *config.Config
 	data   dal.DAL
+	search search.Searcher
+	doctor dal.Doctor
+	repo   git.Repository
+	crypt  crypt.Encrypter
 	meta   *notes.Meta
 
 	inInteractive bool
@@ -76,6 +94,26 @@ func New() (*App, error) {
 	}
 
 	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "config",
+			Usage:  "Read flag defaults from `FILE`",
+			EnvVar: "NOTES_CONFIG",
+		},
+		cli.StringFlag{
+			Name:   "notes-dir",
+			Usage:  "Keep notes, the log, and the command history in `DIRECTORY`",
+			EnvVar: "NOTES_DIR",
+		},
+		cli.StringFlag{
+			Name:   "notebook",
+			Usage:  "Use `NOTEBOOK` rather than the current notebook, without changing the current notebook",
+			EnvVar: "NOTES_NOTEBOOK",
+		},
+		cli.DurationFlag{
+			Name:   "trash-retention",
+			Usage:  "permanently remove notes which have been soft deleted for longer than `DURATION`, such as 720h, at startup, at most once a day, and with gc. If zero, they're kept until the trash is emptied",
+			EnvVar: "NOTES_TRASH_RETENTION",
+		},
 		cli.BoolFlag{
 			Name:  "silent",
 			Usage: "Prevent all logging",
@@ -85,26 +123,86 @@ func New() (*App, error) {
 			Usage: "Set the logging level",
 			Value: int(zapcore.InfoLevel),
 		},
-		cli.UintFlag{
+		cli.StringFlag{
 			Name:  "cache-capacity",
-			Usage: "Cache capacity",
+			Usage: "Cache up to `CAPACITY` notes, or bytes of note bodies if given with a unit such as KiB or MB",
 			Value: defaultCacheCapacity,
 		},
 		cli.StringFlag{
 			Name:  "cache",
-			Usage: "Cache note state with `CACHE_TYPE`. Only useful with large note sets in interactive mode",
+			Usage: "Cache note state with `CACHE_TYPE` (lru, rr, lfu, 2q). Only useful with large note sets in interactive mode",
+		},
+		cli.DurationFlag{
+			Name:  "cache-ttl",
+			Usage: "Read cached notes again after `DURATION`, so that changes made by other processes are seen. If zero, cached notes don't expire",
+		},
+		cli.StringFlag{
+			Name:   "dal",
+			Usage:  "Store notes using `DAL_TYPE` (local, sqlite, remote)",
+			Value:  defaultDALType,
+			EnvVar: "NOTES_DAL",
+		},
+		cli.BoolFlag{
+			Name:   "git",
+			Usage:  "Commit every change to a git repository in the notes directory. Requires the local dal and a git binary",
+			EnvVar: "NOTES_GIT",
+		},
+		cli.BoolFlag{
+			Name:   "index-snapshots",
+			Usage:  "Keep a binary snapshot of each notebook's index, which loads faster than the JSON index. Only used by the local dal",
+			EnvVar: "NOTES_INDEX_SNAPSHOTS",
+		},
+		cli.StringFlag{
+			Name:   "remote-url",
+			Usage:  "`URL` of the notes server used by the remote dal",
+			EnvVar: "NOTES_REMOTE_URL",
+		},
+		cli.StringFlag{
+			Name:   "remote-token-file",
+			Usage:  "`FILE` containing the token used to authenticate with the notes server",
+			EnvVar: "NOTES_REMOTE_TOKEN_FILE",
+		},
+		cli.DurationFlag{
+			Name:  "remote-timeout",
+			Usage: "Time limit for each request to the notes server",
+			Value: defaultRemoteTimeout,
+		},
+		cli.IntFlag{
+			Name:  "remote-retries",
+			Usage: "Number of times to retry idempotent requests when the notes server is unreachable",
+			Value: defaultRemoteRetries,
 		},
 	}
 
-	app.Commands = []cli.Command{
+	app.Commands = app.withConfig([]cli.Command{
 		app.buildDebugCommand(),
 		app.buildNotebookCommand(),
 		app.buildListCommand(),
 		app.buildNewCommand(),
 		app.buildRemoveCommand(),
+		app.buildMoveCommand(),
+		app.buildCopyCommand(),
+		app.buildRestoreCommand(),
+		app.buildTrashCommand(),
 		app.buildEditCommand(),
+		app.buildShowCommand(),
 		app.buildInfoCommand(),
-	}
+		app.buildSearchCommand(),
+		app.buildTagCommand(),
+		app.buildHistoryCommand(),
+		app.buildDiffCommand(),
+		app.buildRevertCommand(),
+		app.buildExportCommand(),
+		app.buildImportCommand(),
+		app.buildDoctorCommand(),
+		app.buildServeCommand(),
+		app.buildGitCommand(),
+		app.buildMigrateCommand(),
+		app.buildGCCommand(),
+	})
+
+	// the config command edits the config, so its flags aren't read from it
+	app.Commands = append(app.Commands, app.buildConfigCommand())
 
 	app.CommandNotFound = func(ctx *cli.Context, cmd string) {
 		fmt.Fprintf(ctx.App.ErrWriter, "command %q not found", cmd)
@@ -122,50 +220,154 @@ func New() (*App, error) {
 }
 
 func (a *App) setup(ctx *cli.Context) error {
-	home, err := os.UserHomeDir()
+	var err error
+	a.storage, err = resolveStorage(ctx)
 	if err != nil {
-		return fmt.Errorf("get home directory: %w", err)
+		return err
 	}
-	a.homeDir = home
 
-	data, err := dal.NewLocal(defaultNotesDirectory, Version) // FIXME: option to use different dal
-	if err != nil {
-		return fmt.Errorf("initialize dal: %v", err)
+	// the log file may be kept in the notes directory, so both must exist
+	// before logging starts
+	for _, directory := range []string{a.storage.data, path.Dir(a.storage.log)} {
+		err = os.MkdirAll(directory, os.ModeDir|os.FileMode(0700))
+		if err != nil {
+			return fmt.Errorf("create notes directory: %w", err)
+		}
 	}
 
-	if ctx.Int("cache-capacity") == 0 {
-		return fmt.Errorf("cache capacity must be non-zero")
+	logger, err := a.initLogging(ctx)
+	if err != nil {
+		return fmt.Errorf("init logging: %v", err)
 	}
+	a.logger = logger
 
-	switch strings.ToLower(ctx.String("cache")) {
-	case "lru", "least-recently-used":
-		a.data = cache.NewNoteCache(data, cache.LRU, ctx.Int("capacity"))
-	case "rr", "random-replacement":
-		a.data = cache.NewNoteCache(data, cache.RR, ctx.Int("capacity"))
-	default:
-		a.data = data
+	err = a.openDAL(ctx)
+	if err != nil {
+		return err
 	}
 
-	logger, err := a.initLogging(ctx)
+	err = a.restoreNotebook(ctx)
 	if err != nil {
-		return fmt.Errorf("init logging: %v", err)
+		return err
 	}
-	a.logger = logger
 
-	meta, err := data.GetMeta()
+	meta, err := a.data.GetMeta()
 	if err != nil {
 		return fmt.Errorf("get meta: %v", err)
 	}
 	a.meta = meta
 
+	a.autoCollectGarbage(ctx)
+
 	return nil
 }
 
-func (a *App) before(ctx *cli.Context) error {
+// openDAL opens the DAL selected by the global flags and wraps it in the
+// decorators which are enabled: git, encryption, search, and the note cache.
+// Every DAL the app uses must be opened this way so that none are skipped
+func (a *App) openDAL(ctx *cli.Context) error {
 	var err error
-	a.setupOnce.Do(func() {
-		err = a.setup(ctx)
+	var data dal.DAL
+	switch strings.ToLower(ctx.GlobalString("dal")) {
+	case "local", "":
+		data, err = dal.NewLocalWithOptions(a.storage.data, Version, a.logger.Named("dal"), localOptions(ctx))
+	case "sqlite", "sqlite3":
+		data, err = dal.NewSQLite(a.storage.data, Version)
+	case "remote":
+		data, err = newRemoteDAL(ctx)
+	default:
+		return fmt.Errorf("unknown dal type %q", ctx.GlobalString("dal"))
+	}
+	if err != nil {
+		return fmt.Errorf("initialize dal: %v", err)
+	}
+
+	if doctor, ok := data.(dal.Doctor); ok {
+		a.doctor = doctor
+	}
+
+	if ctx.GlobalBool("git") {
+		switch strings.ToLower(ctx.GlobalString("dal")) {
+		case "local", "":
+		default:
+			return fmt.Errorf("the git flag requires the local dal")
+		}
+
+		a.repo, err = git.New(data, a.storage.data, a.logger.Named("git"))
+		if err != nil {
+			return fmt.Errorf("initialize git repository: %w", err)
+		}
+		data = a.repo
+	}
+
+	// notes from a remote dal are encrypted by the server's own dal, if at all
+	if strings.ToLower(ctx.GlobalString("dal")) != "remote" {
+		a.crypt = crypt.New(data, a.promptPassphrase)
+		data = a.crypt
+	}
+
+	a.search = search.NewSearcher(data, searchDirectory(ctx, a.storage.data))
+	data = a.search
+
+	a.data, err = withCache(ctx, data)
+	return err
+}
+
+// localOptions gets the local DAL's options from the global flags
+func localOptions(ctx *cli.Context) dal.LocalOptions {
+	return dal.LocalOptions{
+		IndexSnapshots: ctx.GlobalBool("index-snapshots"),
+	}
+}
+
+// newRemoteDAL creates a DAL backed by the notes server specified by the
+// remote flags
+func newRemoteDAL(ctx *cli.Context) (dal.DAL, error) {
+	if ctx.GlobalString("remote-url") == "" {
+		return nil, fmt.Errorf("remote-url flag required by the remote dal")
+	}
+	if ctx.GlobalString("remote-token-file") == "" {
+		return nil, fmt.Errorf("remote-token-file flag required by the remote dal")
+	}
+
+	tokens, err := server.LoadTokens(ctx.GlobalString("remote-token-file"))
+	if err != nil {
+		return nil, err
+	}
+
+	return remote.New(ctx.GlobalString("remote-url"), tokens[0], remote.Options{
+		Timeout: ctx.GlobalDuration("remote-timeout"),
+		Retries: ctx.GlobalInt("remote-retries"),
 	})
+}
+
+// searchDirectory gets the directory holding search indexes for the selected
+// dal. Notebooks in different stores may share names, so the indexes for
+// stores other than the local dal are kept in hidden subdirectories, which
+// can't collide with notebook names
+func searchDirectory(ctx *cli.Context, dataDir string) string {
+	directory := path.Join(dataDir, defaultSearchDirectory)
+
+	switch dalType := strings.ToLower(ctx.GlobalString("dal")); dalType {
+	case "local", "":
+		return directory
+	case "sqlite", "sqlite3":
+		return path.Join(directory, ".sqlite")
+	case "remote":
+		sum := sha256.Sum256([]byte(ctx.GlobalString("remote-url")))
+		return path.Join(directory, fmt.Sprintf(".remote-%x", sum[:4]))
+	default:
+		return path.Join(directory, "."+dalType)
+	}
+}
+
+func (a *App) before(ctx *cli.Context) error {
+	err := a.configure(ctx)
+	if err == nil {
+		a.setupOnce.Do(func() {
+			err = a.setup(ctx)
+		})
+	}
 	if err != nil {
 		ctx.App.Writer = ioutil.Discard // prevent help text and double err printing
 		return err
@@ -185,7 +387,7 @@ func (a *App) initLogging(ctx *cli.Context) (*zap.Logger, error) {
 	}
 
 	logLevel := ctx.GlobalInt("verbosity")
-	logFile, err := os.OpenFile(path.Join(a.homeDir, defaultNotesDirectory, defaultLogFilePath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logFile, err := os.OpenFile(a.storage.log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("open log file: %w", err)
 	}
@@ -252,8 +454,9 @@ func (a *App) interactiveMode(ctx *cli.Context) error {
 		return nil
 	}
 	a.inInteractive = true
+	defer a.logCacheStats()
 
-	historyFile, err := os.OpenFile(path.Join(a.homeDir, defaultNotesDirectory, defaultHistoryFilePath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	historyFile, err := os.OpenFile(a.storage.history, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return cli.NewExitError(fmt.Errorf("open history file: %w", err), 1)
 	}
@@ -263,7 +466,7 @@ func (a *App) interactiveMode(ctx *cli.Context) error {
 	}
 
 	config := &readline.Config{
-		Prompt:            ctx.App.Name + "> ",
+		Prompt:            fmt.Sprintf("%s (%s)> ", ctx.App.Name, a.data.GetNotebook()),
 		HistoryFile:       historyPath,
 		HistorySearchFold: true,
 		AutoComplete:      readline.NewPrefixCompleter(buildPrefixCompleter(ctx.App.Commands)),
@@ -277,6 +480,7 @@ func (a *App) interactiveMode(ctx *cli.Context) error {
 	}
 
 	for {
+		reader.SetPrompt(fmt.Sprintf("%s (%s)> ", ctx.App.Name, a.data.GetNotebook()))
 		line, err := reader.Readline()
 		if errors.Is(err, readline.ErrInterrupt) {
 			if len(line) == 0 {
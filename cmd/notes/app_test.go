@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+// newTestHome sets the home directory to a temporary directory, returning the
+// notes directory within it
+func newTestHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "NOTES_DIR", "NOTES_NOTEBOOK", "NOTES_CONFIG"} {
+		t.Setenv(env, "")
+	}
+	return home + "/notes"
+}
+
+// runNotes runs notes with the provided arguments against the notes directory,
+// returning what it wrote
+func runNotes(t *testing.T, notesDir string, args ...string) (string, error) {
+	t.Helper()
+
+	app, err := New()
+	if err != nil {
+		t.Fatalf("new app: %s", err)
+	}
+
+	var buf bytes.Buffer
+	app.Writer = &buf
+	app.ErrWriter = &buf
+
+	err = app.Run(append([]string{"notes", "--notes-dir", notesDir}, args...))
+	return buf.String(), err
+}
+
+// withStdin replaces stdin with a pipe holding input until the test finishes
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("create pipe: %s", err)
+	}
+	_, err = w.WriteString(input)
+	if err != nil {
+		t.Fatalf("write stdin: %s", err)
+	}
+	w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
+
+// openTestDAL opens the local DAL in the notes directory, set to the notebook
+func openTestDAL(t *testing.T, notesDir, notebook string) dal.DAL {
+	t.Helper()
+
+	data, err := dal.NewLocal(notesDir, "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+	err = data.SetNotebook(notebook)
+	if err != nil {
+		t.Fatalf("set notebook: %s", err)
+	}
+	return data
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/subtlepseudonym/notes/dal"
+	"github.com/subtlepseudonym/notes/dal/cache"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+// byteUnits are the suffixes by which the cache capacity is given in bytes
+var byteUnits = map[string]int{
+	"b":   1,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+}
+
+// withCache wraps the DAL in the cache selected by the cache flags, if any
+func withCache(ctx *cli.Context, data dal.DAL) (dal.DAL, error) {
+	var cacheType cache.CacheType
+	switch strings.ToLower(ctx.GlobalString("cache")) {
+	case "", "none":
+		return data, nil
+	case "lru", "least-recently-used":
+		cacheType = cache.LRU
+	case "rr", "random-replacement":
+		cacheType = cache.RR
+	case "lfu", "least-frequently-used":
+		cacheType = cache.LFU
+	case "2q", "two-queue":
+		cacheType = cache.TwoQueue
+	default:
+		return nil, fmt.Errorf("unknown cache type %q", ctx.GlobalString("cache"))
+	}
+
+	options, err := parseCacheCapacity(ctx.GlobalString("cache-capacity"))
+	if err != nil {
+		return nil, err
+	}
+	options.TTL = ctx.GlobalDuration("cache-ttl")
+
+	return cache.NewNoteCacheWithOptions(data, cacheType, options), nil
+}
+
+// parseCacheCapacity parses a capacity given as a number of notes, such as
+// 64, or as a total size of note bodies, such as 16MiB
+func parseCacheCapacity(capacity string) (cache.Options, error) {
+	capacity = strings.TrimSpace(capacity)
+	digits := strings.IndexFunc(capacity, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if digits < 0 {
+		digits = len(capacity)
+	}
+
+	n, err := strconv.Atoi(capacity[:digits])
+	if err != nil || n == 0 {
+		return cache.Options{}, fmt.Errorf("cache capacity must be a non-zero number of notes or bytes, such as 64 or 16MiB")
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(capacity[digits:]))
+	if unit == "" {
+		return cache.Options{Capacity: n}, nil
+	}
+
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return cache.Options{}, fmt.Errorf("unknown cache capacity unit %q", capacity[digits:])
+	}
+	return cache.Options{MaxBytes: n * multiplier}, nil
+}
+
+// cacheStats gets the stats of the note cache, if one is in use
+func (a *App) cacheStats() (cache.Stats, bool) {
+	c, ok := a.data.(cache.NoteCache)
+	if !ok {
+		return cache.Stats{}, false
+	}
+	return c.Stats(), true
+}
+
+// logCacheStats logs a summary of how effective the note cache has been
+func (a *App) logCacheStats() {
+	stats, ok := a.cacheStats()
+	if !ok {
+		return
+	}
+
+	a.logger.Info("cache stats",
+		zap.String("policy", stats.Policy),
+		zap.Int("hits", stats.Hits),
+		zap.Int("misses", stats.Misses),
+		zap.Float64("hitRate", stats.HitRate()),
+		zap.Int("evictions", stats.Evictions),
+		zap.Int("expirations", stats.Expirations),
+		zap.Int("invalidations", stats.Invalidations),
+		zap.Int("entries", stats.Entries),
+		zap.Int("bytes", stats.Bytes),
+	)
+}
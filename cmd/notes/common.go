@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strconv"
 	"time"
 
 	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/operations"
 
 	"github.com/urfave/cli"
 	"go.uber.org/zap"
@@ -19,6 +21,126 @@ const (
 	defaultUpdatePeriod = 5 * time.Minute
 )
 
+// useNotebookFlag switches to the notebook provided by the command's notebook
+// flag, if any. The returned function restores the previous notebook and should
+// be deferred by the caller
+func (a *App) useNotebookFlag(ctx *cli.Context, logger *zap.Logger) (func(), error) {
+	if ctx.String("notebook") == "" {
+		return func() {}, nil
+	}
+
+	notebook := a.data.GetNotebook()
+	restore := func() {
+		a.data.SetNotebook(notebook)
+
+		meta, err := a.data.GetMeta()
+		if err != nil {
+			logger.Error("get meta", zap.Error(err))
+			return
+		}
+		a.meta = meta
+	}
+
+	err := a.data.SetNotebook(ctx.String("notebook"))
+	if err != nil {
+		return nil, fmt.Errorf("set notebook: %w", err)
+	}
+
+	return restore, nil
+}
+
+// operationsContext creates the context for calling operations on the
+// current notebook, reading its meta into a.meta so that changes made by
+// other processes are seen
+func (a *App) operationsContext(logger *zap.Logger) (*operations.Context, error) {
+	err := a.reloadMeta()
+	if err != nil {
+		return nil, err
+	}
+
+	return &operations.Context{
+		Meta:   a.meta,
+		DAL:    a.data,
+		Logger: logger,
+	}, nil
+}
+
+// followForward resolves a noteID left behind by a note being moved to
+// another notebook, switching to the notebook the note was moved to. The
+// returned function restores the previous notebook and should be deferred by
+// the caller
+func (a *App) followForward(noteID int, logger *zap.Logger) (int, func(), error) {
+	meta, err := a.data.GetMeta()
+	if err != nil {
+		return 0, nil, fmt.Errorf("get meta: %w", err)
+	}
+	if _, ok := meta.Forwards[noteID]; !ok {
+		return noteID, func() {}, nil
+	}
+
+	opCtx := &operations.Context{
+		Meta:   meta,
+		DAL:    a.data,
+		Logger: logger,
+	}
+	notebook, newID, err := operations.ResolveForward(opCtx, noteID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("resolve moved note: %w", err)
+	}
+
+	previous := a.data.GetNotebook()
+	err = a.data.SetNotebook(notebook)
+	if err != nil {
+		return 0, nil, fmt.Errorf("set notebook: %w", err)
+	}
+	logger.Info(
+		"following moved note",
+		zap.Int("noteID", noteID),
+		zap.String("notebook", notebook),
+		zap.Int("newNoteID", newID),
+	)
+
+	return newID, func() { a.data.SetNotebook(previous) }, nil
+}
+
+// bodyOptions gets the sources from which a note body can be read without
+// handing control to the user's editor. Stdin is read if the file flag is
+// "-" or, if readPiped is set, if stdin isn't a terminal
+func bodyOptions(ctx *cli.Context, readPiped bool) operations.BodyOptions {
+	options := operations.BodyOptions{
+		Body:    ctx.String("body"),
+		HasBody: ctx.IsSet("body"),
+		File:    ctx.String("file"),
+	}
+
+	if options.File == "-" || (readPiped && stdinIsPiped()) {
+		options.Reader = os.Stdin
+	}
+	return options
+}
+
+// stdinIsPiped determines whether stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// parseNoteID parses a hexadecimal noteID argument
+func parseNoteID(arg string) (int, error) {
+	if arg == "" {
+		return 0, fmt.Errorf("usage: noteID argument required")
+	}
+
+	n, err := strconv.ParseInt(arg, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse noteID argument: %w", err)
+	}
+	return int(n), nil
+}
+
 // editNote is a helper function for turning control over to the user and getting
 // a new note body from them
 func (a *App) editNote(ctx *cli.Context, note *notes.Note, logger *zap.Logger) (string, error) {
@@ -76,7 +198,11 @@ func (a *App) watchAndUpdate(ctx *cli.Context, note *notes.Note, filename string
 				continue
 			}
 
-			note.Body = string(b)
+			note, err = setNoteBody(note, string(b), timestamp, ctx.Bool("no-history"))
+			if err != nil {
+				return fmt.Errorf("set note body: %w", err)
+			}
+
 			note, err = note.AppendEdit(timestamp)
 			if err != nil {
 				return fmt.Errorf("append edit to history: %w", err)
@@ -93,8 +219,16 @@ func (a *App) watchAndUpdate(ctx *cli.Context, note *notes.Note, filename string
 			)
 		}
 	}
+}
+
+// setNoteBody replaces the note's body, recording a new revision unless
+// noHistory is set
+func setNoteBody(note *notes.Note, body string, timestamp time.Time, noHistory bool) (*notes.Note, error) {
+	if noHistory {
+		return note.AmendBody(body)
+	}
 
-	return nil
+	return note.UpdateBody(body, timestamp), nil
 }
 
 // getNoteBodyFromUser drops the user into the provided editor command before
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/subtlepseudonym/notes/config"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	defaultConfigDirectory      = "notes"
+	defaultConfigFilename       = "config.yaml"
+	defaultNotesConfigFilename  = "config"
+	defaultNotebooksSection     = "notebooks"
+	defaultXDGConfigDirectory   = ".config"
+	defaultXDGConfigEnvironment = "XDG_CONFIG_HOME"
+)
+
+func (a *App) buildConfigCommand() cli.Command {
+	notebookFlag := cli.StringFlag{
+		Name:  "notebook",
+		Usage: "use the override section for `NOTEBOOK` rather than the top level defaults",
+	}
+
+	return cli.Command{
+		Name:  "config",
+		Usage: "access config file subcommands",
+		Description: "Read and edit the config file, whose values are used as the defaults of flags which aren't set on the command line or by environment variable. " +
+			"The file is the config flag if set, else $XDG_CONFIG_HOME/notes/config.yaml, else ~/.notes/config. " +
+			"Keys are flag names, such as editor, which set that flag for every command, or a command name and flag name, such as ls.num. " +
+			"Keys under notebooks.<notebook> override the others when that notebook is in use, but only for command flags",
+		Subcommands: []cli.Command{
+			{
+				Name:      "get",
+				Usage:     "print a config value",
+				ArgsUsage: "<key>",
+				Action:    a.configGetAction,
+				Flags:     []cli.Flag{notebookFlag},
+			},
+			{
+				Name:      "set",
+				Usage:     "set a config value",
+				ArgsUsage: "<key> <value>",
+				Action:    a.configSetAction,
+				Flags:     []cli.Flag{notebookFlag},
+			},
+			{
+				Name:      "unset",
+				Usage:     "remove a config value",
+				ArgsUsage: "<key>",
+				Action:    a.configUnsetAction,
+				Flags:     []cli.Flag{notebookFlag},
+			},
+			{
+				Name:    "list",
+				Aliases: []string{"ls"},
+				Usage:   "print every config value",
+				Action:  a.configListAction,
+			},
+			{
+				Name:   "path",
+				Usage:  "print the config file path",
+				Action: a.configPathAction,
+			},
+		},
+	}
+}
+
+// configPath gets the path of the config file. If no config file exists, the
+// path in the XDG config directory is used so that it's created there
+func configPath(ctx *cli.Context) (string, error) {
+	if ctx.GlobalString("config") != "" {
+		return ctx.GlobalString("config"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home directory: %w", err)
+	}
+
+	configHome := os.Getenv(defaultXDGConfigEnvironment)
+	if configHome == "" {
+		configHome = path.Join(home, defaultXDGConfigDirectory)
+	}
+
+	candidates := []string{
+		path.Join(configHome, defaultConfigDirectory, defaultConfigFilename),
+		path.Join(home, defaultNotesDirectory, defaultNotesConfigFilename),
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+
+	return candidates[0], nil
+}
+
+// configure loads the config file, if it hasn't been loaded yet, and sets the
+// global flags the user didn't set from it
+func (a *App) configure(ctx *cli.Context) error {
+	if a.config == nil {
+		filename, err := configPath(ctx)
+		if err != nil {
+			return err
+		}
+
+		a.config, err = config.Load(filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.applyConfig(ctx, ctx.App.Flags, func(name string) [][]string {
+		return [][]string{{name}}
+	})
+}
+
+// applyConfig sets each flag the user didn't set to the value at the first of
+// its config keys that holds one
+func (a *App) applyConfig(ctx *cli.Context, flags []cli.Flag, keys func(name string) [][]string) error {
+	for _, f := range flags {
+		name := flagName(f)
+		if name == "help" || name == "version" || ctx.IsSet(name) {
+			continue
+		}
+
+		for _, key := range keys(name) {
+			value, ok := a.config.Get(key)
+			if !ok {
+				continue
+			}
+
+			err := ctx.Set(name, value)
+			if err != nil {
+				return fmt.Errorf("config %s: %w", strings.Join(key, "."), err)
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+// withConfig wraps the actions of the provided commands, and their
+// subcommands, so that the command flags the user didn't set are set from
+// the config before the action is called
+func (a *App) withConfig(commands []cli.Command) []cli.Command {
+	for i := range commands {
+		commands[i].Subcommands = a.withConfig(commands[i].Subcommands)
+
+		action, ok := commands[i].Action.(func(*cli.Context) error)
+		if !ok {
+			continue
+		}
+
+		commands[i].Action = func(ctx *cli.Context) error {
+			err := a.applyCommandConfig(ctx)
+			if err != nil {
+				return err
+			}
+			return action(ctx)
+		}
+	}
+
+	return commands
+}
+
+// applyCommandConfig sets the command's flags from, in order of precedence,
+// the notebook's section for the command, the notebook's section, the
+// command's section, and the top level
+func (a *App) applyCommandConfig(ctx *cli.Context) error {
+	command := strings.Fields(ctx.Command.FullName())
+
+	notebook := ctx.String("notebook")
+	if notebook == "" {
+		notebook = a.data.GetNotebook()
+	}
+
+	return a.applyConfig(ctx, ctx.Command.Flags, func(name string) [][]string {
+		return [][]string{
+			append(append([]string{defaultNotebooksSection, notebook}, command...), name),
+			{defaultNotebooksSection, notebook, name},
+			append(append([]string{}, command...), name),
+			{name},
+		}
+	})
+}
+
+func flagName(f cli.Flag) string {
+	return strings.TrimSpace(strings.Split(f.GetName(), ",")[0])
+}
+
+// configKey gets the key path for the key argument, in the notebook's section
+// if the notebook flag is set
+func configKey(ctx *cli.Context) ([]string, error) {
+	if !ctx.Args().Present() {
+		return nil, fmt.Errorf("usage: key argument required")
+	}
+
+	key := config.SplitKey(ctx.Args().First())
+	if notebook := ctx.String("notebook"); notebook != "" {
+		key = append([]string{defaultNotebooksSection, notebook}, key...)
+	}
+	return key, nil
+}
+
+// findConfigFlag gets the flag which the key path sets, so that values can be
+// checked before they're saved
+func (a *App) findConfigFlag(key []string) (cli.Flag, error) {
+	perNotebook := len(key) > 2 && key[0] == defaultNotebooksSection
+	if perNotebook {
+		key = key[2:]
+	}
+	name, sections := key[len(key)-1], key[:len(key)-1]
+
+	if len(sections) == 0 {
+		var found cli.Flag
+		var search func(commands []cli.Command)
+		search = func(commands []cli.Command) {
+			for _, command := range commands {
+				for _, f := range command.Flags {
+					if found == nil && flagName(f) == name {
+						found = f
+					}
+				}
+				search(command.Subcommands)
+			}
+		}
+		search(a.Commands)
+
+		if !perNotebook {
+			for _, f := range a.Flags {
+				if flagName(f) == name {
+					found = f
+				}
+			}
+		}
+
+		if found == nil {
+			return nil, fmt.Errorf("no command has a %q flag", name)
+		}
+		return found, nil
+	}
+
+	commands := a.Commands
+	var command *cli.Command
+	for _, section := range sections {
+		command = nil
+		for i := range commands {
+			if commands[i].Name == section {
+				command = &commands[i]
+			}
+		}
+		if command == nil {
+			return nil, fmt.Errorf("unknown command %q", strings.Join(sections, " "))
+		}
+		commands = command.Subcommands
+	}
+
+	for _, f := range command.Flags {
+		if flagName(f) == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("command %q has no %q flag", strings.Join(sections, " "), name)
+}
+
+func (a *App) configGetAction(ctx *cli.Context) error {
+	key, err := configKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	value, ok := a.config.Get(key)
+	if !ok {
+		return fmt.Errorf("%s is not set", strings.Join(key, "."))
+	}
+
+	fmt.Fprintln(ctx.App.Writer, value)
+	return nil
+}
+
+func (a *App) configSetAction(ctx *cli.Context) error {
+	key, err := configKey(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ctx.Args()) < 2 {
+		return fmt.Errorf("usage: value argument required")
+	}
+	value := ctx.Args().Get(1)
+
+	f, err := a.findConfigFlag(key)
+	if err != nil {
+		return fmt.Errorf("invalid key %s: %w", strings.Join(key, "."), err)
+	}
+
+	// the value must be one the flag accepts
+	set := flag.NewFlagSet(flagName(f), flag.ContinueOnError)
+	f.Apply(set)
+	err = set.Set(flagName(f), value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", strings.Join(key, "."), err)
+	}
+
+	err = a.config.Set(key, value)
+	if err != nil {
+		return err
+	}
+	return a.config.Save()
+}
+
+func (a *App) configUnsetAction(ctx *cli.Context) error {
+	key, err := configKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !a.config.Unset(key) {
+		return fmt.Errorf("%s is not set", strings.Join(key, "."))
+	}
+	return a.config.Save()
+}
+
+func (a *App) configListAction(ctx *cli.Context) error {
+	for _, entry := range a.config.Entries() {
+		fmt.Fprintf(ctx.App.Writer, "%s=%s\n", strings.Join(entry.Key, "."), entry.Value)
+	}
+	return nil
+}
+
+func (a *App) configPathAction(ctx *cli.Context) error {
+	fmt.Fprintln(ctx.App.Writer, a.config.Path)
+	return nil
+}
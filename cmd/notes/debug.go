@@ -12,7 +12,6 @@ import (
 	"strconv"
 
 	"github.com/subtlepseudonym/notes"
-	"github.com/subtlepseudonym/notes/dal"
 	"github.com/urfave/cli"
 )
 
@@ -26,6 +25,7 @@ func (a *App) buildDebugCommand() cli.Command {
 			a.getMeta(),
 			a.getNoteMetas(),
 			a.rebuildIndex(),
+			a.cacheStatsCommand(),
 		},
 	}
 }
@@ -140,6 +140,33 @@ func (a *App) getNoteMetasAction(ctx *cli.Context) error {
 	return nil
 }
 
+func (a *App) cacheStatsCommand() cli.Command {
+	return cli.Command{
+		Name:        "cache-stats",
+		Usage:       "print note cache stats",
+		Description: "Print the note cache's stats as a json object",
+		Action:      a.cacheStatsAction,
+	}
+}
+
+func (a *App) cacheStatsAction(ctx *cli.Context) error {
+	stats, ok := a.cacheStats()
+	if !ok {
+		return fmt.Errorf("note cache is disabled")
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("marshal cache stats: %w", err)
+	}
+
+	_, err = ctx.App.Writer.Write(b)
+	if err != nil {
+		return fmt.Errorf("write to app writer: %w", err)
+	}
+	return nil
+}
+
 func (a *App) rebuildIndex() cli.Command {
 	return cli.Command{
 		Name:        "rebuild-index",
@@ -159,7 +186,7 @@ func (a *App) rebuildIndexAction(ctx *cli.Context) error {
 	notebook := a.data.GetNotebook()
 	a.logger = a.logger.Named(notebook).Named("rebuild-index")
 
-	notesDir := path.Join(a.homeDir, defaultNotesDirectory, notebook)
+	notesDir := path.Join(a.storage.data, notebook)
 	if _, err := os.Stat(notesDir); os.IsNotExist(err) {
 		return fmt.Errorf("local DAL not found: %w\nuse --force if you'd like to rebuild anyway", err)
 	}
@@ -201,7 +228,7 @@ func (a *App) rebuildIndexAction(ctx *cli.Context) error {
 
 	// FIXME: this will need to be updated if the default index filename is ever changed or
 	// 		  if the option to rename the file is ever provided
-	indexPath := path.Join(a.homeDir, defaultNotesDirectory, notebook, "index")
+	indexPath := path.Join(a.storage.data, notebook, "index")
 	backupIndex := !ctx.Bool("no-backup")
 	if backupIndex {
 		err := os.Rename(indexPath, indexPath+".rebuild.bak")
@@ -231,11 +258,16 @@ func (a *App) rebuildIndexAction(ctx *cli.Context) error {
 		return fmt.Errorf("close index file: %w", err)
 	}
 
-	local, err := dal.NewLocal(defaultNotesDirectory, a.meta.Version)
+	// reopen the DAL so that the rebuilt index is read, keeping every
+	// decorator in place
+	err = a.openDAL(ctx)
 	if err != nil {
-		return fmt.Errorf("new local dal: %w", err)
+		return fmt.Errorf("reopen dal: %w", err)
 	}
-	a.data = local
 
-	return nil
+	err = a.data.SetNotebook(notebook)
+	if err != nil {
+		return fmt.Errorf("set notebook: %w", err)
+	}
+	return a.reloadMeta()
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/subtlepseudonym/notes/dal"
+	"github.com/subtlepseudonym/notes/dal/cache"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+func (a *App) buildDoctorCommand() cli.Command {
+	return cli.Command{
+		Name:        "doctor",
+		Usage:       "check notebooks for inconsistencies",
+		Description: "Cross-check each notebook's meta, index, and note files, reporting notes missing from the index or from disk, index entries that don't match their note files, a latest ID lower than the highest note ID, and files that can't be decoded. With --repair, the index and meta are corrected to match the note files, and corrupt files are moved to the quarantine directory rather than deleted. The meta of a notebook with encrypted notes holds its encryption key, so it's never rebuilt",
+		Action:      a.doctorAction,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "repair",
+				Usage: "fix the problems found",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "print problems as a JSON array",
+			},
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "check only the specified notebook. If unspecified, will check all notebooks",
+			},
+		},
+	}
+}
+
+func (a *App) doctorAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	if a.doctor == nil {
+		return fmt.Errorf("the %s dal doesn't support checking for problems", ctx.GlobalString("dal"))
+	}
+
+	notebooks := []string{ctx.String("notebook")}
+	if ctx.String("notebook") == "" {
+		notebooks = a.data.GetAllNotebooks()
+		sort.Strings(notebooks)
+	}
+
+	repair := ctx.Bool("repair")
+	problems := []dal.Problem{}
+	for _, notebook := range notebooks {
+		found, err := a.doctor.Diagnose(notebook, repair)
+		problems = append(problems, found...)
+		if err != nil {
+			return fmt.Errorf("diagnose notebook %q: %w", notebook, err)
+		}
+
+		if repair && len(found) > 0 {
+			logger.Info("notebook repaired", zap.String("notebook", notebook), zap.Int("problems", len(found)))
+
+			err = a.search.Rebuild(notebook)
+			if err != nil {
+				return fmt.Errorf("rebuild search index for %q: %w", notebook, err)
+			}
+		}
+	}
+
+	if repair {
+		// repairs are made beneath the cache, so cached notes may be stale
+		if noteCache, ok := a.data.(cache.NoteCache); ok {
+			noteCache.Flush()
+		}
+
+		meta, err := a.data.GetMeta()
+		if err != nil {
+			return fmt.Errorf("get meta: %w", err)
+		}
+		a.meta = meta
+	}
+
+	if ctx.Bool("json") {
+		encoder := json.NewEncoder(ctx.App.Writer)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(problems)
+		if err != nil {
+			return fmt.Errorf("encode problems: %w", err)
+		}
+	} else {
+		printProblems(ctx, problems)
+	}
+
+	if !repair && len(problems) > 0 {
+		return fmt.Errorf("found %d problems, use --repair to fix them", len(problems))
+	}
+
+	var unrepaired int
+	for _, problem := range problems {
+		if !problem.Repaired {
+			unrepaired++
+		}
+	}
+	if unrepaired > 0 {
+		return fmt.Errorf("%d problems can't be repaired", unrepaired)
+	}
+	return nil
+}
+
+// printProblems writes a line describing each problem, followed by a summary
+func printProblems(ctx *cli.Context, problems []dal.Problem) {
+	var repaired int
+	for _, problem := range problems {
+		fields := []string{problem.Notebook, string(problem.Kind)}
+		if problem.File != "" {
+			fields = append(fields, problem.File)
+		}
+		fields = append(fields, problem.Detail)
+
+		line := strings.Join(fields, ": ")
+		if problem.Quarantined != "" {
+			line += fmt.Sprintf(" (moved to %s)", problem.Quarantined)
+		} else if problem.Repaired {
+			line += " (repaired)"
+		}
+		fmt.Fprintln(ctx.App.Writer, line)
+
+		if problem.Repaired {
+			repaired++
+		}
+	}
+
+	switch {
+	case len(problems) == 0:
+		fmt.Fprintln(ctx.App.Writer, "no problems found")
+	case repaired > 0:
+		fmt.Fprintf(ctx.App.Writer, "found %d problems, repaired %d\n", len(problems), repaired)
+	}
+}
@@ -3,10 +3,10 @@ package main
 import (
 	"fmt"
 	"strconv"
-	"time"
 
 	"github.com/subtlepseudonym/notes"
 	"github.com/subtlepseudonym/notes/dal"
+	"github.com/subtlepseudonym/notes/operations"
 
 	"github.com/urfave/cli"
 	"go.uber.org/zap"
@@ -21,7 +21,7 @@ func (a *App) buildEditCommand() cli.Command {
 		Name:        "edit",
 		Aliases:     []string{"e"},
 		Usage:       "edit an existing note",
-		Description: "Open a note for editing, as specified by the <noteID> argument. If no argument is provided, notes will open the most recently created note for editing",
+		Description: "Open a note for editing, as specified by the <noteID> argument. If no argument is provided, notes will open the most recently created note for editing. If --body or --file is provided, the new body is read from there instead of opening an editor. Stdin is only read with --file -. If only --title or --tag is provided, the body is left as it is",
 		ArgsUsage:   "[<noteID>]",
 		Action:      a.editAction,
 		Flags: []cli.Flag{
@@ -41,6 +41,22 @@ func (a *App) buildEditCommand() cli.Command {
 				Name:  "title, t",
 				Usage: "note title",
 			},
+			cli.StringFlag{
+				Name:  "body, b",
+				Usage: "use `BODY` as the note body rather than opening an editor",
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "read the note body from `FILE` rather than opening an editor. Use \"-\" for stdin",
+			},
+			cli.BoolFlag{
+				Name:  "append",
+				Usage: "append the body provided by --body or --file to the existing body",
+			},
+			cli.StringSliceFlag{
+				Name:  "tag",
+				Usage: "add `TAG` to the note. May be repeated",
+			},
 			cli.StringFlag{
 				Name:   "editor",
 				Usage:  "text editor command",
@@ -92,80 +108,72 @@ func getNoteID(meta *notes.Meta, data dal.DAL, arg string, searchDepth int) (int
 }
 
 func (a *App) editAction(ctx *cli.Context) error {
-	notebook := a.data.GetNotebook()
-	logger := a.logger.Named(notebook).Named(ctx.Command.Name)
-
-	if ctx.String("notebook") != "" {
-		defer func() {
-			a.data.SetNotebook(notebook)
-
-			meta, err := a.data.GetMeta()
-			if err != nil {
-				logger.Error("get meta", zap.Error(err))
-				return
-			}
-			a.meta = meta
-		}()
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
 
-		err := a.data.SetNotebook(ctx.String("notebook"))
-		if err != nil {
-			return fmt.Errorf("set notebook: %w", err)
-		}
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
 	}
+	defer restore()
 
-	meta, err := a.data.GetMeta()
+	err = a.reloadMeta()
 	if err != nil {
-		return fmt.Errorf("get meta: %v", err)
+		return err
 	}
-	a.meta = meta
 
 	noteID, err := getNoteID(a.meta, a.data, ctx.Args().First(), ctx.Int("latest-depth"))
 	if err != nil {
 		return fmt.Errorf("get note ID: %w", err)
 	}
 
-	note, err := a.data.GetNote(noteID)
+	noteID, restoreForward, err := a.followForward(noteID, logger)
 	if err != nil {
-		return fmt.Errorf("get note: %w", err)
-	}
-
-	var changed bool
-	if !note.Meta.Deleted.Time.Equal(time.Unix(0, 0)) {
-		note.Meta.Deleted.Time = time.Unix(0, 0) // restore soft deleted notes
-		changed = true
+		return err
 	}
+	defer restoreForward()
 
-	if ctx.String("title") != "" {
-		note.Meta.Title = ctx.String("title")
-		changed = true
+	tags, err := parseTags(ctx.StringSlice("tag"))
+	if err != nil {
+		return err
 	}
 
-	body, err := a.editNote(ctx, note, logger)
+	// stdin isn't read unless asked for, as edit is often run in loops
+	// reading from a pipe
+	body, ok, err := operations.ReadBody(bodyOptions(ctx, false))
 	if err != nil {
-		return fmt.Errorf("user handoff: %w", err)
+		return err
 	}
-
-	if note.Body != body {
-		note.Body = body
-		changed = true
+	if !ok && ctx.Bool("append") {
+		return fmt.Errorf("usage: --append requires --body or --file")
 	}
-
-	if !changed {
-		return nil
+	metaOnly := !ok && (ctx.IsSet("title") || len(tags) > 0)
+
+	options := operations.EditNoteOptions{
+		Title:     ctx.String("title"),
+		Body:      body,
+		Append:    ctx.Bool("append"),
+		Tags:      tags,
+		NoHistory: ctx.Bool("no-history"),
 	}
-
-	if !ctx.Bool("no-history") {
-		note, err = note.AppendEdit(time.Now())
-		if err != nil {
-			return fmt.Errorf("append edit to note history: %w", err)
+	if metaOnly {
+		// appending nothing leaves the body as it is
+		options.Append = true
+	} else if !ok {
+		options.Editor = func(note *notes.Note) (string, error) {
+			return a.editNote(ctx, note, logger)
 		}
 	}
 
-	err = a.data.SaveNote(note)
+	opCtx, err := a.operationsContext(logger)
+	if err != nil {
+		return err
+	}
+
+	_, err = operations.EditNote(opCtx, options, noteID)
 	if err != nil {
-		return fmt.Errorf("save note: %w", err)
+		return fmt.Errorf("edit note: %w", err)
 	}
-	logger.Info("note updated", zap.Int("noteID", note.Meta.ID), zap.String("notebook", a.data.GetNotebook()))
+	logger.Info("note updated", zap.Int("noteID", noteID), zap.String("notebook", a.data.GetNotebook()))
 
 	return nil
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestEditReadsStdinOnlyWhenAsked(t *testing.T) {
+	notesDir := newTestHome(t)
+
+	withStdin(t, "original\n")
+	_, err := runNotes(t, notesDir, "new", "--title", "todo")
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		args  []string
+		stdin string
+		body  string
+		title string
+	}{
+		{name: "tag", args: []string{"--tag", "x"}, stdin: "piped\n", body: "original\n", title: "todo"},
+		{name: "title", args: []string{"--title", "done"}, stdin: "piped\n", body: "original\n", title: "done"},
+		{name: "file", args: []string{"--file", "-"}, stdin: "replaced\n", body: "replaced\n", title: "done"},
+		{name: "append", args: []string{"--append", "--body", "more"}, stdin: "piped\n", body: "replaced\nmore", title: "done"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			withStdin(t, test.stdin)
+			_, err := runNotes(t, notesDir, append(append([]string{"edit"}, test.args...), "1")...)
+			if err != nil {
+				t.Fatalf("edit: %s", err)
+			}
+
+			note, err := openTestDAL(t, notesDir, "default").GetNote(1)
+			if err != nil {
+				t.Fatalf("get note: %s", err)
+			}
+			if note.Body != test.body || note.Meta.Title != test.title {
+				t.Errorf("note has title %q and body %q, expected %q and %q", note.Meta.Title, note.Body, test.title, test.body)
+			}
+		})
+	}
+}
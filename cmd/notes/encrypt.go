@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+func (a *App) encryptNotebook() cli.Command {
+	return cli.Command{
+		Name:        "encrypt",
+		Usage:       "encrypt a notebook's notes",
+		Description: "Encrypt the note bodies and revisions, and optionally the titles, of the current notebook with a key protected by a passphrase. The passphrase is asked for once per session when the notebook's notes are read or saved. Encrypted notebooks aren't searchable. If the conversion is interrupted, run encrypt again to finish it. Copies of notes made before encryption, such as in git history or exports, are not affected",
+		Action:      a.encryptNotebookAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+			},
+			cli.BoolFlag{
+				Name:  "titles",
+				Usage: "also encrypt note titles, which must then be decrypted to list notes",
+			},
+		},
+	}
+}
+
+func (a *App) decryptNotebook() cli.Command {
+	return cli.Command{
+		Name:        "decrypt",
+		Usage:       "decrypt a notebook's notes",
+		Description: "Decrypt every note in the current notebook and remove its passphrase. If the conversion is interrupted, run decrypt again to finish it",
+		Action:      a.decryptNotebookAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+			},
+		},
+	}
+}
+
+func (a *App) rekeyNotebook() cli.Command {
+	return cli.Command{
+		Name:        "rekey",
+		Usage:       "change an encrypted notebook's passphrase",
+		Description: "Change the passphrase protecting the current notebook's key. Notes don't need to be re-encrypted, so the change is made in a single write",
+		Action:      a.rekeyNotebookAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+			},
+		},
+	}
+}
+
+func (a *App) encryptNotebookAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	if a.crypt == nil {
+		return fmt.Errorf("the %s dal doesn't support encryption", ctx.GlobalString("dal"))
+	}
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	notebook := a.data.GetNotebook()
+	encrypted, err := a.crypt.IsEncrypted(notebook)
+	if err != nil {
+		return err
+	}
+
+	// an encrypted notebook is only left with unencrypted notes by an
+	// interrupted conversion, which is finished with the existing passphrase
+	var passphrase string
+	if encrypted {
+		passphrase, err = readPassphrase(fmt.Sprintf("passphrase for notebook %q: ", notebook))
+	} else {
+		passphrase, err = readNewPassphrase(notebook)
+	}
+	if err != nil {
+		return err
+	}
+
+	converted, err := a.crypt.Encrypt(passphrase, ctx.Bool("titles"))
+	if err != nil {
+		return fmt.Errorf("encrypt notebook: %w", err)
+	}
+
+	err = a.search.Rebuild(notebook)
+	if err != nil {
+		return fmt.Errorf("rebuild search index: %w", err)
+	}
+
+	logger.Info("notebook encrypted", zap.Int("converted", converted))
+	fmt.Fprintf(ctx.App.Writer, "encrypted %d notes\n", converted)
+	return a.reloadMeta()
+}
+
+func (a *App) decryptNotebookAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	if a.crypt == nil {
+		return fmt.Errorf("the %s dal doesn't support encryption", ctx.GlobalString("dal"))
+	}
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	converted, err := a.crypt.Decrypt()
+	if err != nil {
+		return fmt.Errorf("decrypt notebook: %w", err)
+	}
+
+	err = a.search.Rebuild(a.data.GetNotebook())
+	if err != nil {
+		return fmt.Errorf("rebuild search index: %w", err)
+	}
+
+	logger.Info("notebook decrypted", zap.Int("converted", converted))
+	fmt.Fprintf(ctx.App.Writer, "decrypted %d notes\n", converted)
+	return a.reloadMeta()
+}
+
+func (a *App) rekeyNotebookAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	if a.crypt == nil {
+		return fmt.Errorf("the %s dal doesn't support encryption", ctx.GlobalString("dal"))
+	}
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	notebook := a.data.GetNotebook()
+	encrypted, err := a.crypt.IsEncrypted(notebook)
+	if err != nil {
+		return err
+	}
+	if !encrypted {
+		return fmt.Errorf("notebook %q is not encrypted", notebook)
+	}
+
+	// the current passphrase is checked before asking for the new one
+	err = a.crypt.Unlock()
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readNewPassphrase(notebook)
+	if err != nil {
+		return err
+	}
+
+	err = a.crypt.Rekey(passphrase)
+	if err != nil {
+		return fmt.Errorf("rekey notebook: %w", err)
+	}
+
+	logger.Info("notebook rekeyed")
+	return a.reloadMeta()
+}
+
+// reloadMeta reads the current notebook's meta into a.meta
+func (a *App) reloadMeta() error {
+	meta, err := a.data.GetMeta()
+	if err != nil {
+		return fmt.Errorf("get meta: %w", err)
+	}
+	a.meta = meta
+	return nil
+}
+
+// promptPassphrase asks for a notebook's passphrase when the encryption
+// decorator first needs it
+func (a *App) promptPassphrase(notebook string) (string, error) {
+	return readPassphrase(fmt.Sprintf("passphrase for notebook %q: ", notebook))
+}
+
+// readNewPassphrase asks for a new passphrase twice, requiring that both
+// entries match
+func readNewPassphrase(notebook string) (string, error) {
+	passphrase, err := readPassphrase(fmt.Sprintf("new passphrase for notebook %q: ", notebook))
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase cannot be blank")
+	}
+
+	confirmation, err := readPassphrase("confirm passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != passphrase {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
+// readPassphrase prompts for a passphrase on the controlling terminal,
+// without echoing it, so that the passphrase can be read even when stdin is
+// used for a note body
+func readPassphrase(prompt string) (string, error) {
+	var fd int
+	var out io.Writer
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		fd, out = int(tty.Fd()), tty
+	} else if term.IsTerminal(int(os.Stdin.Fd())) {
+		fd, out = int(os.Stdin.Fd()), os.Stderr
+	} else {
+		return "", fmt.Errorf("passphrase required, but no terminal is available to read it from")
+	}
+
+	fmt.Fprint(out, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(out)
+	if err != nil {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/subtlepseudonym/notes/markdown"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultExportFormat = "markdown"
+)
+
+func (a *App) buildExportCommand() cli.Command {
+	return cli.Command{
+		Name:        "export",
+		Usage:       "export notes to files",
+		Description: "Write each note in the selected notebooks to <dir>, in a subdirectory named for its notebook. Markdown notes include their meta information as YAML front matter",
+		ArgsUsage:   "<dir>",
+		Action:      a.exportAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "export file `FORMAT` (markdown)",
+				Value: defaultExportFormat,
+			},
+			cli.BoolFlag{
+				Name:  "all-notebooks, A",
+				Usage: "export all notebooks",
+			},
+			cli.BoolFlag{
+				Name:  "include-deleted",
+				Usage: "export soft deleted notes",
+			},
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the current notebook",
+			},
+		},
+	}
+}
+
+func (a *App) exportAction(ctx *cli.Context) error {
+	if !ctx.Args().Present() {
+		return fmt.Errorf("usage: directory argument required")
+	}
+	directory := ctx.Args().First()
+
+	switch strings.ToLower(ctx.String("format")) {
+	case "markdown", "md":
+	default:
+		return fmt.Errorf("unsupported export format %q", ctx.String("format"))
+	}
+
+	current := a.data.GetNotebook()
+	logger := a.logger.Named(current).Named(ctx.Command.Name)
+
+	var notebooks []string
+	if ctx.Bool("all-notebooks") {
+		notebooks = a.data.GetAllNotebooks()
+		sort.Strings(notebooks)
+	} else if ctx.String("notebook") != "" {
+		notebooks = []string{ctx.String("notebook")}
+	} else {
+		notebooks = []string{current}
+	}
+
+	defer a.data.SetNotebook(current)
+	for _, notebook := range notebooks {
+		err := a.data.SetNotebook(notebook)
+		if err != nil {
+			return fmt.Errorf("set notebook: %w", err)
+		}
+
+		count, err := a.exportNotebook(filepath.Join(directory, notebook), ctx.Bool("include-deleted"))
+		if err != nil {
+			return fmt.Errorf("export notebook %q: %w", notebook, err)
+		}
+		logger.Info("exported notebook", zap.String("notebook", notebook), zap.Int("notes", count), zap.String("directory", directory))
+	}
+
+	return nil
+}
+
+// exportNotebook writes each note in the current notebook to the provided
+// directory as a markdown file, returning the number of notes written
+func (a *App) exportNotebook(directory string, includeDeleted bool) (int, error) {
+	index, err := a.data.GetAllNoteMetas()
+	if err != nil {
+		return 0, fmt.Errorf("get note metas: %w", err)
+	}
+
+	err = os.MkdirAll(directory, os.ModeDir|os.FileMode(0755))
+	if err != nil {
+		return 0, fmt.Errorf("create export directory: %w", err)
+	}
+
+	var count int
+	for id, meta := range index {
+		if !includeDeleted && meta.IsDeleted() {
+			continue
+		}
+
+		note, err := a.data.GetNote(id)
+		if err != nil {
+			return count, fmt.Errorf("get note %x: %w", id, err)
+		}
+
+		b, err := markdown.Marshal(note)
+		if err != nil {
+			return count, fmt.Errorf("encode note %x: %w", id, err)
+		}
+
+		err = os.WriteFile(filepath.Join(directory, markdown.Filename(note.Meta)), b, 0644)
+		if err != nil {
+			return count, fmt.Errorf("write note %x: %w", id, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	shortHashLength = 10
+)
+
+func (a *App) buildGitCommand() cli.Command {
+	return cli.Command{
+		Name:        "git",
+		Usage:       "access git storage subcommands",
+		Description: "Inspect the git repository which the notes directory is kept in when the --git flag is set",
+		Subcommands: []cli.Command{
+			{
+				Name:        "log",
+				Usage:       "list the commits that changed a note",
+				Description: "List the commits that changed the note specified by <noteID>, newest first. Notes which have since been removed are listed too",
+				ArgsUsage:   "<noteID>",
+				Action:      a.gitLogAction,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "notebook",
+						Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+					},
+					cli.StringFlag{
+						Name:  "time-format",
+						Usage: "format to display timestamps in",
+						Value: defaultListTimeFormat,
+					},
+					cli.StringFlag{
+						Name:  "delimiter",
+						Usage: "list column delimiter",
+						Value: defaultListColumnDelimiter,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (a *App) gitLogAction(ctx *cli.Context) error {
+	if a.repo == nil {
+		return fmt.Errorf("git storage isn't enabled, use the --git flag")
+	}
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	notebook := ctx.String("notebook")
+	if notebook == "" {
+		notebook = a.data.GetNotebook()
+	}
+
+	commits, err := a.repo.Log(notebook, noteID)
+	if err != nil {
+		return fmt.Errorf("get git log: %w", err)
+	}
+
+	for _, commit := range commits {
+		hash := commit.Hash
+		if len(hash) > shortHashLength {
+			hash = hash[:shortHashLength]
+		}
+
+		fields := []string{
+			hash,
+			commit.Date.UTC().Format(ctx.String("time-format")),
+			commit.Author,
+			commit.Subject,
+		}
+		fmt.Fprintln(ctx.App.Writer, strings.Join(fields, ctx.String("delimiter")))
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subtlepseudonym/notes/diff"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDiffContext = 3
+)
+
+func (a *App) buildHistoryCommand() cli.Command {
+	return cli.Command{
+		Name:        "history",
+		Usage:       "list a note's revisions",
+		Description: "List every saved revision of the note specified by <noteID>, newest first",
+		ArgsUsage:   "<noteID>",
+		Action:      a.historyAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+			},
+			cli.StringFlag{
+				Name:  "time-format",
+				Usage: "format to display timestamps in",
+				Value: defaultListTimeFormat,
+			},
+			cli.StringFlag{
+				Name:  "delimiter",
+				Usage: "list column delimiter",
+				Value: defaultListColumnDelimiter,
+			},
+		},
+	}
+}
+
+func (a *App) buildDiffCommand() cli.Command {
+	return cli.Command{
+		Name:        "diff",
+		Usage:       "show changes between note revisions",
+		Description: "Print a unified diff between revisions <revA> and <revB> of the note specified by <noteID>. If <revB> is omitted, the current revision is used",
+		ArgsUsage:   "<noteID> <revA> [<revB>]",
+		Action:      a.diffAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+			},
+			cli.IntFlag{
+				Name:  "context, U",
+				Usage: "number of context lines",
+				Value: defaultDiffContext,
+			},
+		},
+	}
+}
+
+func (a *App) buildRevertCommand() cli.Command {
+	return cli.Command{
+		Name:        "revert",
+		Usage:       "restore a previous revision of a note",
+		Description: "Create a new revision of the note specified by <noteID> with the body of revision <rev>",
+		ArgsUsage:   "<noteID> <rev>",
+		Action:      a.revertAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+			},
+		},
+	}
+}
+
+func parseRevision(arg string) (int, error) {
+	if arg == "" {
+		return 0, fmt.Errorf("usage: revision argument required")
+	}
+
+	rev, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("parse revision argument: %w", err)
+	}
+	return rev, nil
+}
+
+func (a *App) historyAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	noteID, restoreForward, err := a.followForward(noteID, logger)
+	if err != nil {
+		return err
+	}
+	defer restoreForward()
+
+	note, err := a.data.GetNote(noteID)
+	if err != nil {
+		return fmt.Errorf("get note: %w", err)
+	}
+
+	for i, revision := range note.ListRevisions() {
+		current := " "
+		if i == 0 {
+			current = "*"
+		}
+
+		fields := []string{
+			fmt.Sprintf("%s%4d", current, revision.Number),
+			revision.Updated.UTC().Format(ctx.String("time-format")),
+			fmt.Sprintf("%d bytes", revision.Size),
+		}
+		fmt.Fprintln(ctx.App.Writer, strings.Join(fields, ctx.String("delimiter")))
+	}
+
+	return nil
+}
+
+func (a *App) diffAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	noteID, restoreForward, err := a.followForward(noteID, logger)
+	if err != nil {
+		return err
+	}
+	defer restoreForward()
+
+	revA, err := parseRevision(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	note, err := a.data.GetNote(noteID)
+	if err != nil {
+		return fmt.Errorf("get note: %w", err)
+	}
+
+	revB := note.CurrentRevision()
+	if ctx.NArg() > 2 {
+		revB, err = parseRevision(ctx.Args().Get(2))
+		if err != nil {
+			return err
+		}
+	}
+
+	bodyA, err := note.BodyAt(revA)
+	if err != nil {
+		return fmt.Errorf("get revision %d: %w", revA, err)
+	}
+
+	bodyB, err := note.BodyAt(revB)
+	if err != nil {
+		return fmt.Errorf("get revision %d: %w", revB, err)
+	}
+
+	nameA := fmt.Sprintf("%x@%d", noteID, revA)
+	nameB := fmt.Sprintf("%x@%d", noteID, revB)
+	fmt.Fprint(ctx.App.Writer, diff.Unified(nameA, nameB, bodyA, bodyB, ctx.Int("context")))
+
+	return nil
+}
+
+func (a *App) revertAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	noteID, restoreForward, err := a.followForward(noteID, logger)
+	if err != nil {
+		return err
+	}
+	defer restoreForward()
+
+	rev, err := parseRevision(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	note, err := a.data.GetNote(noteID)
+	if err != nil {
+		return fmt.Errorf("get note: %w", err)
+	}
+
+	body, err := note.BodyAt(rev)
+	if err != nil {
+		return fmt.Errorf("get revision %d: %w", rev, err)
+	}
+
+	if body == note.Body {
+		return nil
+	}
+
+	now := time.Now()
+	note = note.UpdateBody(body, now)
+	note, err = note.AppendEdit(now)
+	if err != nil {
+		return fmt.Errorf("append edit to note history: %w", err)
+	}
+
+	err = a.data.SaveNote(note)
+	if err != nil {
+		return fmt.Errorf("save note: %w", err)
+	}
+	logger.Info("note reverted", zap.Int("noteID", note.Meta.ID), zap.Int("revision", rev))
+
+	return nil
+}
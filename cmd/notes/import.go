@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+	"github.com/subtlepseudonym/notes/markdown"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+var importExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+}
+
+// importFile is a file to be imported and the notebook it's destined for
+type importFile struct {
+	path     string
+	notebook string
+}
+
+func (a *App) buildImportCommand() cli.Command {
+	return cli.Command{
+		Name:        "import",
+		Usage:       "import notes from files",
+		Description: "Create notes from a markdown or text file, or from every such file in a directory tree. Front matter, such as that written by the export command, is used for note meta information when present. Otherwise, the file name is used as the title and its modification time as the creation time",
+		ArgsUsage:   "<path>",
+		Action:      a.importAction,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "report what would be imported without creating notes",
+			},
+			cli.BoolFlag{
+				Name:  "map-notebooks",
+				Usage: "import files in each top-level subdirectory into the notebook of the same name, creating it if necessary",
+			},
+			cli.BoolFlag{
+				Name:  "allow-duplicates",
+				Usage: "import files whose content matches an existing note",
+			},
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the current notebook",
+			},
+		},
+	}
+}
+
+func (a *App) importAction(ctx *cli.Context) error {
+	if !ctx.Args().Present() {
+		return fmt.Errorf("usage: path argument required")
+	}
+	root := ctx.Args().First()
+
+	current := a.data.GetNotebook()
+	logger := a.logger.Named(current).Named(ctx.Command.Name)
+
+	notebook := current
+	if ctx.String("notebook") != "" {
+		notebook = ctx.String("notebook")
+	}
+
+	files, err := findImportFiles(root, notebook, ctx.Bool("map-notebooks"))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		a.data.SetNotebook(current)
+
+		meta, err := a.data.GetMeta()
+		if err != nil {
+			logger.Error("get meta", zap.Error(err))
+			return
+		}
+		a.meta = meta
+	}()
+
+	existing := make(map[string]bool)
+	for _, nb := range a.data.GetAllNotebooks() {
+		existing[nb] = true
+	}
+
+	dryRun := ctx.Bool("dry-run")
+	var created, skipped int
+	for start := 0; start < len(files); {
+		notebook := files[start].notebook
+		end := start
+		for end < len(files) && files[end].notebook == notebook {
+			end++
+		}
+
+		var latestID int
+		hashes := make(map[[sha256.Size]byte]int)
+		if existing[notebook] {
+			err = a.data.SetNotebook(notebook)
+			if err != nil {
+				return fmt.Errorf("set notebook: %w", err)
+			}
+
+			meta, err := a.data.GetMeta()
+			if err != nil {
+				return fmt.Errorf("get meta: %w", err)
+			}
+			latestID = meta.LatestID
+
+			if !ctx.Bool("allow-duplicates") {
+				hashes, err = noteHashes(a.data)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			fmt.Fprintf(ctx.App.Writer, "create notebook %s\n", notebook)
+			if !dryRun {
+				err = a.data.CreateNotebook(notebook)
+				if err != nil {
+					return fmt.Errorf("create notebook: %w", err)
+				}
+				err = a.data.SetNotebook(notebook)
+				if err != nil {
+					return fmt.Errorf("set notebook: %w", err)
+				}
+				existing[notebook] = true
+			}
+		}
+
+		for _, file := range files[start:end] {
+			note, err := readImportFile(file.path)
+			if err != nil {
+				return err
+			}
+
+			hash := sha256.Sum256([]byte(note.Body))
+			if id, ok := hashes[hash]; ok && !ctx.Bool("allow-duplicates") {
+				fmt.Fprintf(ctx.App.Writer, "skip %s: duplicate of %s/%x\n", file.path, notebook, id)
+				skipped++
+				continue
+			}
+
+			if dryRun {
+				latestID++
+				note.Meta.ID = latestID
+			} else {
+				err = a.importNote(note)
+				if err != nil {
+					return fmt.Errorf("import %s: %w", file.path, err)
+				}
+				logger.Info("note imported", zap.Int("noteID", note.Meta.ID), zap.String("notebook", notebook), zap.String("path", file.path))
+			}
+			hashes[hash] = note.Meta.ID
+			fmt.Fprintf(ctx.App.Writer, "import %s: %s/%x %q\n", file.path, notebook, note.Meta.ID, note.Meta.Title)
+			created++
+		}
+
+		start = end
+	}
+
+	verb := "imported"
+	if dryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(ctx.App.Writer, "%s %d notes, skipped %d duplicates\n", verb, created, skipped)
+
+	return nil
+}
+
+// importNote saves a note under a freshly reserved ID in the current
+// notebook, setting the note's ID
+func (a *App) importNote(note *notes.Note) error {
+	meta, err := dal.ReserveNoteID(a.data)
+	if err != nil {
+		return fmt.Errorf("reserve note ID: %w", err)
+	}
+	note.Meta.ID = meta.LatestID
+
+	err = a.data.SaveNote(note)
+	if err != nil {
+		return fmt.Errorf("save note: %w", err)
+	}
+	return nil
+}
+
+// findImportFiles lists the files to import from root, sorted by notebook and
+// then path
+func findImportFiles(root, notebook string, mapNotebooks bool) ([]importFile, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("stat import path: %w", err)
+	}
+
+	if !info.IsDir() {
+		return []importFile{{path: root, notebook: notebook}}, nil
+	}
+
+	var files []importFile
+	err = filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p != root && dal.IsHidden(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() || !importExtensions[strings.ToLower(filepath.Ext(p))] {
+			return nil
+		}
+
+		file := importFile{
+			path:     p,
+			notebook: notebook,
+		}
+
+		if mapNotebooks {
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+
+			parts := strings.Split(filepath.ToSlash(rel), "/")
+			if len(parts) > 1 {
+				file.notebook = parts[0]
+			}
+		}
+
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk import directory: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].notebook == files[j].notebook {
+			return files[i].path < files[j].path
+		}
+		return files[i].notebook < files[j].notebook
+	})
+
+	return files, nil
+}
+
+// readImportFile creates a note, without an ID, from the contents of the
+// file at the provided path
+func readImportFile(path string) (*notes.Note, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var body string
+	var fm *markdown.FrontMatter
+	if strings.ToLower(filepath.Ext(path)) == ".txt" {
+		body = string(b)
+	} else {
+		fm, body, err = markdown.Unmarshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	note := &notes.Note{}
+	if fm != nil {
+		note.Meta = fm.NoteMeta()
+	} else {
+		note.Meta.Deleted = notes.JSONTime{Time: time.Unix(0, 0)}
+	}
+
+	if note.Meta.Title == "" {
+		note.Meta.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if note.Meta.Created.IsZero() {
+		note.Meta.Created = notes.JSONTime{Time: info.ModTime()}
+	}
+
+	updated := note.Meta.Created.Time
+	if len(note.Meta.History) > 0 {
+		updated = note.Meta.History[0].Updated.Time
+	}
+	note.UpdateBody(body, updated)
+
+	return note, nil
+}
+
+// noteHashes maps the content hash of each note body in the current notebook
+// to its note ID
+func noteHashes(data dal.DAL) (map[[sha256.Size]byte]int, error) {
+	index, err := data.GetAllNoteMetas()
+	if err != nil {
+		return nil, fmt.Errorf("get note metas: %w", err)
+	}
+
+	hashes := make(map[[sha256.Size]byte]int, len(index))
+	for id := range index {
+		note, err := data.GetNote(id)
+		if err != nil {
+			return nil, fmt.Errorf("get note %x: %w", id, err)
+		}
+		hashes[sha256.Sum256([]byte(note.Body))] = id
+	}
+
+	return hashes, nil
+}
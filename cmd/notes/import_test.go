@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/subtlepseudonym/notes"
+)
+
+// writeTestFiles writes each file's contents under root, creating any
+// parent directories
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(p), 0700)
+		if err != nil {
+			t.Fatalf("create directory: %s", err)
+		}
+		err = os.WriteFile(p, []byte(content), 0600)
+		if err != nil {
+			t.Fatalf("write %s: %s", name, err)
+		}
+	}
+}
+
+func TestFindImportFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"b.txt":                   "b",
+		"a.md":                    "a",
+		"skip.pdf":                "not imported",
+		".hidden.md":              "hidden file",
+		".hidden/x.md":            "hidden directory",
+		"work/c.md":               "c",
+		"work/.git/d.md":          "nested hidden directory",
+		"archive/2020/e.markdown": "e",
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		mapNotebooks bool
+		expected     []importFile
+	}{
+		{
+			name: "file",
+			path: "a.md",
+			expected: []importFile{
+				{path: "a.md", notebook: "default"},
+			},
+		},
+		{
+			name: "directory",
+			expected: []importFile{
+				{path: "a.md", notebook: "default"},
+				{path: "archive/2020/e.markdown", notebook: "default"},
+				{path: "b.txt", notebook: "default"},
+				{path: "work/c.md", notebook: "default"},
+			},
+		},
+		{
+			name:         "map notebooks",
+			mapNotebooks: true,
+			expected: []importFile{
+				{path: "archive/2020/e.markdown", notebook: "archive"},
+				{path: "a.md", notebook: "default"},
+				{path: "b.txt", notebook: "default"},
+				{path: "work/c.md", notebook: "work"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := findImportFiles(filepath.Join(root, test.path), "default", test.mapNotebooks)
+			if err != nil {
+				t.Fatalf("find import files: %s", err)
+			}
+
+			for i := range files {
+				rel, err := filepath.Rel(root, files[i].path)
+				if err != nil {
+					t.Fatalf("relative path: %s", err)
+				}
+				files[i].path = filepath.ToSlash(rel)
+			}
+
+			if diff := deep.Equal(files, test.expected); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNoteHashes(t *testing.T) {
+	data := openTestDAL(t, newTestHome(t), "default")
+
+	for id, body := range map[int]string{1: "first", 2: "second", 3: "first"} {
+		note := &notes.Note{
+			Meta: notes.NoteMeta{
+				ID:      id,
+				Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+			},
+		}
+		note.UpdateBody(body, time.Now())
+		err := data.SaveNote(note)
+		if err != nil {
+			t.Fatalf("save note: %s", err)
+		}
+	}
+
+	hashes, err := noteHashes(data)
+	if err != nil {
+		t.Fatalf("note hashes: %s", err)
+	}
+
+	if len(hashes) != 2 {
+		t.Errorf("expected 2 hashes, got %d", len(hashes))
+	}
+	if id := hashes[sha256.Sum256([]byte("first"))]; id != 1 && id != 3 {
+		t.Errorf("expected first to hash to note 1 or 3, got %d", id)
+	}
+	if id := hashes[sha256.Sum256([]byte("second"))]; id != 2 {
+		t.Errorf("expected second to hash to note 2, got %d", id)
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string // output lines, with the import directory removed
+		latestID int      // of the default notebook afterward
+	}{
+		{
+			name: "duplicates",
+			expected: []string{
+				"import /new.md: default/3 \"new\"",
+				"skip /same.txt: duplicate of default/1",
+				"imported 1 notes, skipped 1 duplicates",
+			},
+			latestID: 3,
+		},
+		{
+			name: "allow duplicates",
+			args: []string{"--allow-duplicates"},
+			expected: []string{
+				"import /new.md: default/3 \"new\"",
+				"import /same.txt: default/4 \"same\"",
+				"imported 2 notes, skipped 0 duplicates",
+			},
+			latestID: 4,
+		},
+		{
+			name: "dry run",
+			args: []string{"--dry-run", "--allow-duplicates"},
+			expected: []string{
+				"import /new.md: default/3 \"new\"",
+				"import /same.txt: default/4 \"same\"",
+				"would import 2 notes, skipped 0 duplicates",
+			},
+			latestID: 2,
+		},
+		{
+			name: "dry run new notebook",
+			args: []string{"--dry-run", "--notebook", "work"},
+			expected: []string{
+				"create notebook work",
+				"import /new.md: work/1 \"new\"",
+				"import /same.txt: work/2 \"same\"",
+				"would import 2 notes, skipped 0 duplicates",
+			},
+			latestID: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notesDir := newTestHome(t)
+			for _, body := range []string{"existing", "old"} {
+				_, err := runNotes(t, notesDir, "new", "--body", body)
+				if err != nil {
+					t.Fatalf("new note: %s", err)
+				}
+			}
+
+			root := t.TempDir()
+			writeTestFiles(t, root, map[string]string{
+				"new.md":   "fresh",
+				"same.txt": "existing",
+			})
+
+			out, err := runNotes(t, notesDir, append(append([]string{"import"}, test.args...), root)...)
+			if err != nil {
+				t.Fatalf("import: %s", err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(out, root, "")), "\n")
+			if diff := deep.Equal(lines, test.expected); diff != nil {
+				t.Error(diff)
+			}
+
+			data := openTestDAL(t, notesDir, "default")
+			meta, err := data.GetMeta()
+			if err != nil {
+				t.Fatalf("get meta: %s", err)
+			}
+			if meta.LatestID != test.latestID {
+				t.Errorf("expected latest ID %d, got %d", test.latestID, meta.LatestID)
+			}
+			if diff := deep.Equal(data.GetAllNotebooks(), []string{"default"}); diff != nil {
+				t.Errorf("notebooks: %v", diff)
+			}
+		})
+	}
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
 	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/operations"
 
 	"github.com/urfave/cli"
 )
@@ -18,8 +20,8 @@ func (a *App) buildInfoCommand() cli.Command {
 	return cli.Command{
 		Name:        "info",
 		Usage:       "print info",
-		Description: "This command gets information about the app binary, the meta file, or specific note files and prints it in a human-friendly format. These are specified by providing no arguments, the \"meta\" argument, or a noteID respectively",
-		ArgsUsage:   "[meta | <noteID>]",
+		Description: "This command gets information about the app binary, the meta file, the note cache, or specific note files and prints it in a human-friendly format. These are specified by providing no arguments, the \"meta\" argument, the \"cache\" argument, or a noteID respectively. Cache counts are kept for the life of the process, so they're most useful in interactive mode",
+		ArgsUsage:   "[meta | cache | <noteID>]",
 		Action:      a.infoAction,
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -35,31 +37,50 @@ func (a *App) infoAction(ctx *cli.Context) error {
 		return printAppInfo(ctx)
 	}
 
-	if ctx.String("notebook") != "" {
-		err := a.data.SetNotebook(ctx.String("notebook"))
-		if err != nil {
-			return fmt.Errorf("set notebook: %w", err)
-		}
+	if ctx.Args().First() == "cache" {
+		return a.printCacheInfo(ctx)
+	}
+
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	opCtx, err := a.operationsContext(logger)
+	if err != nil {
+		return err
 	}
 
 	if ctx.Args().First() == "meta" {
-		return printMetaInfo(ctx, a.meta)
+		return printMetaInfo(ctx, opCtx.Meta)
 	}
 
-	noteID, err := strconv.ParseInt(ctx.Args().First(), 16, 64)
+	noteID, err := parseNoteID(ctx.Args().First())
 	if err != nil {
-		return fmt.Errorf("parse noteID argument: %w", err)
+		return err
+	}
+
+	options := operations.NoteInfoOptions{
+		FollowForwards: true,
 	}
 
-	note, err := a.data.GetNote(int(noteID))
+	_, info, err := operations.GetNoteInfo(opCtx, options, noteID)
 	if err != nil {
-		return fmt.Errorf("get note file: %w", err)
+		return fmt.Errorf("get note info: %w", err)
 	}
 
-	return printNoteInfo(ctx, a.meta, note)
+	return printNoteInfo(ctx, info)
 }
 
 func printRows(ctx *cli.Context, rows [][]string) {
+	writeRows(ctx.App.Writer, rows)
+}
+
+// writeRows writes label and value pairs to w with the labels aligned
+func writeRows(w io.Writer, rows [][]string) {
 	var labelWidth int
 	for _, row := range rows {
 		if len(row[0]) > labelWidth {
@@ -69,7 +90,7 @@ func printRows(ctx *cli.Context, rows [][]string) {
 
 	for _, row := range rows {
 		labelPad := labelWidth - utf8.RuneCountInString(row[0])
-		fmt.Fprintf(ctx.App.Writer, "%s%s %s %s\n", row[0], strings.Repeat(" ", labelPad), infoDelimiter, row[1])
+		fmt.Fprintf(w, "%s%s %s %s\n", row[0], strings.Repeat(" ", labelPad), infoDelimiter, row[1])
 	}
 }
 
@@ -105,21 +126,60 @@ func printMetaInfo(ctx *cli.Context, meta *notes.Meta) error {
 	return nil
 }
 
-func printNoteInfo(ctx *cli.Context, meta *notes.Meta, note *notes.Note) error {
+func (a *App) printCacheInfo(ctx *cli.Context) error {
+	stats, ok := a.cacheStats()
+	if !ok {
+		printRows(ctx, [][]string{{"policy", "none"}})
+		return nil
+	}
+
+	entries, bytes := strconv.Itoa(stats.Entries), strconv.Itoa(stats.Bytes)
+	if stats.Capacity > 0 {
+		entries = fmt.Sprintf("%d / %d", stats.Entries, stats.Capacity)
+	}
+	if stats.MaxBytes > 0 {
+		bytes = fmt.Sprintf("%d / %d", stats.Bytes, stats.MaxBytes)
+	}
+
 	rows := [][]string{
-		{"id", strconv.Itoa(note.Meta.ID)},
-		{"title", note.Meta.Title},
-		{"created", note.Meta.Created.Format(time.RFC3339)},
+		{"policy", stats.Policy},
+		{"hits", strconv.Itoa(stats.Hits)},
+		{"misses", strconv.Itoa(stats.Misses)},
+		{"hit rate", fmt.Sprintf("%.1f%%", stats.HitRate()*100)},
+		{"evictions", strconv.Itoa(stats.Evictions)},
+		{"expirations", strconv.Itoa(stats.Expirations)},
+		{"invalidations", strconv.Itoa(stats.Invalidations)},
+		{"entries", entries},
+		{"bytes", bytes},
+	}
+	if stats.TTL > 0 {
+		rows = append(rows, []string{"ttl", stats.TTL.String()})
+	}
+
+	printRows(ctx, rows)
+	return nil
+}
+
+func printNoteInfo(ctx *cli.Context, info operations.NoteInfo) error {
+	meta := info.Meta
+	rows := [][]string{
+		{"id", strconv.Itoa(meta.ID)},
+		{"title", meta.Title},
+		{"created", meta.Created.Format(time.RFC3339)},
+	}
+
+	if meta.IsDeleted() {
+		rows = append(rows, []string{"deleted", meta.Deleted.Format(time.RFC3339)})
 	}
 
-	if !note.Meta.Deleted.Equal(time.Unix(0, 0)) {
-		rows = append(rows, []string{"deleted", note.Meta.Deleted.Format(time.RFC3339)})
+	if len(meta.Tags) > 0 {
+		rows = append(rows, []string{"tags", strings.Join(meta.Tags, ", ")})
 	}
 
-	if note.Meta.History != nil {
-		rows = append(rows, []string{"history", fmt.Sprintf("%s @ %d bytes", note.Meta.History[0].Updated.Format(time.RFC3339), note.Meta.History[0].Size)})
-		for i := 1; i < len(note.Meta.History); i++ {
-			rows = append(rows, []string{"", fmt.Sprintf("%s @ %d bytes", note.Meta.History[i].Updated.Format(time.RFC3339), note.Meta.History[i].Size)})
+	if meta.History != nil {
+		rows = append(rows, []string{"history", fmt.Sprintf("%s @ %d bytes", meta.History[0].Updated.Format(time.RFC3339), meta.History[0].Size)})
+		for i := 1; i < len(meta.History); i++ {
+			rows = append(rows, []string{"", fmt.Sprintf("%s @ %d bytes", meta.History[i].Updated.Format(time.RFC3339), meta.History[i].Size)})
 		}
 	}
 
@@ -2,12 +2,12 @@ package main
 
 import (
 	"fmt"
-	"math"
 	"strings"
 	"time"
 
+	"github.com/subtlepseudonym/notes/operations"
+
 	"github.com/urfave/cli"
-	"go.uber.org/zap"
 )
 
 const (
@@ -57,97 +57,75 @@ func (a *App) buildListCommand() cli.Command {
 				Usage: "list column delimiter",
 				Value: defaultListColumnDelimiter,
 			},
+			cli.StringSliceFlag{
+				Name:  "tag",
+				Usage: "only show notes with `TAG`. May be repeated to require several tags",
+			},
+			cli.StringSliceFlag{
+				Name:  "not-tag",
+				Usage: "hide notes with `TAG`. May be repeated",
+			},
 		},
 		UseShortOptionHandling: true,
 	}
 }
 
 func (a *App) lsAction(ctx *cli.Context) error {
-	if ctx.String("notebook") != "" {
-		notebook := a.data.GetNotebook()
-		logger := a.logger.Named(notebook).Named(ctx.Command.Name)
-
-		defer func() {
-			a.data.SetNotebook(notebook)
-
-			meta, err := a.data.GetMeta()
-			if err != nil {
-				logger.Error("get meta", zap.Error(err))
-				return
-			}
-			a.meta = meta
-		}()
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
 
-		err := a.data.SetNotebook(ctx.String("notebook"))
-		if err != nil {
-			return fmt.Errorf("set notebook: %w", err)
-		}
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
 	}
+	defer restore()
 
-	index, err := a.data.GetAllNoteMetas()
+	opCtx, err := a.operationsContext(logger)
 	if err != nil {
-		return fmt.Errorf("get note metas: %v", err)
+		return err
 	}
 
-	limit := ctx.Int("num")
-	if ctx.Bool("all") || len(index) < limit {
-		limit = len(index)
+	options := operations.ListNotesOptions{
+		Deleted:     ctx.Bool("deleted"),
+		Tags:        ctx.StringSlice("tag"),
+		ExcludeTags: ctx.StringSlice("not-tag"),
+		Limit:       ctx.Int("num"),
+		Reverse:     ctx.Bool("reverse"),
+	}
+	if ctx.Bool("all") {
+		options.Limit = 0
 	}
 
-	padAmount := int(math.Log(float64(len(index)))/math.Log(16.0) + 1.0)
-	idFormat := fmt.Sprintf(" %%%dx", padAmount)
-
-	meta, err := a.data.GetMeta()
+	_, metas, err := operations.ListNotes(opCtx, options)
 	if err != nil {
-		return fmt.Errorf("get meta: %v", err)
+		return fmt.Errorf("list notes: %w", err)
 	}
-	a.meta = meta
-	idx := a.meta.LatestID
 
-	var listed int
-	var noteList []string
-	for listed < limit && idx >= 0 {
-		note, exists := index[idx]
-		idx--
-		if !exists {
-			continue
-		}
+	var maxID int
+	for _, meta := range metas {
+		maxID = max(maxID, meta.ID)
+	}
+	idFormat := fmt.Sprintf(" %%%dx", len(fmt.Sprintf("%x", maxID)))
 
-		var fields []string
-		fields = append(fields, fmt.Sprintf(idFormat, note.ID))
+	timeFormat := ctx.String("time-format")
+	if timeFormat == "" {
+		timeFormat = defaultListTimeFormat
+	}
 
+	for _, meta := range metas {
+		fields := []string{fmt.Sprintf(idFormat, meta.ID)}
 		if ctx.Bool("deleted") {
-			if time.Unix(0, 0).Equal(note.Deleted.Time) {
+			if !meta.IsDeleted() {
 				fields = append(fields, " ")
 			} else {
 				fields = append(fields, "d")
 			}
-		} else if !time.Unix(0, 0).Equal(note.Deleted.Time) {
-			continue
 		}
-
 		if ctx.Bool("long") {
-			timeFormat := defaultListTimeFormat
-			if ctx.String("time-format") != "" {
-				timeFormat = ctx.String("time-format")
-			}
-			fields = append(fields, note.Created.UTC().Format(timeFormat))
-		}
-
-		fields = append(fields, note.Title)
-
-		noteList = append(noteList, strings.Join(fields, ctx.String("delimiter")))
-		listed++
-	}
-
-	if !ctx.Bool("reverse") {
-		for l, r := 0, len(noteList)-1; l < r; l, r = l+1, r-1 {
-			noteList[l], noteList[r] = noteList[r], noteList[l]
+			fields = append(fields, meta.Created.UTC().Format(timeFormat))
 		}
-	}
+		fields = append(fields, meta.Title)
 
-	for _, note := range noteList {
-		fmt.Fprintln(ctx.App.Writer, note)
+		fmt.Fprintln(ctx.App.Writer, strings.Join(fields, ctx.String("delimiter")))
 	}
 
 	return nil
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAppDirectory        = "notes"
+	defaultXDGDataDirectory    = ".local/share"
+	defaultXDGDataEnvironment  = "XDG_DATA_HOME"
+	defaultXDGStateDirectory   = ".local/state"
+	defaultXDGStateEnvironment = "XDG_STATE_HOME"
+	defaultStateLogFilename    = "log"
+	defaultStateHistoryFile    = "history"
+	defaultStateNotebookFile   = "notebook"
+	defaultStateGCFile         = "gc"
+)
+
+// storage holds the locations of the files notes reads and writes
+type storage struct {
+	data     string // notebooks, search indexes, and the sqlite database
+	log      string
+	history  string
+	notebook string // holds the name of the current notebook
+	gc       string // holds when garbage was last collected at startup
+}
+
+// inDirectory keeps everything in one directory, as ~/.notes always has
+func inDirectory(directory string) storage {
+	return storage{
+		data:     directory,
+		log:      path.Join(directory, defaultLogFilePath),
+		history:  path.Join(directory, defaultHistoryFilePath),
+		notebook: path.Join(directory, defaultNotebookFilePath),
+		gc:       path.Join(directory, defaultGCFilePath),
+	}
+}
+
+// xdgStorage keeps data in $XDG_DATA_HOME/notes and the log and history in
+// $XDG_STATE_HOME/notes
+func xdgStorage(home string) storage {
+	state := path.Join(xdgDirectory(home, defaultXDGStateEnvironment, defaultXDGStateDirectory), defaultAppDirectory)
+	return storage{
+		data:     path.Join(xdgDirectory(home, defaultXDGDataEnvironment, defaultXDGDataDirectory), defaultAppDirectory),
+		log:      path.Join(state, defaultStateLogFilename),
+		history:  path.Join(state, defaultStateHistoryFile),
+		notebook: path.Join(state, defaultStateNotebookFile),
+		gc:       path.Join(state, defaultStateGCFile),
+	}
+}
+
+// xdgDirectory gets the base directory named by the environment variable,
+// which the XDG base directory specification requires to be absolute, or
+// the default within the home directory
+func xdgDirectory(home, environment, fallback string) string {
+	directory := os.Getenv(environment)
+	if !filepath.IsAbs(directory) {
+		return path.Join(home, fallback)
+	}
+	return directory
+}
+
+// resolveStorage gets where notes' files are kept. The notes-dir flag holds
+// everything, as does ~/.notes for existing users who haven't migrated.
+// Otherwise, the XDG data and state directories are used
+func resolveStorage(ctx *cli.Context) (storage, error) {
+	if directory := ctx.GlobalString("notes-dir"); directory != "" {
+		directory, err := filepath.Abs(directory)
+		if err != nil {
+			return storage{}, fmt.Errorf("resolve notes directory: %w", err)
+		}
+		return inDirectory(directory), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return storage{}, fmt.Errorf("get home directory: %w", err)
+	}
+
+	xdg := xdgStorage(home)
+	if _, err := os.Stat(xdg.data); err == nil {
+		return xdg, nil
+	}
+
+	legacy := path.Join(home, defaultNotesDirectory)
+	if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+		return inDirectory(legacy), nil
+	}
+
+	return xdg, nil
+}
+
+func (a *App) buildMigrateCommand() cli.Command {
+	return cli.Command{
+		Name:        "migrate",
+		Usage:       "move ~/.notes to the XDG directories",
+		Description: "Move notebooks, search indexes, and the sqlite database from ~/.notes to $XDG_DATA_HOME/notes, the log and command history to $XDG_STATE_HOME/notes, and ~/.notes/config to $XDG_CONFIG_HOME/notes/config.yaml. Until it's migrated, ~/.notes continues to be used",
+		Action:      a.migrateAction,
+	}
+}
+
+func (a *App) migrateAction(ctx *cli.Context) error {
+	logger := a.logger.Named(ctx.Command.Name)
+
+	if a.inInteractive {
+		return fmt.Errorf("migrate can't be run in interactive mode, which is using the files being moved")
+	}
+	if ctx.GlobalString("notes-dir") != "" {
+		return fmt.Errorf("notes-dir is set, so ~/.notes isn't in use")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("get home directory: %w", err)
+	}
+
+	legacy := inDirectory(path.Join(home, defaultNotesDirectory))
+	if a.storage != legacy {
+		return fmt.Errorf("nothing to migrate, notes are kept in %s", a.storage.data)
+	}
+	xdg := xdgStorage(home)
+
+	// the config must move before the data directory, which it's inside of
+	legacyConfig := path.Join(legacy.data, defaultNotesConfigFilename)
+	if a.config.Path == legacyConfig {
+		configHome := xdgDirectory(home, defaultXDGConfigEnvironment, defaultXDGConfigDirectory)
+		err = move(legacyConfig, path.Join(configHome, defaultConfigDirectory, defaultConfigFilename))
+		if err != nil {
+			return fmt.Errorf("move config: %w", err)
+		}
+	}
+
+	err = move(legacy.data, xdg.data)
+	if err != nil {
+		return fmt.Errorf("move notes directory: %w", err)
+	}
+
+	// the state files were moved along with the data directory
+	moved := inDirectory(xdg.data)
+	files := [][2]string{
+		{moved.log, xdg.log},
+		{moved.history, xdg.history},
+		{moved.notebook, xdg.notebook},
+		{moved.gc, xdg.gc},
+	}
+	for _, file := range files {
+		err = move(file[0], file[1])
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("move %s: %w", path.Base(file[0]), err)
+		}
+	}
+
+	logger.Info("migrated notes directory", zap.String("from", legacy.data), zap.String("to", xdg.data))
+	fmt.Fprintf(ctx.App.Writer, "moved %s to %s\n", legacy.data, xdg.data)
+	fmt.Fprintf(ctx.App.Writer, "log and command history are now kept in %s\n", path.Dir(xdg.log))
+	return nil
+}
+
+// move renames from to the path to, creating its directory. Nothing is
+// overwritten, and moves across filesystems aren't attempted
+func move(from, to string) error {
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+	if _, err := os.Stat(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
+	}
+
+	err := os.MkdirAll(path.Dir(to), os.ModeDir|os.FileMode(0700))
+	if err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	err = os.Rename(from, to)
+	if err != nil {
+		return fmt.Errorf("%w, move it yourself or use the notes-dir flag", err)
+	}
+	return nil
+}
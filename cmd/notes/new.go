@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/operations"
 
 	"github.com/urfave/cli"
 	"go.uber.org/zap"
@@ -17,10 +18,11 @@ const (
 
 func (a *App) buildNewCommand() cli.Command {
 	return cli.Command{
-		Name:    "new",
-		Aliases: []string{"n"},
-		Usage:   "create a new note",
-		Action:  a.newAction,
+		Name:        "new",
+		Aliases:     []string{"n"},
+		Usage:       "create a new note",
+		Description: "Create a new note, opening an editor to write its body. If --body or --file is provided, or stdin is not a terminal, the body is read from there instead and the new noteID is printed",
+		Action:      a.newAction,
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "no-watch",
@@ -38,6 +40,18 @@ func (a *App) buildNewCommand() cli.Command {
 				Name:  "title, t",
 				Usage: "note title",
 			},
+			cli.StringFlag{
+				Name:  "body, b",
+				Usage: "use `BODY` as the note body rather than opening an editor",
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "read the note body from `FILE` rather than opening an editor. Use \"-\" for stdin",
+			},
+			cli.StringSliceFlag{
+				Name:  "tag",
+				Usage: "add `TAG` to the note. May be repeated",
+			},
 			cli.StringFlag{
 				Name:   "editor",
 				Usage:  "text editor command",
@@ -64,110 +78,52 @@ func (a *App) buildNewCommand() cli.Command {
 }
 
 func (a *App) newAction(ctx *cli.Context) error {
-	notebook := a.data.GetNotebook()
-	logger := a.logger.Named(notebook).Named(ctx.Command.Name)
-
-	if ctx.String("notebook") != "" {
-		defer func() {
-			a.data.SetNotebook(notebook)
-
-			meta, err := a.data.GetMeta()
-			if err != nil {
-				logger.Error("get meta", zap.Error(err))
-				return
-			}
-			a.meta = meta
-		}()
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
 
-		err := a.data.SetNotebook(ctx.String("notebook"))
-		if err != nil {
-			return fmt.Errorf("set notebook: %w", err)
-		}
-	}
-
-	index, err := a.data.GetAllNoteMetas()
+	restore, err := a.useNotebookFlag(ctx, logger)
 	if err != nil {
-		return fmt.Errorf("get note metas: %v", err)
+		return err
 	}
+	defer restore()
 
-	meta, err := a.data.GetMeta()
+	tags, err := parseTags(ctx.StringSlice("tag"))
 	if err != nil {
-		return fmt.Errorf("get meta: %v", err)
+		return err
 	}
-	a.meta = meta
 
-	newNoteID := a.meta.LatestID + 1
-	_, exists := index[newNoteID]
-	if exists {
-		return fmt.Errorf("note ID: must be unique")
-	}
-
-	var title string
-	if ctx.String("title") != "" {
-		title = ctx.String("title")
-	} else {
-		title = generateDateTitle(ctx.String("title-format"), ctx.String("title-location"), logger)
-	}
-
-	note := &notes.Note{
-		Meta: notes.NoteMeta{
-			ID:      newNoteID,
-			Title:   title,
-			Created: notes.JSONTime{time.Now()},
-			Deleted: notes.JSONTime{time.Unix(0, 0)},
-		},
-	}
-	a.meta.LatestID = note.Meta.ID
-	err = a.data.SaveMeta(a.meta)
+	body, ok, err := operations.ReadBody(bodyOptions(ctx, true))
 	if err != nil {
-		return fmt.Errorf("save meta: %w", err)
+		return err
 	}
-	logger.Info("meta latestID updated", zap.Int("metaSize", a.meta.Size))
 
-	body, err := a.editNote(ctx, note, logger)
-	if err != nil {
-		return fmt.Errorf("user handoff: %w", err)
+	options := operations.NewNoteOptions{
+		Title:        ctx.String("title"),
+		DateFormat:   ctx.String("title-format"),
+		DateLocation: ctx.String("title-location"),
+		Tags:         tags,
+		Body:         body,
+		NoHistory:    ctx.Bool("no-history"),
 	}
-	note.Body = body
-
-	if !ctx.Bool("no-history") {
-		note, err = note.AppendEdit(time.Now())
-		if err != nil {
-			return fmt.Errorf("append edit to note history: %w", err)
+	if !ok {
+		options.Editor = func(note *notes.Note) (string, error) {
+			return a.editNote(ctx, note, logger)
 		}
 	}
 
-	err = a.data.SaveNote(note)
+	opCtx, err := a.operationsContext(logger)
 	if err != nil {
-		// FIXME: persist the note somewhere if saving it fails
-		return fmt.Errorf("save note: %w", err)
+		return err
 	}
-	logger.Info("note updated", zap.Int("noteID", note.Meta.ID), zap.String("notebook", a.data.GetNotebook()))
 
-	metaSize, err := a.meta.ApproxSize()
+	opCtx, err = operations.NewNote(opCtx, options)
 	if err != nil {
-		return fmt.Errorf("get meta size: %w", err)
+		return fmt.Errorf("new note: %w", err)
 	}
+	a.meta = opCtx.Meta
+	logger.Info("note created", zap.Int("noteID", a.meta.LatestID), zap.String("notebook", a.data.GetNotebook()))
 
-	a.meta.Size = metaSize
-	err = a.data.SaveMeta(a.meta)
-	if err != nil {
-		return fmt.Errorf("save meta: %w", err)
+	if ok {
+		fmt.Fprintf(ctx.App.Writer, "%x\n", a.meta.LatestID)
 	}
-	logger.Info("meta updated", zap.Int("metaSize", a.meta.Size))
-
 	return nil
 }
-
-func generateDateTitle(format, location string, logger *zap.Logger) string {
-	var loc *time.Location
-	l, err := time.LoadLocation(location)
-	if err != nil {
-		logger.Warn("load location failed, defaulting to UTC", zap.String("location", location), zap.String("format", format), zap.Error(err))
-		loc = time.UTC
-	} else {
-		loc = l
-	}
-
-	return time.Now().In(loc).Format(format)
-}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"sort"
+	"os"
+	"strings"
+
+	"github.com/subtlepseudonym/notes/operations"
 
 	"github.com/urfave/cli"
 	"go.uber.org/zap"
@@ -20,6 +24,9 @@ func (a *App) buildNotebookCommand() cli.Command {
 			a.listNotebooks(),
 			a.setNotebook(),
 			a.renameNotebook(),
+			a.encryptNotebook(),
+			a.decryptNotebook(),
+			a.rekeyNotebook(),
 		},
 	}
 }
@@ -44,14 +51,15 @@ func (a *App) createNotebookAction(ctx *cli.Context) error {
 	}
 	name := ctx.Args().First()
 
-	err := a.data.CreateNotebook(name)
+	opCtx, err := operations.CreateNotebook(a.notebookContext(), operations.NotebookOptions{Use: true}, name)
 	if err != nil {
-		return fmt.Errorf("create notebook: %v", err)
+		return err
 	}
+	a.meta = opCtx.Meta
 
-	err = a.data.SetNotebook(name)
+	err = a.saveCurrentNotebook()
 	if err != nil {
-		return fmt.Errorf("set notebook: %v", err)
+		return err
 	}
 
 	a.logger.Info("notebook created", zap.String("notebook", name))
@@ -68,12 +76,7 @@ func (a *App) listNotebooks() cli.Command {
 }
 
 func (a *App) listNotebooksAction(ctx *cli.Context) error {
-	var notebooks []string
-	for _, notebook := range a.data.GetAllNotebooks() {
-		notebooks = append(notebooks, notebook)
-	}
-	sort.Strings(notebooks)
-
+	_, notebooks := operations.ListNotebooks(a.notebookContext())
 	for _, notebook := range notebooks {
 		fmt.Fprintln(ctx.App.Writer, "  ", notebook)
 	}
@@ -96,18 +99,7 @@ func (a *App) setNotebookAction(ctx *cli.Context) error {
 	}
 	name := ctx.Args().First()
 
-	err := a.data.SetNotebook(name)
-	if err != nil {
-		return fmt.Errorf("set notebook: %v", err)
-	}
-
-	meta, err := a.data.GetMeta()
-	if err != nil {
-		return fmt.Errorf("get meta: %v", err)
-	}
-	a.meta = meta
-
-	return nil
+	return a.useNotebook(name)
 }
 
 func (a *App) renameNotebook() cli.Command {
@@ -127,14 +119,15 @@ func (a *App) renameNotebookAction(ctx *cli.Context) error {
 	oldName := ctx.Args().Get(0)
 	newName := ctx.Args().Get(1)
 
-	err := a.data.RenameNotebook(oldName, newName)
+	opCtx, err := operations.RenameNotebook(a.notebookContext(), operations.NotebookOptions{Use: true}, oldName, newName)
 	if err != nil {
-		return fmt.Errorf("rename notebook: %v", err)
+		return err
 	}
+	a.meta = opCtx.Meta
 
-	err = a.data.SetNotebook(newName)
+	err = a.saveCurrentNotebook()
 	if err != nil {
-		return fmt.Errorf("set notebook: %v", err)
+		return err
 	}
 
 	a.logger.Info(
@@ -145,3 +138,68 @@ func (a *App) renameNotebookAction(ctx *cli.Context) error {
 
 	return nil
 }
+
+// notebookContext creates the context for calling notebook operations, which
+// don't need the current notebook's meta to be reread
+func (a *App) notebookContext() *operations.Context {
+	return &operations.Context{
+		Meta:   a.meta,
+		DAL:    a.data,
+		Logger: a.logger.Named("notebook"),
+	}
+}
+
+// useNotebook sets the current notebook and saves it so that later
+// invocations use it too
+func (a *App) useNotebook(name string) error {
+	opCtx, err := operations.UseNotebook(a.notebookContext(), name)
+	if err != nil {
+		return err
+	}
+	a.meta = opCtx.Meta
+
+	return a.saveCurrentNotebook()
+}
+
+// saveCurrentNotebook saves the current notebook so that later invocations
+// use it too
+func (a *App) saveCurrentNotebook() error {
+	err := os.WriteFile(a.storage.notebook, []byte(a.data.GetNotebook()+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("save current notebook: %w", err)
+	}
+	return nil
+}
+
+// restoreNotebook sets the current notebook to the notebook flag, if set, or
+// to the notebook saved by the last invocation to change it. A saved notebook
+// which doesn't exist, such as when a different DAL is used, is skipped for
+// this invocation rather than preventing notes from starting. It isn't
+// forgotten, as it may exist when the usual DAL is used again
+func (a *App) restoreNotebook(ctx *cli.Context) error {
+	if name := ctx.GlobalString("notebook"); name != "" {
+		err := a.data.SetNotebook(name)
+		if err != nil {
+			return fmt.Errorf("set notebook: %v", err)
+		}
+		return nil
+	}
+
+	b, err := os.ReadFile(a.storage.notebook)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read current notebook: %w", err)
+	}
+
+	name := strings.TrimSpace(string(b))
+	if name == "" || name == a.data.GetNotebook() {
+		return nil
+	}
+
+	err = a.data.SetNotebook(name)
+	if err != nil {
+		a.logger.Warn("saved notebook unavailable, using default", zap.String("notebook", name), zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRestoreNotebook(t *testing.T) {
+	tests := []struct {
+		name     string
+		saved    string // overwrites the notebook saved by nb use
+		env      string
+		args     []string
+		expected string // notebook the new note is saved in
+	}{
+		{
+			name:     "saved",
+			expected: "work",
+		},
+		{
+			name:     "flag",
+			args:     []string{"--notebook", "home"},
+			expected: "home",
+		},
+		{
+			name:     "env",
+			env:      "home",
+			expected: "home",
+		},
+		{
+			name:     "missing saved notebook",
+			saved:    "gone",
+			expected: "default",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notesDir := newTestHome(t)
+			notebookPath := path.Join(notesDir, defaultNotebookFilePath)
+
+			for _, args := range [][]string{
+				{"notebook", "new", "work"},
+				{"notebook", "new", "home"},
+				{"notebook", "use", "work"},
+			} {
+				_, err := runNotes(t, notesDir, args...)
+				if err != nil {
+					t.Fatalf("%v: %s", args, err)
+				}
+			}
+
+			saved := "work"
+			if test.saved != "" {
+				saved = test.saved
+				err := os.WriteFile(notebookPath, []byte(saved+"\n"), 0644)
+				if err != nil {
+					t.Fatalf("write saved notebook: %s", err)
+				}
+			}
+			t.Setenv("NOTES_NOTEBOOK", test.env)
+
+			_, err := runNotes(t, notesDir, append(test.args, "new", "--body", "restored")...)
+			if err != nil {
+				t.Fatalf("new note: %s", err)
+			}
+
+			for _, notebook := range []string{"default", "work", "home"} {
+				meta, err := openTestDAL(t, notesDir, notebook).GetMeta()
+				if err != nil {
+					t.Fatalf("get meta: %s", err)
+				}
+
+				expected := 0
+				if notebook == test.expected {
+					expected = 1
+				}
+				if meta.LatestID != expected {
+					t.Errorf("expected %d notes in %s, got %d", expected, notebook, meta.LatestID)
+				}
+			}
+
+			// neither overriding nor skipping the saved notebook forgets it
+			b, err := os.ReadFile(notebookPath)
+			if err != nil {
+				t.Fatalf("read saved notebook: %s", err)
+			}
+			if string(b) != saved+"\n" {
+				t.Errorf("expected saved notebook %q, got %q", saved, b)
+			}
+		})
+	}
+}
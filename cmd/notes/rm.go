@@ -2,8 +2,8 @@ package main
 
 import (
 	"fmt"
-	"strconv"
-	"time"
+
+	"github.com/subtlepseudonym/notes/operations"
 
 	"github.com/urfave/cli"
 	"go.uber.org/zap"
@@ -29,54 +29,39 @@ func (a *App) buildRemoveCommand() cli.Command {
 }
 
 func (a *App) rmAction(ctx *cli.Context) error {
-	notebook := a.data.GetNotebook()
-	logger := a.logger.Named(notebook).Named(ctx.Command.Name)
-
-	if ctx.String("notebook") != "" {
-		defer func() {
-			a.data.SetNotebook(notebook)
-
-			meta, err := a.data.GetMeta()
-			if err != nil {
-				logger.Error("get meta", zap.Error(err))
-				return
-			}
-			a.meta = meta
-		}()
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
 
-		err := a.data.SetNotebook(ctx.String("notebook"))
-		if err != nil {
-			return fmt.Errorf("set notebook: %w", err)
-		}
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
 	}
+	defer restore()
 
-	if !ctx.Args().Present() {
-		return fmt.Errorf("usage: noteID argument required")
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
 	}
-	n, err := strconv.ParseInt(ctx.Args().First(), 16, 64)
+
+	noteID, restoreForward, err := a.followForward(noteID, logger)
 	if err != nil {
-		return fmt.Errorf("parse noteID argument: %w", err)
+		return err
 	}
-	noteID := int(n)
+	defer restoreForward()
 
-	note, err := a.data.GetNote(noteID)
+	opCtx, err := a.operationsContext(logger)
 	if err != nil {
-		return fmt.Errorf("get note: %w", err)
+		return err
+	}
+
+	options := operations.RemoveNoteOptions{
+		HardDelete: ctx.Bool("hard"),
 	}
 
-	if ctx.Bool("hard") {
-		err = a.data.RemoveNote(noteID)
-		if err != nil {
-			return fmt.Errorf("remove note file: %w", err)
-		}
-	} else {
-		note.Meta.Deleted.Time = time.Now()
-		err = a.data.SaveNote(note)
-		if err != nil {
-			return fmt.Errorf("save note: %w", err)
-		}
-		logger.Info("note updated", zap.Int("noteID", note.Meta.ID))
+	_, err = operations.RemoveNote(opCtx, options, noteID)
+	if err != nil {
+		return fmt.Errorf("remove note: %w", err)
 	}
+	logger.Info("note removed", zap.Int("noteID", noteID), zap.String("notebook", a.data.GetNotebook()), zap.Bool("hard", options.HardDelete))
 
 	return nil
 }
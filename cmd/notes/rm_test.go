@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRemoveFollowsForward(t *testing.T) {
+	notesDir := newTestHome(t)
+
+	for _, args := range [][]string{
+		{"notebook", "new", "work"},
+		{"notebook", "use", "default"},
+		{"new", "--body", "moving", "--title", "plans"},
+		{"mv", "1", "work"},
+		{"rm", "1"},
+	} {
+		_, err := runNotes(t, notesDir, args...)
+		if err != nil {
+			t.Fatalf("%v: %s", args, err)
+		}
+	}
+
+	note, err := openTestDAL(t, notesDir, "work").GetNote(1)
+	if err != nil {
+		t.Fatalf("get moved note: %s", err)
+	}
+	if !note.Meta.IsDeleted() {
+		t.Errorf("removing the moved note's old ID didn't delete it")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/subtlepseudonym/notes/dal/search"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchDirectory = ".search"
+	defaultSearchSize      = 10
+	defaultSnippetLength   = 80
+)
+
+func (a *App) buildSearchCommand() cli.Command {
+	return cli.Command{
+		Name:        "search",
+		Aliases:     []string{"s"},
+		Usage:       "search note titles and bodies",
+		Description: "Search for notes containing the terms in <query>, ranked by relevance. Searches the current notebook unless --notebook or --all-notebooks is provided",
+		ArgsUsage:   "<query>",
+		Action:      a.searchAction,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "all-notebooks, A",
+				Usage: "search all notebooks",
+			},
+			cli.BoolFlag{
+				Name:  "deleted, d",
+				Usage: "include soft deleted notes",
+			},
+			cli.BoolFlag{
+				Name:  "rebuild",
+				Usage: "rebuild the search index before searching",
+			},
+			cli.IntFlag{
+				Name:  "num, n",
+				Usage: "number of results to display",
+				Value: defaultSearchSize,
+			},
+			cli.IntFlag{
+				Name:  "snippet-length",
+				Usage: "approximate length of matched snippets. A negative value disables snippets",
+				Value: defaultSnippetLength,
+			},
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the current notebook",
+			},
+			cli.StringFlag{
+				Name:  "delimiter",
+				Usage: "result column delimiter",
+				Value: defaultListColumnDelimiter,
+			},
+		},
+		UseShortOptionHandling: true,
+	}
+}
+
+func (a *App) searchAction(ctx *cli.Context) error {
+	if !ctx.Args().Present() {
+		return fmt.Errorf("usage: query argument required")
+	}
+	query := strings.Join(ctx.Args(), " ")
+
+	var notebooks []string
+	if ctx.Bool("all-notebooks") {
+		notebooks = a.data.GetAllNotebooks()
+		sort.Strings(notebooks)
+	} else if ctx.String("notebook") != "" {
+		notebooks = []string{ctx.String("notebook")}
+	} else {
+		notebooks = []string{a.data.GetNotebook()}
+	}
+	logger := a.logger.Named(ctx.Command.Name)
+
+	if ctx.Bool("rebuild") {
+		for _, notebook := range notebooks {
+			err := a.search.Rebuild(notebook)
+			if err != nil {
+				return fmt.Errorf("rebuild search index: %w", err)
+			}
+			logger.Info("search index rebuilt", zap.String("notebook", notebook))
+		}
+	}
+
+	options := search.Options{
+		Notebooks:      notebooks,
+		Limit:          ctx.Int("num"),
+		IncludeDeleted: ctx.Bool("deleted"),
+		SnippetLength:  ctx.Int("snippet-length"),
+	}
+	results, err := a.search.Search(query, options)
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+
+	var maxID int
+	for _, result := range results {
+		if result.ID > maxID {
+			maxID = result.ID
+		}
+	}
+	idFormat := fmt.Sprintf(" %%%dx", len(fmt.Sprintf("%x", maxID)))
+
+	delimiter := ctx.String("delimiter")
+	for _, result := range results {
+		fields := []string{fmt.Sprintf(idFormat, result.ID)}
+		if len(notebooks) > 1 {
+			fields = append(fields, result.Notebook)
+		}
+		if ctx.Bool("deleted") {
+			if result.Deleted {
+				fields = append(fields, "d")
+			} else {
+				fields = append(fields, " ")
+			}
+		}
+		fields = append(fields, fmt.Sprintf("%.3f", result.Score), result.Title)
+		fmt.Fprintln(ctx.App.Writer, strings.Join(fields, delimiter))
+
+		if result.Snippet != "" {
+			fmt.Fprintf(ctx.App.Writer, "%s%s\n", strings.Repeat(" ", len(fields[0])+len(delimiter)), result.Snippet)
+		}
+	}
+
+	return nil
+}
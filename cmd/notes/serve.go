@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/subtlepseudonym/notes/server"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultServeAddress    = "localhost:8080"
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+func (a *App) buildServeCommand() cli.Command {
+	return cli.Command{
+		Name:        "serve",
+		Usage:       "serve notes over HTTP",
+		Description: "Serve notebooks, notes, and note operations as a JSON REST API under /" + server.APIVersion + ". Requests must include a bearer token listed in the token file, which holds one token per line",
+		Action:      a.serveAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "addr",
+				Usage: "`ADDRESS` to listen on",
+				Value: defaultServeAddress,
+			},
+			cli.StringFlag{
+				Name:   "token-file",
+				Usage:  "`FILE` containing API tokens",
+				EnvVar: "NOTES_TOKEN_FILE",
+			},
+			cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "certificate `FILE` for serving HTTPS",
+			},
+			cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "private key `FILE` for serving HTTPS",
+			},
+		},
+	}
+}
+
+func (a *App) serveAction(ctx *cli.Context) error {
+	logger := a.logger.Named(ctx.Command.Name)
+
+	if ctx.String("token-file") == "" {
+		return fmt.Errorf("usage: token-file flag required")
+	}
+	if (ctx.String("tls-cert") == "") != (ctx.String("tls-key") == "") {
+		return fmt.Errorf("usage: tls-cert and tls-key flags must be provided together")
+	}
+
+	tokens, err := server.LoadTokens(ctx.String("token-file"))
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              ctx.String("addr"),
+		Handler:           server.New(a.data, tokens, a.Version, logger),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	errs := make(chan error, 1)
+	go func() {
+		if ctx.String("tls-cert") != "" {
+			errs <- srv.ListenAndServeTLS(ctx.String("tls-cert"), ctx.String("tls-key"))
+		} else {
+			errs <- srv.ListenAndServe()
+		}
+	}()
+
+	logger.Info("serving", zap.String("addr", srv.Addr))
+	fmt.Fprintf(ctx.App.Writer, "serving on %s\n", srv.Addr)
+
+	select {
+	case err = <-errs:
+		return fmt.Errorf("serve: %w", err)
+	case sig := <-signals:
+		logger.Info("shutting down", zap.String("signal", sig.String()))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("shut down server: %w", err)
+	}
+
+	err = <-errs
+	if !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subtlepseudonym/notes/markdown"
+	"github.com/subtlepseudonym/notes/operations"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPager = "less"
+
+	// defaultLessOptions makes less exit when output fits on one screen,
+	// pass through ANSI styling, and leave output on the screen afterward
+	defaultLessOptions = "FRX"
+)
+
+func (a *App) buildShowCommand() cli.Command {
+	return cli.Command{
+		Name:        "show",
+		Aliases:     []string{"cat"},
+		Usage:       "print a note",
+		Description: "Print the body of the note specified by <noteID>. When printing to a terminal, the output is piped through the pager",
+		ArgsUsage:   "<noteID>",
+		Action:      a.showAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+			},
+			cli.IntFlag{
+				Name:  "rev",
+				Usage: "show the body as of revision `REV`. If unspecified, will show the current revision",
+			},
+			cli.BoolFlag{
+				Name:  "with-meta, m",
+				Usage: "print the note's meta information before its body",
+			},
+			cli.BoolFlag{
+				Name:  "render",
+				Usage: "style markdown with terminal escape sequences",
+			},
+			cli.BoolFlag{
+				Name:  "raw",
+				Usage: "print the body exactly as stored, without meta information, styling, or paging",
+			},
+			cli.StringFlag{
+				Name:   "pager",
+				Usage:  "pager command used when printing to a terminal",
+				Value:  defaultPager,
+				EnvVar: "PAGER",
+			},
+			cli.BoolFlag{
+				Name:  "no-pager",
+				Usage: "don't pipe output through the pager",
+			},
+		},
+	}
+}
+
+func (a *App) showAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	opCtx, err := a.operationsContext(logger)
+	if err != nil {
+		return err
+	}
+
+	options := operations.ShowNoteOptions{
+		Revision:       ctx.Int("rev"),
+		FollowForwards: true,
+	}
+
+	_, shown, err := operations.ShowNote(opCtx, options, noteID)
+	if err != nil {
+		return fmt.Errorf("show note: %w", err)
+	}
+
+	body := shown.Body
+	if ctx.Bool("raw") {
+		_, err = io.WriteString(ctx.App.Writer, body)
+		return err
+	}
+
+	var buf bytes.Buffer
+	if ctx.Bool("with-meta") {
+		writeRows(&buf, showMetaRows(shown))
+		buf.WriteString("\n")
+	}
+
+	if ctx.Bool("render") {
+		body = markdown.Render(body)
+	}
+	buf.WriteString(body)
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		buf.WriteString("\n")
+	}
+
+	if ctx.Bool("no-pager") || ctx.App.Writer != os.Stdout || !stdoutIsTerminal() {
+		_, err = buf.WriteTo(ctx.App.Writer)
+		return err
+	}
+
+	err = page(ctx.String("pager"), &buf)
+	if errors.Is(err, exec.ErrNotFound) {
+		logger.Debug("pager not found", zap.String("pager", ctx.String("pager")))
+		_, err = buf.WriteTo(ctx.App.Writer)
+	}
+	return err
+}
+
+// showMetaRows gets the meta information printed above a note body. The
+// updated time and size are those of the revision being shown
+func showMetaRows(shown operations.ShownNote) [][]string {
+	note := shown.Note
+	rows := [][]string{
+		{"notebook", shown.Notebook},
+		{"id", fmt.Sprintf("%x", note.Meta.ID)},
+		{"title", note.Meta.Title},
+		{"created", note.Meta.Created.Format(time.RFC3339)},
+	}
+
+	if revision := shown.Revision; revision.Number != 0 {
+		rows = append(rows,
+			[]string{"updated", revision.Updated.Format(time.RFC3339)},
+			[]string{"revision", fmt.Sprintf("%d of %d", revision.Number, note.CurrentRevision())},
+			[]string{"size", strconv.Itoa(revision.Size) + " bytes"},
+		)
+	}
+
+	if note.Meta.IsDeleted() {
+		rows = append(rows, []string{"deleted", note.Meta.Deleted.Format(time.RFC3339)})
+	}
+
+	if len(note.Meta.Tags) > 0 {
+		rows = append(rows, []string{"tags", strings.Join(note.Meta.Tags, ", ")})
+	}
+
+	return rows
+}
+
+// stdoutIsTerminal determines whether stdout is a terminal rather than a pipe
+// or file
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// page runs the provided pager command with r as its input. The command may
+// include arguments, as $PAGER often does
+func page(pager string, r io.Reader) error {
+	args := strings.Fields(pager)
+	if len(args) == 0 {
+		return fmt.Errorf("pager command: %w", exec.ErrNotFound)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS="+defaultLessOptions)
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("run pager command: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/subtlepseudonym/notes"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+func (a *App) buildTagCommand() cli.Command {
+	notebookFlag := cli.StringFlag{
+		Name:  "notebook",
+		Usage: "specify which notebook to use. If unspecified, will use the default notebook",
+	}
+
+	return cli.Command{
+		Name:        "tag",
+		Usage:       "access note tag subcommands",
+		Description: "Add, remove, and list the tags used to categorize notes within a notebook",
+		Subcommands: []cli.Command{
+			{
+				Name:      "add",
+				Usage:     "add tags to a note",
+				ArgsUsage: "<noteID> <tag>...",
+				Action:    a.addTagsAction,
+				Flags:     []cli.Flag{notebookFlag},
+			},
+			{
+				Name:      "rm",
+				Usage:     "remove tags from a note",
+				ArgsUsage: "<noteID> <tag>...",
+				Action:    a.removeTagsAction,
+				Flags:     []cli.Flag{notebookFlag},
+			},
+			{
+				Name:        "ls",
+				Usage:       "list tags",
+				Description: "List the tags on the note specified by <noteID>. If no argument is provided, list every tag in the notebook with the number of notes using it",
+				ArgsUsage:   "[<noteID>]",
+				Action:      a.listTagsAction,
+				Flags: []cli.Flag{
+					notebookFlag,
+					cli.BoolFlag{
+						Name:  "deleted, d",
+						Usage: "include soft deleted notes in tag counts",
+					},
+				},
+			},
+		},
+	}
+}
+
+// parseTags validates the provided tags, trimming surrounding whitespace
+func parseTags(args []string) ([]string, error) {
+	var tags []string
+	for _, arg := range args {
+		tag := strings.TrimSpace(arg)
+		if tag == "" {
+			return nil, fmt.Errorf("tag cannot be blank string")
+		}
+		if strings.IndexFunc(tag, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("tag %q cannot contain whitespace", tag)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+func (a *App) addTagsAction(ctx *cli.Context) error {
+	return a.updateTags(ctx, func(meta *notes.NoteMeta, tags []string) {
+		meta.AddTags(tags...)
+	})
+}
+
+func (a *App) removeTagsAction(ctx *cli.Context) error {
+	return a.updateTags(ctx, func(meta *notes.NoteMeta, tags []string) {
+		meta.RemoveTags(tags...)
+	})
+}
+
+// updateTags applies fn to the meta of the note specified by the first
+// argument, using the remaining arguments as tags
+func (a *App) updateTags(ctx *cli.Context, fn func(*notes.NoteMeta, []string)) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	noteID, restoreForward, err := a.followForward(noteID, logger)
+	if err != nil {
+		return err
+	}
+	defer restoreForward()
+
+	tags, err := parseTags(ctx.Args().Tail())
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return fmt.Errorf("usage: at least one tag argument required")
+	}
+
+	note, err := a.data.GetNote(noteID)
+	if err != nil {
+		return fmt.Errorf("get note: %w", err)
+	}
+
+	fn(&note.Meta, tags)
+	err = a.data.SaveNote(note)
+	if err != nil {
+		return fmt.Errorf("save note: %w", err)
+	}
+	logger.Info("note tags updated", zap.Int("noteID", note.Meta.ID), zap.Strings("tags", note.Meta.Tags))
+
+	return nil
+}
+
+func (a *App) listTagsAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if ctx.Args().Present() {
+		noteID, err := parseNoteID(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		noteID, restoreForward, err := a.followForward(noteID, logger)
+		if err != nil {
+			return err
+		}
+		defer restoreForward()
+
+		meta, err := a.data.GetNoteMeta(noteID)
+		if err != nil {
+			return fmt.Errorf("get note meta: %w", err)
+		}
+
+		for _, tag := range meta.Tags {
+			fmt.Fprintln(ctx.App.Writer, tag)
+		}
+		return nil
+	}
+
+	index, err := a.data.GetAllNoteMetas()
+	if err != nil {
+		return fmt.Errorf("get note metas: %w", err)
+	}
+
+	if !ctx.Bool("deleted") {
+		filtered := make(map[int]notes.NoteMeta, len(index))
+		for id, meta := range index {
+			if !meta.IsDeleted() {
+				filtered[id] = meta
+			}
+		}
+		index = filtered
+	}
+
+	counts := notes.TagCounts(index)
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	rows := make([][]string, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, []string{tag, strconv.Itoa(counts[tag])})
+	}
+
+	printRows(ctx, rows)
+	return nil
+}
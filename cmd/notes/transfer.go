@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/subtlepseudonym/notes/operations"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+func (a *App) buildMoveCommand() cli.Command {
+	return cli.Command{
+		Name:        "mv",
+		Usage:       "move a note to another notebook",
+		Description: "Move the note specified by <noteID> to <notebook>, where it's given a new ID. Its creation time, edit history, and revisions are kept. The old ID is forwarded to the new one, so commands given the old ID act on the moved note",
+		ArgsUsage:   "<noteID> <notebook>",
+		Action:      a.moveAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to move the note from. If unspecified, will use the current notebook",
+			},
+		},
+	}
+}
+
+func (a *App) buildCopyCommand() cli.Command {
+	return cli.Command{
+		Name:        "cp",
+		Usage:       "copy a note to another notebook",
+		Description: "Copy the note specified by <noteID> to <notebook>, where it's given a new ID. Its creation time, edit history, and revisions are kept",
+		ArgsUsage:   "<noteID> <notebook>",
+		Action:      a.copyAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to copy the note from. If unspecified, will use the current notebook",
+			},
+		},
+	}
+}
+
+func (a *App) moveAction(ctx *cli.Context) error {
+	return a.transferNote(ctx, true)
+}
+
+func (a *App) copyAction(ctx *cli.Context) error {
+	return a.transferNote(ctx, false)
+}
+
+// transferNote copies, or moves, the note given by the first argument to the
+// notebook given by the second
+func (a *App) transferNote(ctx *cli.Context, move bool) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	if ctx.NArg() < 2 {
+		return fmt.Errorf("usage: notebook argument required")
+	}
+	notebook := ctx.Args().Get(1)
+
+	meta, err := a.data.GetMeta()
+	if err != nil {
+		return fmt.Errorf("get meta: %w", err)
+	}
+
+	opCtx := &operations.Context{
+		Meta:   meta,
+		DAL:    a.data,
+		Logger: logger,
+	}
+
+	options := operations.TransferNoteOptions{
+		Notebook: notebook,
+		Move:     move,
+	}
+
+	_, newID, err := operations.TransferNote(opCtx, options, noteID)
+	if err != nil {
+		return fmt.Errorf("%s note: %w", ctx.Command.Name, err)
+	}
+
+	// the in-memory meta would otherwise overwrite the forward when next saved
+	err = a.reloadMeta()
+	if err != nil {
+		return err
+	}
+
+	logger.Info(
+		"note transferred",
+		zap.Int("noteID", noteID),
+		zap.String("notebook", notebook),
+		zap.Int("newNoteID", newID),
+		zap.Bool("move", move),
+	)
+	fmt.Fprintf(ctx.App.Writer, "%x\n", newID)
+
+	return nil
+}
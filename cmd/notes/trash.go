@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/operations"
+
+	"github.com/urfave/cli"
+	"go.uber.org/zap"
+)
+
+// collectedNote is a soft-deleted note removed, or selected for removal, by
+// garbage collection
+type collectedNote struct {
+	notebook string
+	meta     notes.NoteMeta
+}
+
+func (a *App) buildRestoreCommand() cli.Command {
+	return cli.Command{
+		Name:      "restore",
+		Usage:     "restore a soft deleted note",
+		ArgsUsage: "<noteID>",
+		Action:    a.restoreAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "specify which notebook to use. If unspecified, will use the current notebook",
+			},
+		},
+	}
+}
+
+func (a *App) buildTrashCommand() cli.Command {
+	notebookFlag := cli.StringFlag{
+		Name:  "notebook",
+		Usage: "specify which notebook to use. If unspecified, will use the current notebook",
+	}
+
+	return cli.Command{
+		Name:        "trash",
+		Usage:       "access soft deleted note subcommands",
+		Description: "List soft deleted notes or remove them permanently. Notes are soft deleted by rm and restored by restore",
+		Subcommands: []cli.Command{
+			{
+				Name:    "list",
+				Aliases: []string{"ls"},
+				Usage:   "list soft deleted notes",
+				Action:  a.trashListAction,
+				Flags: []cli.Flag{
+					notebookFlag,
+					cli.StringFlag{
+						Name:  "time-format",
+						Usage: "format to display timestamps in",
+						Value: defaultListTimeFormat,
+					},
+					cli.StringFlag{
+						Name:  "delimiter",
+						Usage: "list column delimiter",
+						Value: defaultListColumnDelimiter,
+					},
+				},
+			},
+			{
+				Name:   "empty",
+				Usage:  "permanently remove every soft deleted note",
+				Action: a.trashEmptyAction,
+				Flags: []cli.Flag{
+					notebookFlag,
+					cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "list the notes which would be removed without removing them",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (a *App) buildGCCommand() cli.Command {
+	return cli.Command{
+		Name:        "gc",
+		Usage:       "permanently remove notes deleted longer ago than the retention period",
+		Description: "Permanently remove notes in every notebook which have been soft deleted for longer than the trash-retention flag. When the flag is set, this also happens at startup, skipping encrypted notebooks",
+		Action:      a.gcAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "notebook",
+				Usage: "only remove notes from `NOTEBOOK`",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "list the notes which would be removed without removing them",
+			},
+		},
+	}
+}
+
+func (a *App) restoreAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	noteID, err := parseNoteID(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	noteID, restoreForward, err := a.followForward(noteID, logger)
+	if err != nil {
+		return err
+	}
+	defer restoreForward()
+
+	opCtx := &operations.Context{
+		Meta:   a.meta,
+		DAL:    a.data,
+		Logger: logger,
+	}
+
+	_, err = operations.RestoreNote(opCtx, noteID)
+	if err != nil {
+		return fmt.Errorf("restore note: %w", err)
+	}
+	logger.Info("note restored", zap.Int("noteID", noteID), zap.String("notebook", a.data.GetNotebook()))
+
+	return nil
+}
+
+func (a *App) trashListAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	opCtx := &operations.Context{
+		Meta:   a.meta,
+		DAL:    a.data,
+		Logger: logger,
+	}
+
+	trashed, err := operations.TrashedNotes(opCtx)
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range trashed {
+		fields := []string{
+			fmt.Sprintf(" %x", meta.ID),
+			meta.Deleted.UTC().Format(ctx.String("time-format")),
+			meta.Title,
+		}
+		fmt.Fprintln(ctx.App.Writer, strings.Join(fields, ctx.String("delimiter")))
+	}
+
+	return nil
+}
+
+func (a *App) trashEmptyAction(ctx *cli.Context) error {
+	logger := a.logger.Named(a.data.GetNotebook()).Named(ctx.Command.Name)
+
+	restore, err := a.useNotebookFlag(ctx, logger)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	options := operations.PurgeNotesOptions{
+		DryRun: ctx.Bool("dry-run"),
+	}
+
+	collected, err := a.collectGarbage([]string{a.data.GetNotebook()}, options, logger)
+	printCollected(ctx, collected, options.DryRun)
+	return err
+}
+
+func (a *App) gcAction(ctx *cli.Context) error {
+	logger := a.logger.Named(ctx.Command.Name)
+
+	retention := ctx.GlobalDuration("trash-retention")
+	if retention <= 0 {
+		return fmt.Errorf("trash-retention is not set, use trash empty to remove every soft deleted note")
+	}
+
+	notebooks := a.data.GetAllNotebooks()
+	if ctx.String("notebook") != "" {
+		notebooks = []string{ctx.String("notebook")}
+	}
+
+	options := operations.PurgeNotesOptions{
+		DeletedBefore: time.Now().Add(-retention),
+		DryRun:        ctx.Bool("dry-run"),
+	}
+
+	collected, err := a.collectGarbage(notebooks, options, logger)
+	printCollected(ctx, collected, options.DryRun)
+	return err
+}
+
+// autoCollectGarbage removes notes which have outlived the retention period,
+// if one is set. It doesn't run before commands which manage deleted notes,
+// so that a dry run or restore isn't preempted, and skips encrypted notebooks
+// so that starting notes doesn't prompt for a passphrase. Collecting garbage
+// loads every notebook's index, so it runs at most once per interval
+func (a *App) autoCollectGarbage(ctx *cli.Context) {
+	retention := ctx.GlobalDuration("trash-retention")
+	if retention <= 0 {
+		return
+	}
+
+	switch ctx.Args().First() {
+	case "gc", "trash", "restore":
+		return
+	}
+
+	logger := a.logger.Named("gc")
+
+	last, err := a.lastGarbageCollection()
+	if err != nil {
+		logger.Warn("read last garbage collection", zap.Error(err))
+	}
+	if time.Since(last) < defaultGCInterval {
+		return
+	}
+
+	err = os.WriteFile(a.storage.gc, []byte(time.Now().Format(time.RFC3339)+"\n"), 0644)
+	if err != nil {
+		logger.Error("save last garbage collection", zap.Error(err))
+	}
+
+	var notebooks []string
+	for _, notebook := range a.data.GetAllNotebooks() {
+		if a.crypt != nil {
+			encrypted, err := a.crypt.IsEncrypted(notebook)
+			if err != nil || encrypted {
+				continue
+			}
+		}
+		notebooks = append(notebooks, notebook)
+	}
+
+	options := operations.PurgeNotesOptions{
+		DeletedBefore: time.Now().Add(-retention),
+	}
+
+	collected, err := a.collectGarbage(notebooks, options, logger)
+	if err != nil {
+		logger.Error("collect garbage", zap.Error(err))
+	}
+	if len(collected) > 0 {
+		logger.Info("removed expired notes", zap.Int("count", len(collected)), zap.Duration("retention", retention))
+	}
+}
+
+// lastGarbageCollection gets when garbage was last collected at startup,
+// which is the zero time if it never has been
+func (a *App) lastGarbageCollection() (time.Time, error) {
+	b, err := os.ReadFile(a.storage.gc)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("read file: %w", err)
+	}
+
+	last, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse time: %w", err)
+	}
+	return last, nil
+}
+
+// collectGarbage purges soft-deleted notes from each of the provided
+// notebooks, returning to the current notebook afterward
+func (a *App) collectGarbage(notebooks []string, options operations.PurgeNotesOptions, logger *zap.Logger) ([]collectedNote, error) {
+	current := a.data.GetNotebook()
+	defer func() {
+		a.data.SetNotebook(current)
+
+		err := a.reloadMeta()
+		if err != nil {
+			logger.Error("reload meta", zap.Error(err))
+		}
+	}()
+
+	var collected []collectedNote
+	for _, notebook := range notebooks {
+		err := a.data.SetNotebook(notebook)
+		if err != nil {
+			return collected, fmt.Errorf("set notebook: %w", err)
+		}
+
+		opCtx := &operations.Context{
+			DAL:    a.data,
+			Logger: logger.With(zap.String("notebook", notebook)),
+		}
+
+		_, purged, err := operations.PurgeNotes(opCtx, options)
+		for _, meta := range purged {
+			collected = append(collected, collectedNote{notebook: notebook, meta: meta})
+		}
+		if err != nil {
+			return collected, fmt.Errorf("purge notebook %q: %w", notebook, err)
+		}
+	}
+
+	return collected, nil
+}
+
+func printCollected(ctx *cli.Context, collected []collectedNote, dryRun bool) {
+	for _, note := range collected {
+		fields := []string{
+			note.notebook,
+			fmt.Sprintf("%x", note.meta.ID),
+			note.meta.Title,
+		}
+		fmt.Fprintln(ctx.App.Writer, strings.Join(fields, defaultListColumnDelimiter))
+	}
+
+	noun := "notes"
+	if len(collected) == 1 {
+		noun = "note"
+	}
+
+	if dryRun {
+		fmt.Fprintf(ctx.App.Writer, "%d %s would be removed\n", len(collected), noun)
+	} else {
+		fmt.Fprintf(ctx.App.Writer, "removed %d %s\n", len(collected), noun)
+	}
+}
@@ -0,0 +1,236 @@
+// Package config reads and edits the YAML file holding default values for
+// command line flags. Values are addressed by key paths, such as
+// []string{"ls", "limit"}, and are always read and written as strings so that
+// they can be parsed by the flag they set
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Entry is a single value in the config
+type Entry struct {
+	Key   []string
+	Value string
+}
+
+// Config is a YAML document of nested mappings. Editing the document, rather
+// than a decoded copy of it, preserves comments and ordering when it's saved
+type Config struct {
+	Path string
+	doc  *yaml.Node
+	root *yaml.Node // top level mapping
+}
+
+// Load reads the config file at path. A missing file is loaded as an empty
+// config, which is created when saved
+func Load(path string) (*Config, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	c := &Config{
+		Path: path,
+		doc:  &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}},
+		root: root,
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("decode config %q: %w", path, err)
+	}
+
+	if len(doc.Content) > 0 {
+		if doc.Content[0].Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("decode config %q: top level must be a mapping", path)
+		}
+		c.doc, c.root = &doc, doc.Content[0]
+	}
+
+	return c, nil
+}
+
+// SplitKey splits a dotted key, such as "ls.limit", into a key path
+func SplitKey(key string) []string {
+	return strings.Split(key, ".")
+}
+
+// lookup finds the value node for the key in the mapping, returning its
+// index in the mapping's content or -1 if it isn't present
+func lookup(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// Get gets the value at key, reporting whether a value is set there. Sections
+// aren't values
+func (c *Config) Get(key []string) (string, bool) {
+	node := c.root
+	for _, k := range key {
+		if node.Kind != yaml.MappingNode {
+			return "", false
+		}
+
+		i := lookup(node, k)
+		if i < 0 {
+			return "", false
+		}
+		node = node.Content[i]
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return node.Value, true
+}
+
+// Set sets the value at key, creating sections as necessary
+func (c *Config) Set(key []string, value string) error {
+	node := c.root
+	for depth, k := range key {
+		if k == "" {
+			return fmt.Errorf("key %q contains an empty section", strings.Join(key, "."))
+		}
+
+		last := depth == len(key)-1
+		i := lookup(node, k)
+		if i < 0 {
+			child := &yaml.Node{Kind: yaml.MappingNode}
+			if last {
+				child = &yaml.Node{Kind: yaml.ScalarNode}
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, child)
+			i = len(node.Content) - 1
+		}
+
+		child := node.Content[i]
+		switch {
+		case last && child.Kind != yaml.ScalarNode:
+			return fmt.Errorf("key %q is a section, not a value", strings.Join(key, "."))
+		case !last && child.Kind != yaml.MappingNode:
+			return fmt.Errorf("key %q is a value, not a section", strings.Join(key[:depth+1], "."))
+		}
+		node = child
+	}
+
+	node.Value = value
+	node.Tag = ""
+	node.Style = 0
+
+	return nil
+}
+
+// Unset removes the value or section at key, reporting whether it was
+// present. Sections left empty are removed too
+func (c *Config) Unset(key []string) bool {
+	return unset(c.root, key)
+}
+
+func unset(mapping *yaml.Node, key []string) bool {
+	if mapping.Kind != yaml.MappingNode || len(key) == 0 {
+		return false
+	}
+
+	i := lookup(mapping, key[0])
+	if i < 0 {
+		return false
+	}
+
+	if len(key) > 1 {
+		child := mapping.Content[i]
+		if !unset(child, key[1:]) {
+			return false
+		}
+		if len(child.Content) > 0 {
+			return true
+		}
+	}
+
+	mapping.Content = append(mapping.Content[:i-1], mapping.Content[i+1:]...)
+	return true
+}
+
+// Entries lists every value in the config, in file order
+func (c *Config) Entries() []Entry {
+	var entries []Entry
+	var walk func(node *yaml.Node, prefix []string)
+	walk = func(node *yaml.Node, prefix []string) {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := append(append([]string{}, prefix...), node.Content[i].Value)
+			value := node.Content[i+1]
+
+			switch value.Kind {
+			case yaml.MappingNode:
+				walk(value, key)
+			case yaml.ScalarNode:
+				entries = append(entries, Entry{Key: key, Value: value.Value})
+			}
+		}
+	}
+	walk(c.root, nil)
+
+	return entries
+}
+
+// Save writes the config to its path, creating the containing directory if
+// necessary. The file is replaced atomically so that a failed write can't
+// truncate it
+func (c *Config) Save() error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	err := encoder.Encode(c.doc)
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	err = encoder.Close()
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+
+	dir := filepath.Dir(c.Path)
+	err = os.MkdirAll(dir, os.ModeDir|os.FileMode(0700))
+	if err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(c.Path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temporary config file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(buf.Bytes())
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+
+	err = os.Rename(file.Name(), c.Path)
+	if err != nil {
+		return fmt.Errorf("replace config: %w", err)
+	}
+	return nil
+}
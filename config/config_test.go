@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	filename := path.Join(t.TempDir(), "notes", "config.yaml")
+
+	c, err := Load(filename)
+	if err != nil {
+		t.Fatalf("load missing config: %s", err)
+	}
+
+	// set in order, as entries are listed in the order they were added
+	for _, setting := range []struct {
+		key, value string
+	}{
+		{"editor", "nvim"},
+		{"ls.limit", "20"},
+		{"notebooks.work.editor", "code --wait"},
+		{"notebooks.work.ls.limit", "5"},
+	} {
+		err = c.Set(SplitKey(setting.key), setting.value)
+		if err != nil {
+			t.Fatalf("set %s: %s", setting.key, err)
+		}
+	}
+
+	err = c.Save()
+	if err != nil {
+		t.Fatalf("save: %s", err)
+	}
+
+	c, err = Load(filename)
+	if err != nil {
+		t.Fatalf("load: %s", err)
+	}
+
+	value, ok := c.Get([]string{"notebooks", "work", "ls", "limit"})
+	if !ok || value != "5" {
+		t.Errorf("expected 5, got %q (set: %t)", value, ok)
+	}
+
+	if _, ok := c.Get([]string{"ls"}); ok {
+		t.Errorf("expected section not to be reported as a value")
+	}
+
+	if err := c.Set([]string{"ls"}, "1"); err == nil {
+		t.Errorf("expected error setting a section to a value")
+	}
+	if err := c.Set([]string{"editor", "x"}, "1"); err == nil {
+		t.Errorf("expected error setting a key under a value")
+	}
+
+	if !c.Unset([]string{"notebooks", "work", "ls", "limit"}) {
+		t.Fatalf("expected key to be unset")
+	}
+
+	var keys []string
+	for _, entry := range c.Entries() {
+		keys = append(keys, strings.Join(entry.Key, "."))
+	}
+	expected := "editor ls.limit notebooks.work.editor"
+	if strings.Join(keys, " ") != expected {
+		t.Errorf("expected keys %q, got %q", expected, strings.Join(keys, " "))
+	}
+}
+
+func TestConfigPreservesComments(t *testing.T) {
+	filename := path.Join(t.TempDir(), "config")
+	original := "# defaults for notes\neditor: vim # the editor\n"
+
+	err := os.WriteFile(filename, []byte(original), 0644)
+	if err != nil {
+		t.Fatalf("write config: %s", err)
+	}
+
+	c, err := Load(filename)
+	if err != nil {
+		t.Fatalf("load: %s", err)
+	}
+
+	err = c.Set([]string{"editor"}, "nvim")
+	if err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	err = c.Save()
+	if err != nil {
+		t.Fatalf("save: %s", err)
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("read config: %s", err)
+	}
+
+	expected := "# defaults for notes\neditor: nvim # the editor\n"
+	if string(b) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b)
+	}
+}
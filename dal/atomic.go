@@ -0,0 +1,146 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+const (
+	defaultFileMode  = os.FileMode(0644)
+	tempFileInfix    = ".tmp-"
+	backupFileSuffix = ".bak"
+)
+
+// WriteFileAtomic replaces the contents of the file at filePath with data.
+// The data is written and synced to a temporary file in the same directory,
+// which is then renamed over the original before the directory itself is
+// synced. If the process dies partway through, the file holds either its old
+// or new contents, and a hidden temporary file may be left behind. Those left
+// in notebook directories are removed by reconcileNotebook
+func WriteFileAtomic(filePath string, data []byte) error {
+	directory := path.Dir(filePath)
+	tempFile, err := os.CreateTemp(directory, "."+path.Base(filePath)+tempFileInfix+"*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Chmod(defaultFileMode)
+	}
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("write temporary file: %w", err)
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("close temporary file: %w", err)
+	}
+
+	err = os.Rename(tempPath, filePath)
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("rename temporary file: %w", err)
+	}
+
+	err = syncDirectory(directory)
+	if err != nil {
+		return fmt.Errorf("sync directory: %w", err)
+	}
+	return nil
+}
+
+// writeJSONAtomic encodes v as JSON and writes it with WriteFileAtomic
+func writeJSONAtomic(filePath string, v interface{}) error {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	return WriteFileAtomic(filePath, buf.Bytes())
+}
+
+// syncDirectory flushes the directory entry changes made by a rename to disk.
+// Windows doesn't support syncing directories, and doesn't need it to make
+// renames durable
+func syncDirectory(directory string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+
+	err = dir.Sync()
+	if err != nil {
+		dir.Close()
+		return err
+	}
+	return dir.Close()
+}
+
+// reconcileNotebook cleans up after writes interrupted by a crash. Temporary
+// files never replaced their originals, so they're removed. Meta and index
+// backup files are left by versions which moved the original aside before
+// rewriting it; the backup is restored if the original is missing or isn't
+// valid JSON, and removed otherwise. The caller must hold the notebook's lock
+// so that writes in progress elsewhere aren't mistaken for interrupted ones
+func reconcileNotebook(notebookPath string) error {
+	entries, err := os.ReadDir(notebookPath)
+	if err != nil {
+		return fmt.Errorf("read notebook directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		switch {
+		case IsHidden(name) && strings.Contains(name, tempFileInfix):
+			err = os.Remove(path.Join(notebookPath, name))
+			if err != nil {
+				return fmt.Errorf("remove temporary file %q: %w", name, err)
+			}
+		case name == defaultMetaFilename+backupFileSuffix || name == defaultIndexFilename+backupFileSuffix:
+			err = reconcileBackup(path.Join(notebookPath, strings.TrimSuffix(name, backupFileSuffix)))
+			if err != nil {
+				return fmt.Errorf("reconcile backup %q: %w", name, err)
+			}
+		}
+	}
+
+	return syncDirectory(notebookPath)
+}
+
+// reconcileBackup either restores or removes the backup of the file at
+// filePath
+func reconcileBackup(filePath string) error {
+	backupPath := filePath + backupFileSuffix
+	b, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read original: %w", err)
+	}
+
+	if err == nil && json.Valid(b) {
+		return os.Remove(backupPath)
+	}
+
+	return os.Rename(backupPath, filePath)
+}
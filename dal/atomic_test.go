@@ -0,0 +1,83 @@
+package dal
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "meta")
+
+	for _, data := range []string{"first\n", "second\n"} {
+		err := WriteFileAtomic(filePath, []byte(data))
+		if err != nil {
+			t.Fatalf("write file: %s", err)
+		}
+
+		b, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatalf("read file: %s", err)
+		}
+		if string(b) != data {
+			t.Errorf("file contains %q, expected %q", b, data)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %s", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory contains %d files, expected only the written file", len(entries))
+	}
+}
+
+func TestReconcileNotebook(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"meta":                            `{"version":"new"}`,
+		"meta.bak":                        `{"version":"old"}`,
+		"index":                           `{"1":{"id":`, // truncated by a crash
+		"index.bak":                       `{}`,
+		".000001" + tempFileInfix + "123": `{"meta":`,
+		"index.rebuild.bak":               `{}`,
+	}
+	for name, contents := range files {
+		err := os.WriteFile(path.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatalf("write %s: %s", name, err)
+		}
+	}
+
+	err := reconcileNotebook(dir)
+	if err != nil {
+		t.Fatalf("reconcile notebook: %s", err)
+	}
+
+	expected := map[string]string{
+		"meta":              `{"version":"new"}`,
+		"index":             `{}`,
+		"index.rebuild.bak": `{}`,
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %s", err)
+	}
+	if len(entries) != len(expected) {
+		t.Errorf("directory contains %d files, expected %d", len(entries), len(expected))
+	}
+
+	for name, contents := range expected {
+		b, err := os.ReadFile(path.Join(dir, name))
+		if err != nil {
+			t.Errorf("read %s: %s", name, err)
+			continue
+		}
+		if string(b) != contents {
+			t.Errorf("%s contains %q, expected %q", name, b, contents)
+		}
+	}
+}
@@ -1,29 +1,327 @@
 package cache
 
 import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
 	"github.com/subtlepseudonym/notes/dal"
 )
 
 type CacheType int
 
 const (
-	Noop CacheType = iota
-	LRU            // least recently used
-	RR             // random replacement
+	Noop     CacheType = iota
+	LRU                // least recently used
+	RR                 // random replacement
+	LFU                // least frequently used
+	TwoQueue           // 2Q, which favors notes read more than once over notes read once
 )
 
+// String gets the name of the cache type, as used by the cache flag
+func (t CacheType) String() string {
+	switch t {
+	case LRU:
+		return "lru"
+	case RR:
+		return "rr"
+	case LFU:
+		return "lfu"
+	case TwoQueue:
+		return "2q"
+	default:
+		return "noop"
+	}
+}
+
 type NoteCache interface {
 	dal.DAL
 	Flush() error
+	Stats() Stats
+}
+
+// Stats describe how effective a cache has been and what it holds. Counts
+// are kept from when the cache was created
+type Stats struct {
+	Policy        string `json:"policy"`
+	Hits          int    `json:"hits"`
+	Misses        int    `json:"misses"`
+	Evictions     int    `json:"evictions"`     // notes removed to make room for others
+	Expirations   int    `json:"expirations"`   // notes removed because they outlived the TTL
+	Invalidations int    `json:"invalidations"` // notes removed because they were saved or removed
+
+	Entries int `json:"entries"`
+	Bytes   int `json:"bytes"` // total size of cached note bodies
+
+	Capacity int           `json:"capacity,omitempty"`
+	MaxBytes int           `json:"maxBytes,omitempty"`
+	TTL      time.Duration `json:"ttl,omitempty"`
+}
+
+// HitRate gets the fraction of reads which were served from the cache
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Options bound the notes held by a cache. Limits which are zero aren't
+// enforced
+type Options struct {
+	Capacity int           // maximum number of notes
+	MaxBytes int           // maximum total size of note bodies, in bytes
+	TTL      time.Duration // time after which a cached note is read again
 }
 
 func NewNoteCache(d dal.DAL, cacheType CacheType, capacity int) NoteCache {
+	return NewNoteCacheWithOptions(d, cacheType, Options{Capacity: capacity})
+}
+
+func NewNoteCacheWithOptions(d dal.DAL, cacheType CacheType, options Options) NoteCache {
 	switch cacheType {
 	case LRU:
-		return NewLeastRecentlyUsed(d, capacity)
+		return newCache(d, cacheType, newLRU(), options)
 	case RR:
-		return NewRandomReplacement(d, capacity)
+		return newCache(d, cacheType, newRR(), options)
+	case LFU:
+		return newCache(d, cacheType, newLFU(), options)
+	case TwoQueue:
+		return newCache(d, cacheType, newTwoQueue(), options)
 	default:
 		return NewNoop(d)
 	}
 }
+
+// key identifies a cached note. Note IDs are only unique within a notebook
+type key struct {
+	notebook string
+	id       int
+}
+
+// policy decides which note is evicted when a cache is full. Policies aren't
+// safe for concurrent use, so their methods are only called with the cache's
+// lock held
+type policy interface {
+	get(k key) (*notes.Note, bool)
+	add(k key, note *notes.Note) // k is never already present
+	remove(k key)
+	evict() (key, bool) // remove the note the policy would replace next
+	flush()
+}
+
+// entry is the cache's record of a note held by its policy
+type entry struct {
+	size  int // body size in bytes
+	added time.Time
+}
+
+// cache wraps a DAL, keeping the notes it returns in a policy. Notes are
+// copied in and out of the cache so that callers modifying a note can't
+// change the cached copy, and notes are invalidated when they're saved or
+// removed, so the cache behaves as the DAL it wraps would
+type cache struct {
+	dal.DAL
+	mu        sync.Mutex
+	cacheType CacheType
+	policy    policy
+	options   Options
+	entries   map[key]entry
+	bytes     int   // total size of cached note bodies
+	stats     Stats // counts only, the rest is filled in by Stats
+	now       func() time.Time
+}
+
+func newCache(d dal.DAL, cacheType CacheType, p policy, options Options) *cache {
+	return &cache{
+		DAL:       d,
+		cacheType: cacheType,
+		policy:    p,
+		options:   options,
+		entries:   make(map[key]entry),
+		now:       time.Now,
+	}
+}
+
+func (c *cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Policy = c.cacheType.String()
+	stats.Entries = len(c.entries)
+	stats.Bytes = c.bytes
+	stats.Capacity = c.options.Capacity
+	stats.MaxBytes = c.options.MaxBytes
+	stats.TTL = c.options.TTL
+
+	return stats
+}
+
+func (c *cache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.policy.flush()
+	c.entries = make(map[key]entry)
+	c.bytes = 0
+	return nil
+}
+
+// ReserveNoteID reserves the next note ID with the underlying DAL. Metas
+// aren't cached
+func (c *cache) ReserveNoteID() (*notes.Meta, error) {
+	return dal.ReserveNoteID(c.DAL)
+}
+
+// UpdateMeta updates the meta with the underlying DAL
+func (c *cache) UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error) {
+	return dal.UpdateMeta(c.DAL, update)
+}
+
+func (c *cache) GetNote(id int) (*notes.Note, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := key{notebook: c.DAL.GetNotebook(), id: id}
+	if e, exists := c.entries[k]; exists && c.options.TTL > 0 && c.now().Sub(e.added) >= c.options.TTL {
+		c.remove(k)
+		c.stats.Expirations++
+	}
+	if cached, exists := c.policy.get(k); exists {
+		c.stats.Hits++
+		return clone(cached), nil
+	}
+	c.stats.Misses++
+
+	note, err := c.DAL.GetNote(id)
+	if err != nil {
+		return nil, fmt.Errorf("cache miss: %w", err)
+	}
+
+	c.add(k, clone(note))
+	return note, nil
+}
+
+func (c *cache) SaveNote(note *notes.Note) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the note is invalidated even if saving fails, as it may have been
+	// partially written
+	c.invalidate(key{notebook: c.DAL.GetNotebook(), id: note.Meta.ID})
+	return c.DAL.SaveNote(note)
+}
+
+func (c *cache) RemoveNote(id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidate(key{notebook: c.DAL.GetNotebook(), id: id})
+	return c.DAL.RemoveNote(id)
+}
+
+// SetNotebook is locked so that the notebook can't change between a note's
+// key being computed and the note being read
+func (c *cache) SetNotebook(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.DAL.SetNotebook(name)
+}
+
+func (c *cache) RenameNotebook(oldName, newName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeNotebook(oldName)
+	c.removeNotebook(newName)
+	return c.DAL.RenameNotebook(oldName, newName)
+}
+
+func (c *cache) RemoveNotebook(name string, force bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeNotebook(name)
+	return c.DAL.RemoveNotebook(name, force)
+}
+
+// add caches the note, first evicting notes until it fits within the
+// cache's limits. Notes larger than the byte limit aren't cached
+func (c *cache) add(k key, note *notes.Note) {
+	size := len(note.Body)
+	if c.options.MaxBytes > 0 && size > c.options.MaxBytes {
+		return
+	}
+
+	for len(c.entries) > 0 && c.full(size) {
+		evicted, ok := c.policy.evict()
+		if !ok {
+			break
+		}
+		c.bytes -= c.entries[evicted].size
+		delete(c.entries, evicted)
+		c.stats.Evictions++
+	}
+
+	c.policy.add(k, note)
+	c.entries[k] = entry{size: size, added: c.now()}
+	c.bytes += size
+}
+
+// full determines whether a note of the provided size would exceed the
+// cache's limits
+func (c *cache) full(size int) bool {
+	if c.options.Capacity > 0 && len(c.entries)+1 > c.options.Capacity {
+		return true
+	}
+	return c.options.MaxBytes > 0 && c.bytes+size > c.options.MaxBytes
+}
+
+func (c *cache) remove(k key) {
+	e, exists := c.entries[k]
+	if !exists {
+		return
+	}
+
+	c.policy.remove(k)
+	c.bytes -= e.size
+	delete(c.entries, k)
+}
+
+// invalidate removes a note which has changed in the DAL
+func (c *cache) invalidate(k key) {
+	if _, exists := c.entries[k]; exists {
+		c.remove(k)
+		c.stats.Invalidations++
+	}
+}
+
+// removeNotebook invalidates every cached note in the notebook
+func (c *cache) removeNotebook(notebook string) {
+	for k := range c.entries {
+		if k.notebook == notebook {
+			c.invalidate(k)
+		}
+	}
+}
+
+// clone deeply copies a note
+func clone(note *notes.Note) *notes.Note {
+	c := *note
+	c.Meta.History = slices.Clone(note.Meta.History)
+	c.Meta.Tags = slices.Clone(note.Meta.Tags)
+
+	c.Revisions = slices.Clone(note.Revisions)
+	for i, revision := range c.Revisions {
+		c.Revisions[i].Delta = slices.Clone(revision.Delta)
+		for j, op := range c.Revisions[i].Delta {
+			c.Revisions[i].Delta[j].Lines = slices.Clone(op.Lines)
+		}
+	}
+
+	return &c
+}
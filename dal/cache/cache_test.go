@@ -0,0 +1,443 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+const (
+	testNotebooks = 3
+	testNoteIDs   = 8
+	testCapacity  = 4
+)
+
+// memory is an uncached, in-memory DAL. Notes are stored encoded, as they
+// would be on disk, so every read returns a new copy
+type memory struct {
+	mu        sync.Mutex
+	notebook  string
+	notebooks map[string]map[int][]byte
+}
+
+func newMemory() *memory {
+	m := &memory{
+		notebook:  notebookName(0),
+		notebooks: make(map[string]map[int][]byte),
+	}
+	for i := 0; i < testNotebooks; i++ {
+		m.notebooks[notebookName(i)] = make(map[int][]byte)
+	}
+	return m
+}
+
+func notebookName(i int) string {
+	return fmt.Sprintf("notebook-%d", i)
+}
+
+func (m *memory) GetMeta() (*notes.Meta, error) { return &notes.Meta{}, nil }
+func (m *memory) SaveMeta(*notes.Meta) error    { return nil }
+
+func (m *memory) CreateNotebook(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.notebooks[name] = make(map[int][]byte)
+	return nil
+}
+
+func (m *memory) GetNotebook() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.notebook
+}
+
+func (m *memory) GetAllNotebooks() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var names []string
+	for name := range m.notebooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *memory) SetNotebook(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.notebooks[name]; !exists {
+		return fmt.Errorf("notebook %q not found", name)
+	}
+	m.notebook = name
+	return nil
+}
+
+func (m *memory) RenameNotebook(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notebook, exists := m.notebooks[oldName]
+	if !exists {
+		return fmt.Errorf("notebook %q not found", oldName)
+	}
+	delete(m.notebooks, oldName)
+	m.notebooks[newName] = notebook
+	if m.notebook == oldName {
+		m.notebook = newName
+	}
+	return nil
+}
+
+func (m *memory) RemoveNotebook(name string, _ bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.notebooks, name)
+	return nil
+}
+
+func (m *memory) GetNoteMeta(id int) (*notes.NoteMeta, error) {
+	note, err := m.GetNote(id)
+	if err != nil {
+		return nil, err
+	}
+	return &note.Meta, nil
+}
+
+func (m *memory) GetAllNoteMetas() (map[int]notes.NoteMeta, error) {
+	return nil, nil
+}
+
+func (m *memory) GetNote(id int) (*notes.Note, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.notebooks[m.notebook][id]
+	if !exists {
+		return nil, fmt.Errorf("note %d not found", id)
+	}
+
+	var note notes.Note
+	err := json.Unmarshal(b, &note)
+	return &note, err
+}
+
+func (m *memory) SaveNote(note *notes.Note) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	m.notebooks[m.notebook][note.Meta.ID] = b
+	return nil
+}
+
+func (m *memory) RemoveNote(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.notebooks[m.notebook][id]; !exists {
+		return fmt.Errorf("note %d not found", id)
+	}
+	delete(m.notebooks[m.notebook], id)
+	return nil
+}
+
+// operation is a single call made against both a cached and an uncached DAL
+type operation struct {
+	Kind     int
+	ID       int
+	Notebook int
+	Body     string
+}
+
+const (
+	opGet = iota
+	opSave
+	opRemove
+	opSetNotebook
+	opMutate // modify a note returned by GetNote without saving it
+	opFlush
+	opCount
+)
+
+// operations is a random sequence of calls, generated by testing/quick
+type operations []operation
+
+func (operations) Generate(r *rand.Rand, size int) reflect.Value {
+	ops := make(operations, r.Intn(size*4)+1)
+	for i := range ops {
+		ops[i] = operation{
+			Kind:     r.Intn(opCount),
+			ID:       r.Intn(testNoteIDs),
+			Notebook: r.Intn(testNotebooks),
+			Body:     fmt.Sprintf("body %d", r.Intn(1000)),
+		}
+	}
+	return reflect.ValueOf(ops)
+}
+
+// apply calls the operation against d, returning the result to compare
+func (op operation) apply(d NoteCache) string {
+	switch op.Kind {
+	case opGet:
+		note, err := d.GetNote(op.ID)
+		if err != nil {
+			return "error"
+		}
+		b, _ := json.Marshal(note)
+		return string(b)
+	case opSave:
+		note := &notes.Note{
+			Meta: notes.NoteMeta{ID: op.ID, Title: op.Body, Tags: []string{op.Body}},
+			Body: op.Body,
+		}
+		note.UpdateBody(op.Body+" edited", note.Meta.Created.Time)
+		return fmt.Sprint(d.SaveNote(note) == nil)
+	case opRemove:
+		return fmt.Sprint(d.RemoveNote(op.ID) == nil)
+	case opSetNotebook:
+		return fmt.Sprint(d.SetNotebook(notebookName(op.Notebook)) == nil)
+	case opMutate:
+		note, err := d.GetNote(op.ID)
+		if err != nil {
+			return "error"
+		}
+		note.Body = "mutated"
+		note.Meta.AddTags("mutated")
+		if len(note.Revisions) > 0 && len(note.Revisions[0].Delta) > 0 {
+			note.Revisions[0].Delta[0].Lines = append(note.Revisions[0].Delta[0].Lines[:0], "mutated")
+		}
+		return "mutated"
+	case opFlush:
+		d.Flush()
+		return "flushed"
+	}
+	return ""
+}
+
+// uncached adapts a DAL to NoteCache so that operations can be applied to it
+type uncached struct {
+	*memory
+}
+
+func (uncached) Flush() error { return nil }
+func (uncached) Stats() Stats { return Stats{} }
+
+func testEquivalence(t *testing.T, cacheType CacheType, options Options) {
+	property := func(ops operations) bool {
+		expected := uncached{newMemory()}
+		c := NewNoteCacheWithOptions(newMemory(), cacheType, options).(*cache)
+
+		for i, op := range ops {
+			want, got := op.apply(expected), op.apply(c)
+			if want != got {
+				t.Logf("operation %d %+v: expected %s, got %s", i, op, want, got)
+				return false
+			}
+
+			if options.Capacity > 0 && len(c.entries) > options.Capacity {
+				t.Logf("operation %d %+v: cache holds %d notes, over capacity %d", i, op, len(c.entries), options.Capacity)
+				return false
+			}
+			if options.MaxBytes > 0 && c.bytes > options.MaxBytes {
+				t.Logf("operation %d %+v: cache holds %d bytes, over limit %d", i, op, c.bytes, options.MaxBytes)
+				return false
+			}
+		}
+		return true
+	}
+
+	err := quick.Check(property, &quick.Config{MaxCount: 500})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEquivalence(t *testing.T) {
+	for name, cacheType := range map[string]CacheType{
+		"lru": LRU,
+		"rr":  RR,
+		"lfu": LFU,
+		"2q":  TwoQueue,
+	} {
+		t.Run(name, func(t *testing.T) {
+			testEquivalence(t, cacheType, Options{Capacity: testCapacity})
+		})
+		t.Run(name+"/bytes", func(t *testing.T) {
+			// saved bodies are 13 to 15 bytes, so the limit is two notes
+			testEquivalence(t, cacheType, Options{MaxBytes: 32})
+		})
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(newMemory(), 2).(*cache)
+	for id := 1; id <= 2; id++ {
+		c.add(key{id: id}, &notes.Note{})
+	}
+	c.policy.get(key{id: 1})
+	c.add(key{id: 3}, &notes.Note{})
+
+	expectCached(t, c, map[int]bool{1: true, 2: false, 3: true})
+}
+
+func TestLFUEvictsLeastFrequentlyUsed(t *testing.T) {
+	c := NewLFU(newMemory(), 2).(*cache)
+	for id := 1; id <= 2; id++ {
+		c.add(key{id: id}, &notes.Note{})
+	}
+	c.policy.get(key{id: 2})
+	c.policy.get(key{id: 2})
+	c.policy.get(key{id: 1})
+	c.add(key{id: 3}, &notes.Note{})
+
+	expectCached(t, c, map[int]bool{1: false, 2: true, 3: true})
+}
+
+func TestTwoQueueResistsScans(t *testing.T) {
+	c := NewTwoQueue(newMemory(), 4).(*cache)
+
+	// note 1 is evicted from recent, then read again, so it's kept in frequent
+	for id := 1; id <= 5; id++ {
+		c.add(key{id: id}, &notes.Note{})
+	}
+	c.add(key{id: 1}, &notes.Note{})
+
+	// a scan of notes read once doesn't evict it
+	for id := 10; id < 20; id++ {
+		c.add(key{id: id}, &notes.Note{})
+	}
+
+	expectCached(t, c, map[int]bool{1: true, 2: false, 10: false, 19: true})
+}
+
+func TestTTL(t *testing.T) {
+	m := newMemory()
+	m.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 1}, Body: "original"})
+
+	now := time.Now()
+	c := NewNoteCacheWithOptions(m, LRU, Options{Capacity: 2, TTL: time.Minute}).(*cache)
+	c.now = func() time.Time { return now }
+	c.GetNote(1)
+
+	// an edit made by another process isn't visible until the note expires
+	m.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 1}, Body: "edited"})
+	for _, step := range []struct {
+		elapsed time.Duration
+		body    string
+	}{
+		{elapsed: 30 * time.Second, body: "original"},
+		{elapsed: time.Minute, body: "edited"},
+	} {
+		now = now.Add(step.elapsed)
+		note, err := c.GetNote(1)
+		if err != nil {
+			t.Fatalf("get note: %s", err)
+		}
+		if note.Body != step.body {
+			t.Errorf("after %s, expected body %q, got %q", step.elapsed, step.body, note.Body)
+		}
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	c := NewNoteCacheWithOptions(newMemory(), LRU, Options{MaxBytes: 10}).(*cache)
+	c.add(key{id: 1}, &notes.Note{Body: "12345"})
+	c.add(key{id: 2}, &notes.Note{Body: "12345"})
+	c.add(key{id: 3}, &notes.Note{Body: "123"})
+	c.add(key{id: 4}, &notes.Note{Body: "this body is too large to cache"})
+
+	expectCached(t, c, map[int]bool{1: false, 2: true, 3: true, 4: false})
+	if c.bytes != 8 {
+		t.Errorf("expected 8 bytes cached, got %d", c.bytes)
+	}
+}
+
+// expectCached checks whether each note ID, in the empty notebook, is cached
+func expectCached(t *testing.T, c *cache, expected map[int]bool) {
+	t.Helper()
+	for id, cached := range expected {
+		if _, exists := c.entries[key{id: id}]; exists != cached {
+			t.Errorf("expected note %d cached to be %t", id, cached)
+		}
+	}
+}
+
+func TestCacheConcurrentUse(t *testing.T) {
+	for name, c := range map[string]NoteCache{
+		"lru": NewLRU(newMemory(), testCapacity),
+		"rr":  NewRR(newMemory(), testCapacity),
+		"lfu": NewLFU(newMemory(), testCapacity),
+		"2q":  NewTwoQueue(newMemory(), testCapacity),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for worker := 0; worker < 4; worker++ {
+				wg.Add(1)
+				go func(worker int) {
+					defer wg.Done()
+					r := rand.New(rand.NewSource(int64(worker)))
+					for i := 0; i < 200; i++ {
+						operation{
+							Kind:     r.Intn(opCount),
+							ID:       r.Intn(testNoteIDs),
+							Notebook: r.Intn(testNotebooks),
+						}.apply(c)
+					}
+				}(worker)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	m := newMemory()
+	for id := 1; id <= 3; id++ {
+		m.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: id}, Body: "body"})
+	}
+
+	now := time.Now()
+	c := NewNoteCacheWithOptions(m, LRU, Options{Capacity: 2, TTL: time.Minute}).(*cache)
+	c.now = func() time.Time { return now }
+
+	c.GetNote(1) // miss
+	c.GetNote(1) // hit
+	c.GetNote(2) // miss
+	c.GetNote(3) // miss, evicting 1
+	c.GetNote(4) // miss, not found
+	c.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 2}, Body: "edited"})
+	now = now.Add(time.Minute)
+	c.GetNote(3) // expired, then missed
+
+	expected := Stats{
+		Policy:        "lru",
+		Hits:          1,
+		Misses:        5,
+		Evictions:     1,
+		Expirations:   1,
+		Invalidations: 1,
+		Entries:       1,
+		Bytes:         4,
+		Capacity:      2,
+		TTL:           time.Minute,
+	}
+	if stats := c.Stats(); stats != expected {
+		t.Errorf("expected stats %+v, got %+v", expected, stats)
+	}
+}
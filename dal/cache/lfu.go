@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"container/heap"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+// lfu utilizes a least-frequently-used cache replacement policy. Notes read
+// equally often are replaced least recently used first
+type lfu struct {
+	index map[key]*lfuItem
+	queue lfuQueue
+	clock uint64 // incremented on each read, to order reads
+}
+
+type lfuItem struct {
+	key   key
+	note  *notes.Note
+	reads int
+	used  uint64 // clock value of the last read
+	pos   int    // position in the queue
+}
+
+func NewLFU(d dal.DAL, capacity int) NoteCache {
+	return newCache(d, LFU, newLFU(), Options{Capacity: capacity})
+}
+
+func newLFU() *lfu {
+	return &lfu{
+		index: make(map[key]*lfuItem),
+	}
+}
+
+func (l *lfu) get(k key) (*notes.Note, bool) {
+	item, exists := l.index[k]
+	if !exists {
+		return nil, false
+	}
+
+	l.clock++
+	item.reads++
+	item.used = l.clock
+	heap.Fix(&l.queue, item.pos)
+
+	return item.note, true
+}
+
+func (l *lfu) add(k key, note *notes.Note) {
+	l.clock++
+	item := &lfuItem{
+		key:   k,
+		note:  note,
+		reads: 1,
+		used:  l.clock,
+	}
+
+	l.index[k] = item
+	heap.Push(&l.queue, item)
+}
+
+func (l *lfu) remove(k key) {
+	item, exists := l.index[k]
+	if !exists {
+		return
+	}
+
+	heap.Remove(&l.queue, item.pos)
+	delete(l.index, k)
+}
+
+func (l *lfu) evict() (key, bool) {
+	if len(l.queue) == 0 {
+		return key{}, false
+	}
+
+	item := heap.Pop(&l.queue).(*lfuItem)
+	delete(l.index, item.key)
+	return item.key, true
+}
+
+func (l *lfu) flush() {
+	l.index = make(map[key]*lfuItem)
+	l.queue = nil
+}
+
+// lfuQueue is a min-heap of items ordered by reads, then by last read
+type lfuQueue []*lfuItem
+
+func (q lfuQueue) Len() int { return len(q) }
+
+func (q lfuQueue) Less(i, j int) bool {
+	if q[i].reads != q[j].reads {
+		return q[i].reads < q[j].reads
+	}
+	return q[i].used < q[j].used
+}
+
+func (q lfuQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].pos = i
+	q[j].pos = j
+}
+
+func (q *lfuQueue) Push(x any) {
+	item := x.(*lfuItem)
+	item.pos = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *lfuQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
@@ -1,24 +1,21 @@
 package cache
 
 import (
-	"fmt"
-
 	"github.com/subtlepseudonym/notes"
 	"github.com/subtlepseudonym/notes/dal"
 )
 
 // lru utilizes a least-recently-used cache replacement policy
 type lru struct {
-	dal.DAL
-	capacity int
-	index    map[int]*node // map noteID to linked list pointer
-	front    *node
-	rear     *node
+	index map[key]*node // map note key to linked list pointer
+	front *node         // most recently used
+	rear  *node         // least recently used
 }
 
 type node struct {
 	prev *node
 	next *node
+	key  key
 	note *notes.Note
 }
 
@@ -27,98 +24,87 @@ func NewLeastRecentlyUsed(d dal.DAL, capacity int) NoteCache {
 }
 
 func NewLRU(d dal.DAL, capacity int) NoteCache {
-	return lru{
-		DAL:      d,
-		capacity: capacity,
-		index:    make(map[int]*node, capacity),
-	}
+	return newCache(d, LRU, newLRU(), Options{Capacity: capacity})
 }
 
-func (l lru) Flush() error {
-	l.index = make(map[int]*node, l.capacity)
-	l.front = nil
-	l.rear = nil
-
-	return nil
+func newLRU() *lru {
+	return &lru{
+		index: make(map[key]*node),
+	}
 }
 
-func (l lru) GetNote(id int) (*notes.Note, error) {
-	cached, exists := l.index[id]
-	if exists {
-		l.moveToFront(cached)
-		return cached.note, nil
+func (l *lru) get(k key) (*notes.Note, bool) {
+	n, exists := l.index[k]
+	if !exists {
+		return nil, false
 	}
 
-	note, err := l.DAL.GetNote(id)
-	if err != nil {
-		return nil, fmt.Errorf("cache miss: %w", err)
-	}
+	l.unlink(n)
+	l.pushFront(n)
+	return n.note, true
+}
 
-	newNode := node{
+func (l *lru) add(k key, note *notes.Note) {
+	n := &node{
+		key:  k,
 		note: note,
 	}
-	l.add(&newNode)
-
-	return note, nil
+	l.index[k] = n
+	l.pushFront(n)
 }
 
-func (l lru) moveToFront(n *node) {
-	if n == l.front {
+func (l *lru) remove(k key) {
+	n, exists := l.index[k]
+	if !exists {
 		return
 	}
 
-	n.prev.next = n.next
-	if n.next != nil {
-		n.next.prev = n.prev
-	}
+	l.unlink(n)
+	delete(l.index, k)
+}
 
-	if n == l.rear {
-		l.rear = n.prev
-		l.rear.next = nil
+func (l *lru) evict() (key, bool) {
+	if l.rear == nil {
+		return key{}, false
 	}
 
-	n.next = l.front
-	n.prev = nil
-
-	n.next.prev = n
-	l.front = n
+	k := l.rear.key
+	l.remove(k)
+	return k, true
 }
 
-func (l lru) add(n *node) {
-	if l.front == nil {
-		l.front = n
-		l.rear = n
+func (l *lru) flush() {
+	l.index = make(map[key]*node)
+	l.front = nil
+	l.rear = nil
+}
 
-		return
+// unlink removes the node from the list, leaving it in the index
+func (l *lru) unlink(n *node) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		l.front = n.next
 	}
 
-	n.next = l.front
-	l.front.prev = n
-
-	l.front = n
-	l.index[n.note.Meta.ID] = n
-
-	if len(l.index)+1 > l.capacity {
-		l.removeOldest()
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		l.rear = n.prev
 	}
-}
 
-func (l lru) removeOldest() {
-	if len(l.index) == 0 {
-		return
-	}
+	n.prev = nil
+	n.next = nil
+}
 
-	if l.front == l.rear {
-		l.front = nil
-		l.rear = nil
-		return
+func (l *lru) pushFront(n *node) {
+	n.next = l.front
+	if l.front != nil {
+		l.front.prev = n
 	}
+	l.front = n
 
-	id := l.rear.note.Meta.ID
-	delete(l.index, id)
-
-	l.rear = l.rear.prev
-	if l.rear != nil {
-		l.rear.next = nil
+	if l.rear == nil {
+		l.rear = n
 	}
 }
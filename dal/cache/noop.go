@@ -3,6 +3,8 @@ package cache
 import (
 	"errors"
 
+	"github.com/subtlepseudonym/notes"
+
 	"github.com/subtlepseudonym/notes/dal"
 )
 
@@ -16,6 +18,18 @@ func NewNoop(d dal.DAL) NoteCache {
 	}
 }
 
+func (n noop) ReserveNoteID() (*notes.Meta, error) {
+	return dal.ReserveNoteID(n.DAL)
+}
+
+func (n noop) UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error) {
+	return dal.UpdateMeta(n.DAL, update)
+}
+
 func (n noop) Flush() error {
 	return errors.New("noop cache: nothing to flush")
 }
+
+func (n noop) Stats() Stats {
+	return Stats{Policy: Noop.String()}
+}
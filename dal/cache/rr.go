@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"fmt"
 	"math/rand"
 
 	"github.com/subtlepseudonym/notes"
@@ -10,10 +9,8 @@ import (
 
 // rr uses a random replacement cache replacement policy
 type rr struct {
-	dal.DAL
-	capacity int
-	index    []int
-	cache    map[int]*notes.Note
+	index []key
+	cache map[key]*notes.Note
 }
 
 func NewRandomReplacement(d dal.DAL, capacity int) NoteCache {
@@ -21,46 +18,59 @@ func NewRandomReplacement(d dal.DAL, capacity int) NoteCache {
 }
 
 func NewRR(d dal.DAL, capacity int) NoteCache {
-	return rr{
-		DAL:      d,
-		capacity: capacity,
-		index:    make([]int, 0, capacity),
-		cache:    make(map[int]*notes.Note, capacity),
+	return newCache(d, RR, newRR(), Options{Capacity: capacity})
+}
+
+func newRR() *rr {
+	return &rr{
+		cache: make(map[key]*notes.Note),
 	}
 }
 
-func (r rr) Flush() error {
-	r.index = make([]int, 0, r.capacity)
-	r.cache = make(map[int]*notes.Note, r.capacity)
+func (r *rr) get(k key) (*notes.Note, bool) {
+	note, exists := r.cache[k]
+	return note, exists
+}
 
-	return nil
+func (r *rr) add(k key, note *notes.Note) {
+	r.index = append(r.index, k)
+	r.cache[k] = note
 }
 
-func (r rr) GetNote(id int) (*notes.Note, error) {
-	cached, exists := r.cache[id]
-	if exists {
-		return cached, nil
+func (r *rr) remove(k key) {
+	if _, exists := r.cache[k]; !exists {
+		return
+	}
+
+	for i := range r.index {
+		if r.index[i] == k {
+			r.removeAt(i)
+			return
+		}
 	}
+}
 
-	note, err := r.DAL.GetNote(id)
-	if err != nil {
-		return nil, fmt.Errorf("cache miss: %w", err)
+func (r *rr) evict() (key, bool) {
+	if len(r.index) == 0 {
+		return key{}, false
 	}
 
-	r.add(note)
-	return note, nil
+	i := rand.Intn(len(r.index))
+	k := r.index[i]
+	r.removeAt(i)
+	return k, true
 }
 
-func (r rr) add(note *notes.Note) {
-	if len(r.index) < r.capacity {
-		r.index = append(r.index, note.Meta.ID)
-	} else {
-		idx := rand.Intn(len(r.index))
-		noteID := r.index[idx]
-		delete(r.cache, noteID)
+// removeAt removes the note at position i of the index
+func (r *rr) removeAt(i int) {
+	delete(r.cache, r.index[i])
 
-		r.index[idx] = note.Meta.ID
-	}
+	last := len(r.index) - 1
+	r.index[i] = r.index[last]
+	r.index = r.index[:last]
+}
 
-	r.cache[note.Meta.ID] = note
+func (r *rr) flush() {
+	r.index = nil
+	r.cache = make(map[key]*notes.Note)
 }
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+const (
+	defaultRecentShare = 4 // recent is evicted from first once it holds more than 1/4 of the cached notes
+	defaultGhostShare  = 2 // ghost remembers up to 1/2 as many keys as there are cached notes
+)
+
+// twoQueue utilizes the 2Q cache replacement policy. Notes are first held in
+// recent, in the order they were added. The keys of notes evicted from recent
+// are remembered in ghost, and a note read again shortly after being evicted
+// is held in frequent, by how recently it was read. Scanning many notes once
+// only replaces notes in recent, so it can't flush out notes read often
+type twoQueue struct {
+	recent   *lru // only ordered by addition, as reads don't move notes to the front
+	frequent *lru
+	ghost    *lru // keys only
+}
+
+func NewTwoQueue(d dal.DAL, capacity int) NoteCache {
+	return newCache(d, TwoQueue, newTwoQueue(), Options{Capacity: capacity})
+}
+
+func newTwoQueue() *twoQueue {
+	return &twoQueue{
+		recent:   newLRU(),
+		frequent: newLRU(),
+		ghost:    newLRU(),
+	}
+}
+
+func (q *twoQueue) get(k key) (*notes.Note, bool) {
+	if n, exists := q.recent.index[k]; exists {
+		return n.note, true
+	}
+	return q.frequent.get(k)
+}
+
+func (q *twoQueue) add(k key, note *notes.Note) {
+	if _, exists := q.ghost.index[k]; exists {
+		q.ghost.remove(k)
+		q.frequent.add(k, note)
+		return
+	}
+	q.recent.add(k, note)
+}
+
+func (q *twoQueue) remove(k key) {
+	q.recent.remove(k)
+	q.frequent.remove(k)
+}
+
+func (q *twoQueue) evict() (key, bool) {
+	cached := len(q.recent.index) + len(q.frequent.index)
+	if len(q.recent.index)*defaultRecentShare <= cached && len(q.frequent.index) > 0 {
+		return q.frequent.evict()
+	}
+
+	k, ok := q.recent.evict()
+	if !ok {
+		return k, false
+	}
+
+	q.ghost.add(k, nil)
+	for len(q.ghost.index) > max(cached/defaultGhostShare, 1) {
+		q.ghost.evict()
+	}
+	return k, true
+}
+
+func (q *twoQueue) flush() {
+	q.recent.flush()
+	q.frequent.flush()
+	q.ghost.flush()
+}
@@ -0,0 +1,661 @@
+// Package crypt implements a DAL decorator which encrypts the notes of
+// selected notebooks at rest
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	cipherName   = "aes-256-gcm"
+	kdfName      = "scrypt"
+	keySize      = 32
+	saltSize     = 16
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	sealedPrefix = dal.SealedPrefix
+	dataKeyData  = "notes data key" // additional data authenticated with the sealed data key
+)
+
+var (
+	// ErrIncorrectPassphrase indicates that the passphrase couldn't unseal
+	// the notebook's data key
+	ErrIncorrectPassphrase = errors.New("incorrect passphrase")
+
+	// ErrNotEncrypted indicates that the notebook isn't encrypted
+	ErrNotEncrypted = errors.New("notebook is not encrypted")
+)
+
+// PassphraseFunc gets the passphrase for the named notebook, typically by
+// prompting the user
+type PassphraseFunc func(notebook string) (string, error)
+
+// Encrypter wraps a DAL, encrypting note bodies, revisions, and optionally
+// titles in notebooks whose meta holds encryption parameters. Everything else
+// in the note meta is left readable so that notes can be listed without the
+// passphrase
+type Encrypter interface {
+	dal.DAL
+	dal.EncryptionChecker
+
+	// Encrypt encrypts the current notebook's notes, returning the number of
+	// notes converted. If the notebook is already encrypted, passphrase must
+	// match its passphrase and only notes that were left unencrypted, such as
+	// by an interrupted call, are converted
+	Encrypt(passphrase string, titles bool) (int, error)
+
+	// Decrypt decrypts the current notebook's notes, returning the number of
+	// notes converted
+	Decrypt() (int, error)
+
+	// Rekey changes the current notebook's passphrase
+	Rekey(passphrase string) error
+
+	// Unlock unseals the current notebook's data key, asking for its
+	// passphrase if it hasn't been provided before
+	Unlock() error
+}
+
+// sealedNote holds the fields of a note which are encrypted together as its
+// body
+type sealedNote struct {
+	Body      string           `json:"body"`
+	Revisions []notes.Revision `json:"revisions,omitempty"`
+}
+
+// encrypter asks for each notebook's passphrase on the first call that needs
+// its data key, which is then kept for the encrypter's lifetime. Notes that
+// aren't sealed are read as they are, which allows converting a notebook one
+// note at a time
+type encrypter struct {
+	dal.DAL
+	mu     sync.Mutex
+	prompt PassphraseFunc
+	keys   map[string][]byte // map sealed data keys to data keys
+}
+
+// New returns an Encrypter which gets passphrases from prompt
+func New(d dal.DAL, prompt PassphraseFunc) Encrypter {
+	return &encrypter{
+		DAL:    d,
+		prompt: prompt,
+		keys:   make(map[string][]byte),
+	}
+}
+
+// encryption gets the current notebook's encryption parameters, which are
+// nil if it isn't encrypted
+func (e *encrypter) encryption() (*notes.Encryption, error) {
+	meta, err := e.DAL.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("get meta: %w", err)
+	}
+	return meta.Encryption, nil
+}
+
+// dataKey unseals the data key described by enc, prompting for the current
+// notebook's passphrase if the key hasn't been unsealed before
+func (e *encrypter) dataKey(enc *notes.Encryption) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if key, ok := e.keys[string(enc.Key)]; ok {
+		return key, nil
+	}
+
+	passphrase, err := e.prompt(e.DAL.GetNotebook())
+	if err != nil {
+		return nil, fmt.Errorf("get passphrase: %w", err)
+	}
+
+	key, err := unsealDataKey(enc, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	e.keys[string(enc.Key)] = key
+	return key, nil
+}
+
+// remember caches the data key sealed in enc
+func (e *encrypter) remember(enc *notes.Encryption, key []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.keys[string(enc.Key)] = key
+}
+
+// SaveMeta saves the meta, keeping the stored encryption parameters. They're
+// only changed by Encrypt, Decrypt, and Rekey so that saving a meta which was
+// read before one of those calls can't lose them
+func (e *encrypter) SaveMeta(meta *notes.Meta) error {
+	enc, err := e.encryption()
+	if err != nil {
+		return err
+	}
+
+	meta.Encryption = enc
+	return e.DAL.SaveMeta(meta)
+}
+
+// ReserveNoteID reserves the next note ID with the underlying DAL. The meta's
+// encryption parameters are saved as they were read
+func (e *encrypter) ReserveNoteID() (*notes.Meta, error) {
+	return dal.ReserveNoteID(e.DAL)
+}
+
+// UpdateMeta updates the meta with the underlying DAL, keeping the stored
+// encryption parameters as SaveMeta does
+func (e *encrypter) UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error) {
+	return dal.UpdateMeta(e.DAL, func(meta *notes.Meta) {
+		enc := meta.Encryption
+		update(meta)
+		meta.Encryption = enc
+	})
+}
+
+// IsEncrypted determines whether the notebook's notes are encrypted
+func (e *encrypter) IsEncrypted(notebook string) (bool, error) {
+	current := e.DAL.GetNotebook()
+	if notebook != current {
+		err := e.DAL.SetNotebook(notebook)
+		if err != nil {
+			return false, fmt.Errorf("set notebook: %w", err)
+		}
+		defer e.DAL.SetNotebook(current)
+	}
+
+	enc, err := e.encryption()
+	if err != nil {
+		return false, err
+	}
+	return enc != nil, nil
+}
+
+func (e *encrypter) GetNoteMeta(id int) (*notes.NoteMeta, error) {
+	meta, err := e.DAL.GetNoteMeta(id)
+	if err != nil || !isSealed(meta.Title) {
+		return meta, err
+	}
+
+	key, err := e.currentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	meta.Title, err = openString(key, meta.Title, noteData(id))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt note %d title: %w", id, err)
+	}
+	return meta, nil
+}
+
+func (e *encrypter) GetAllNoteMetas() (map[int]notes.NoteMeta, error) {
+	metas, err := e.DAL.GetAllNoteMetas()
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	for id, meta := range metas {
+		if !isSealed(meta.Title) {
+			continue
+		}
+
+		if key == nil {
+			key, err = e.currentKey()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		meta.Title, err = openString(key, meta.Title, noteData(id))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt note %d title: %w", id, err)
+		}
+		metas[id] = meta
+	}
+
+	return metas, nil
+}
+
+func (e *encrypter) GetNote(id int) (*notes.Note, error) {
+	note, err := e.DAL.GetNote(id)
+	if err != nil || (!isSealed(note.Body) && !isSealed(note.Meta.Title)) {
+		return note, err
+	}
+
+	key, err := e.currentKey()
+	if err != nil {
+		return nil, err
+	}
+	return openNote(key, note)
+}
+
+// SaveNote encrypts the note if the current notebook is encrypted. The
+// provided note is left unencrypted
+func (e *encrypter) SaveNote(note *notes.Note) error {
+	enc, err := e.encryption()
+	if err != nil {
+		return err
+	}
+	if enc == nil {
+		return e.DAL.SaveNote(note)
+	}
+
+	key, err := e.dataKey(enc)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := sealNote(key, note, enc.Titles)
+	if err != nil {
+		return err
+	}
+	return e.DAL.SaveNote(sealed)
+}
+
+// currentKey gets the current notebook's data key
+func (e *encrypter) currentKey() ([]byte, error) {
+	enc, err := e.encryption()
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("note is encrypted, but %w", ErrNotEncrypted)
+	}
+	return e.dataKey(enc)
+}
+
+func (e *encrypter) Unlock() error {
+	enc, err := e.encryption()
+	if err != nil {
+		return err
+	}
+	if enc == nil {
+		return ErrNotEncrypted
+	}
+
+	_, err = e.dataKey(enc)
+	return err
+}
+
+func (e *encrypter) Encrypt(passphrase string, titles bool) (int, error) {
+	meta, err := e.DAL.GetMeta()
+	if err != nil {
+		return 0, fmt.Errorf("get meta: %w", err)
+	}
+
+	var key []byte
+	if meta.Encryption != nil {
+		if meta.Encryption.Titles != titles {
+			return 0, fmt.Errorf("notebook is already encrypted with title encryption set to %t", meta.Encryption.Titles)
+		}
+
+		key, err = unsealDataKey(meta.Encryption, passphrase)
+		if err != nil {
+			return 0, err
+		}
+		e.remember(meta.Encryption, key)
+	} else {
+		key = make([]byte, keySize)
+		_, err = rand.Read(key)
+		if err != nil {
+			return 0, fmt.Errorf("generate data key: %w", err)
+		}
+
+		meta.Encryption, err = newEncryption(passphrase, key, titles)
+		if err != nil {
+			return 0, err
+		}
+
+		// the parameters are saved first so that notes encrypted before an
+		// interruption can still be read
+		err = e.DAL.SaveMeta(meta)
+		if err != nil {
+			return 0, fmt.Errorf("save meta: %w", err)
+		}
+		e.remember(meta.Encryption, key)
+	}
+
+	return e.convert(func(note *notes.Note) (*notes.Note, error) {
+		if isSealed(note.Body) && (isSealed(note.Meta.Title) || !titles || note.Meta.Title == "") {
+			return nil, nil
+		}
+
+		note, err := openNote(key, note)
+		if err != nil {
+			return nil, err
+		}
+		return sealNote(key, note, titles)
+	})
+}
+
+func (e *encrypter) Decrypt() (int, error) {
+	enc, err := e.encryption()
+	if err != nil {
+		return 0, err
+	}
+	if enc == nil {
+		return 0, ErrNotEncrypted
+	}
+
+	key, err := e.dataKey(enc)
+	if err != nil {
+		return 0, err
+	}
+
+	converted, err := e.convert(func(note *notes.Note) (*notes.Note, error) {
+		if !isSealed(note.Body) && !isSealed(note.Meta.Title) {
+			return nil, nil
+		}
+		return openNote(key, note)
+	})
+	if err != nil {
+		return converted, err
+	}
+
+	// the parameters are removed last so that notes left encrypted by an
+	// interruption can still be read
+	meta, err := e.DAL.GetMeta()
+	if err != nil {
+		return converted, fmt.Errorf("get meta: %w", err)
+	}
+	meta.Encryption = nil
+
+	err = e.DAL.SaveMeta(meta)
+	if err != nil {
+		return converted, fmt.Errorf("save meta: %w", err)
+	}
+	return converted, nil
+}
+
+// Rekey seals the existing data key with the new passphrase. Notes aren't
+// re-encrypted, so the change is made by a single meta write
+func (e *encrypter) Rekey(passphrase string) error {
+	meta, err := e.DAL.GetMeta()
+	if err != nil {
+		return fmt.Errorf("get meta: %w", err)
+	}
+	if meta.Encryption == nil {
+		return ErrNotEncrypted
+	}
+
+	key, err := e.dataKey(meta.Encryption)
+	if err != nil {
+		return err
+	}
+
+	meta.Encryption, err = newEncryption(passphrase, key, meta.Encryption.Titles)
+	if err != nil {
+		return err
+	}
+
+	err = e.DAL.SaveMeta(meta)
+	if err != nil {
+		return fmt.Errorf("save meta: %w", err)
+	}
+	e.remember(meta.Encryption, key)
+
+	return nil
+}
+
+// convert saves the result of fn for each note in the current notebook,
+// returning the number of notes saved. Notes for which fn returns nil are
+// left as they are
+func (e *encrypter) convert(fn func(*notes.Note) (*notes.Note, error)) (int, error) {
+	metas, err := e.DAL.GetAllNoteMetas()
+	if err != nil {
+		return 0, fmt.Errorf("get note metas: %w", err)
+	}
+
+	ids := make([]int, 0, len(metas))
+	for id := range metas {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var converted int
+	for _, id := range ids {
+		note, err := e.DAL.GetNote(id)
+		if err != nil {
+			return converted, fmt.Errorf("get note %d: %w", id, err)
+		}
+
+		note, err = fn(note)
+		if err != nil {
+			return converted, fmt.Errorf("convert note %d: %w", id, err)
+		}
+		if note == nil {
+			continue
+		}
+
+		err = e.DAL.SaveNote(note)
+		if err != nil {
+			return converted, fmt.Errorf("save note %d: %w", id, err)
+		}
+		converted++
+	}
+
+	return converted, nil
+}
+
+// newEncryption creates encryption parameters with a new salt, sealing the
+// data key with a key derived from passphrase
+func newEncryption(passphrase string, key []byte, titles bool) (*notes.Encryption, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be blank")
+	}
+
+	enc := &notes.Encryption{
+		Cipher: cipherName,
+		KDF:    kdfName,
+		Salt:   make([]byte, saltSize),
+		N:      scryptN,
+		R:      scryptR,
+		P:      scryptP,
+		Titles: titles,
+	}
+
+	_, err := rand.Read(enc.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+
+	passphraseKey, err := deriveKey(enc, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	enc.Key, err = seal(passphraseKey, key, []byte(dataKeyData))
+	if err != nil {
+		return nil, fmt.Errorf("seal data key: %w", err)
+	}
+	return enc, nil
+}
+
+// unsealDataKey gets the data key sealed in enc using a key derived from
+// passphrase
+func unsealDataKey(enc *notes.Encryption, passphrase string) ([]byte, error) {
+	if enc.Cipher != cipherName {
+		return nil, fmt.Errorf("unsupported cipher %q", enc.Cipher)
+	}
+
+	passphraseKey, err := deriveKey(enc, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := open(passphraseKey, enc.Key, []byte(dataKeyData))
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return key, nil
+}
+
+func deriveKey(enc *notes.Encryption, passphrase string) ([]byte, error) {
+	if enc.KDF != kdfName {
+		return nil, fmt.Errorf("unsupported key derivation function %q", enc.KDF)
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), enc.Salt, enc.N, enc.R, enc.P, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	return key, nil
+}
+
+// noteData is the additional data authenticated with a note's sealed fields,
+// which prevents them being swapped between notes
+func noteData(id int) []byte {
+	return []byte(strconv.Itoa(id))
+}
+
+// sealNote returns a copy of the note with its body and revisions, and
+// optionally its title, encrypted
+func sealNote(key []byte, note *notes.Note, titles bool) (*notes.Note, error) {
+	b, err := json.Marshal(sealedNote{
+		Body:      note.Body,
+		Revisions: note.Revisions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode note: %w", err)
+	}
+
+	sealed := *note
+	sealed.Revisions = nil
+	sealed.Body, err = sealString(key, string(b), noteData(note.Meta.ID))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt note: %w", err)
+	}
+
+	if titles && note.Meta.Title != "" {
+		sealed.Meta.Title, err = sealString(key, note.Meta.Title, noteData(note.Meta.ID))
+		if err != nil {
+			return nil, fmt.Errorf("encrypt title: %w", err)
+		}
+	}
+
+	return &sealed, nil
+}
+
+// openNote returns a copy of the note with its sealed fields decrypted
+func openNote(key []byte, note *notes.Note) (*notes.Note, error) {
+	opened := *note
+	data := noteData(note.Meta.ID)
+
+	if isSealed(note.Body) {
+		b, err := openString(key, note.Body, data)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt note %d: %w", note.Meta.ID, err)
+		}
+
+		var contents sealedNote
+		err = json.Unmarshal([]byte(b), &contents)
+		if err != nil {
+			return nil, fmt.Errorf("decode note %d: %w", note.Meta.ID, err)
+		}
+		opened.Body = contents.Body
+		opened.Revisions = contents.Revisions
+	}
+
+	if isSealed(note.Meta.Title) {
+		var err error
+		opened.Meta.Title, err = openString(key, note.Meta.Title, data)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt note %d title: %w", note.Meta.ID, err)
+		}
+	}
+
+	return &opened, nil
+}
+
+func isSealed(s string) bool {
+	return strings.HasPrefix(s, sealedPrefix)
+}
+
+func sealString(key []byte, s string, data []byte) (string, error) {
+	b, err := seal(key, []byte(s), data)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(b), nil
+}
+
+func openString(key []byte, s string, data []byte) (string, error) {
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(s, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode ciphertext: %w", err)
+	}
+
+	b, err = open(key, b, data)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// seal encrypts and authenticates plaintext and data, returning the
+// ciphertext prefixed with its random nonce
+func seal(key, plaintext, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, data), nil
+}
+
+// open decrypts and authenticates a ciphertext created by seal
+func open(key, ciphertext, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, data)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create GCM: %w", err)
+	}
+	return aead, nil
+}
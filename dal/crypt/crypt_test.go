@@ -0,0 +1,270 @@
+package crypt
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+func newTestEncrypter(t *testing.T, passphrase string) (*encrypter, *int) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	local, err := dal.NewLocal("notes", "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+
+	prompts := new(int)
+	e := New(local, func(string) (string, error) {
+		*prompts++
+		return passphrase, nil
+	})
+	return e.(*encrypter), prompts
+}
+
+func newTestNote(id int, title, body string) *notes.Note {
+	return &notes.Note{
+		Meta: notes.NoteMeta{
+			ID:      id,
+			Title:   title,
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+		},
+		Body: body,
+		Revisions: []notes.Revision{
+			{Number: 1, Delta: []notes.DeltaOp{{Lines: []string{"old secret"}}}},
+		},
+	}
+}
+
+// readNoteFile reads the note's file as stored by the local DAL
+func readNoteFile(t *testing.T, notebook string, id int) string {
+	t.Helper()
+
+	home, _ := os.UserHomeDir()
+	b, err := os.ReadFile(path.Join(home, "notes", dal.LocalNotePath(notebook, id)))
+	if err != nil {
+		t.Fatalf("read note file: %s", err)
+	}
+	return string(b)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	e, prompts := newTestEncrypter(t, "hunter2")
+
+	for id := 1; id <= 3; id++ {
+		err := e.SaveNote(newTestNote(id, "incident plans", "secret body"))
+		if err != nil {
+			t.Fatalf("save note: %s", err)
+		}
+	}
+
+	converted, err := e.Encrypt("hunter2", true)
+	if err != nil {
+		t.Fatalf("encrypt: %s", err)
+	}
+	if converted != 3 {
+		t.Errorf("expected 3 notes converted, got %d", converted)
+	}
+
+	stored := readNoteFile(t, "default", 2)
+	for _, plaintext := range []string{"secret body", "old secret", "incident plans"} {
+		if strings.Contains(stored, plaintext) {
+			t.Errorf("stored note contains %q:\n%s", plaintext, stored)
+		}
+	}
+
+	// notes saved after encryption are encrypted too
+	err = e.SaveNote(newTestNote(4, "incident plans", "secret body"))
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+	if strings.Contains(readNoteFile(t, "default", 4), "secret body") {
+		t.Errorf("note saved to encrypted notebook was stored in the clear")
+	}
+
+	note, err := e.GetNote(4)
+	if err != nil {
+		t.Fatalf("get note: %s", err)
+	}
+	if note.Body != "secret body" || note.Meta.Title != "incident plans" || len(note.Revisions) != 1 {
+		t.Errorf("decrypted note doesn't match: %+v", note)
+	}
+
+	metas, err := e.GetAllNoteMetas()
+	if err != nil {
+		t.Fatalf("get note metas: %s", err)
+	}
+	if metas[1].Title != "incident plans" {
+		t.Errorf("expected decrypted title, got %q", metas[1].Title)
+	}
+
+	if *prompts != 0 {
+		t.Errorf("expected the key from encrypt to be reused, prompted %d times", *prompts)
+	}
+
+	converted, err = e.Decrypt()
+	if err != nil {
+		t.Fatalf("decrypt: %s", err)
+	}
+	if converted != 4 {
+		t.Errorf("expected 4 notes converted, got %d", converted)
+	}
+	if !strings.Contains(readNoteFile(t, "default", 2), "secret body") {
+		t.Errorf("decrypted note isn't stored in the clear")
+	}
+
+	encrypted, err := e.IsEncrypted("default")
+	if err != nil {
+		t.Fatalf("is encrypted: %s", err)
+	}
+	if encrypted {
+		t.Errorf("expected notebook to be decrypted")
+	}
+}
+
+func TestEncryptPromptsOnce(t *testing.T) {
+	e, prompts := newTestEncrypter(t, "hunter2")
+
+	err := e.SaveNote(newTestNote(1, "incident plans", "secret body"))
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+	_, err = e.Encrypt("hunter2", false)
+	if err != nil {
+		t.Fatalf("encrypt: %s", err)
+	}
+
+	// a new session must ask for the passphrase, but only once
+	session := New(e.DAL, e.prompt).(*encrypter)
+	for i := 0; i < 3; i++ {
+		note, err := session.GetNote(1)
+		if err != nil {
+			t.Fatalf("get note: %s", err)
+		}
+		if note.Body != "secret body" {
+			t.Errorf("expected decrypted body, got %q", note.Body)
+		}
+	}
+	if *prompts != 1 {
+		t.Errorf("expected one prompt, got %d", *prompts)
+	}
+
+	// titles aren't encrypted, so listing doesn't need the passphrase
+	metas, err := New(e.DAL, nil).GetAllNoteMetas()
+	if err != nil {
+		t.Fatalf("get note metas: %s", err)
+	}
+	if metas[1].Title != "incident plans" {
+		t.Errorf("expected readable title, got %q", metas[1].Title)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	e, _ := newTestEncrypter(t, "hunter2")
+
+	err := e.SaveNote(newTestNote(1, "incident plans", "secret body"))
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+	_, err = e.Encrypt("hunter2", false)
+	if err != nil {
+		t.Fatalf("encrypt: %s", err)
+	}
+
+	err = e.Rekey("correct horse")
+	if err != nil {
+		t.Fatalf("rekey: %s", err)
+	}
+
+	old := New(e.DAL, func(string) (string, error) { return "hunter2", nil })
+	_, err = old.GetNote(1)
+	if !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("expected old passphrase to be rejected, got %v", err)
+	}
+
+	rekeyed := New(e.DAL, func(string) (string, error) { return "correct horse", nil })
+	note, err := rekeyed.GetNote(1)
+	if err != nil {
+		t.Fatalf("get note: %s", err)
+	}
+	if note.Body != "secret body" {
+		t.Errorf("expected decrypted body, got %q", note.Body)
+	}
+}
+
+func TestSaveMetaKeepsEncryption(t *testing.T) {
+	e, _ := newTestEncrypter(t, "hunter2")
+
+	stale, err := e.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+
+	_, err = e.Encrypt("hunter2", false)
+	if err != nil {
+		t.Fatalf("encrypt: %s", err)
+	}
+
+	stale.LatestID = 7
+	err = e.SaveMeta(stale)
+	if err != nil {
+		t.Fatalf("save meta: %s", err)
+	}
+
+	encrypted, err := e.IsEncrypted("default")
+	if err != nil {
+		t.Fatalf("is encrypted: %s", err)
+	}
+	if !encrypted {
+		t.Errorf("saving a stale meta removed the notebook's encryption")
+	}
+}
+
+func TestDiagnoseKeepsEncryptedMeta(t *testing.T) {
+	e, _ := newTestEncrypter(t, "hunter2")
+
+	err := e.SaveNote(newTestNote(1, "incident plans", "secret body"))
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+	_, err = e.Encrypt("hunter2", false)
+	if err != nil {
+		t.Fatalf("encrypt: %s", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	metaPath := path.Join(home, "notes", "default", "meta")
+	for _, contents := range []string{"", "{"} {
+		if contents == "" {
+			err = os.Remove(metaPath)
+		} else {
+			err = os.WriteFile(metaPath, []byte(contents), 0644)
+		}
+		if err != nil {
+			t.Fatalf("damage meta: %s", err)
+		}
+
+		problems, err := e.DAL.(dal.Doctor).Diagnose("default", true)
+		if err != nil {
+			t.Fatalf("diagnose: %s", err)
+		}
+		if len(problems) != 1 || problems[0].Kind != dal.ProblemEncryptedMeta || problems[0].Repaired {
+			t.Errorf("diagnose with damaged meta %q found %+v, expected an unrepaired %s problem", contents, problems, dal.ProblemEncryptedMeta)
+		}
+
+		// the damaged meta is left for the user to restore
+		b, err := os.ReadFile(metaPath)
+		if contents == "" && !os.IsNotExist(err) {
+			t.Errorf("diagnose synthesized a meta for an encrypted notebook: %s", b)
+		} else if contents != "" && string(b) != contents {
+			t.Errorf("diagnose replaced a corrupt meta for an encrypted notebook with %s", b)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package dal
 
 import (
+	"fmt"
+	"path"
+	"strings"
+
 	"github.com/subtlepseudonym/notes"
 )
 
@@ -24,3 +28,116 @@ type DAL interface {
 	SaveNote(*notes.Note) error
 	RemoveNote(int) error
 }
+
+// EncryptionChecker is implemented by DALs which may encrypt a notebook's
+// notes. Decorators which keep note contents elsewhere, such as a search
+// index, should skip encrypted notebooks so that their contents aren't
+// written to disk in the clear
+type EncryptionChecker interface {
+	IsEncrypted(notebook string) (bool, error)
+}
+
+// SealedPrefix begins note bodies and titles encrypted by the crypt package.
+// DALs can't open them, but need to recognize them
+const SealedPrefix = "$notes-aes256gcm$"
+
+// IsSealed determines whether the note field was encrypted
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, SealedPrefix)
+}
+
+// ValidateNotebookName checks that the name can be used as a notebook's name.
+// Notebook names are single path elements, so that a notebook can't be
+// created outside of the notes directory
+func ValidateNotebookName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("notebook name cannot be blank string")
+	case name[0] == '.':
+		return fmt.Errorf("notebook name cannot start with \".\"")
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("notebook name cannot contain path separators")
+	case strings.Contains(name, ".."), path.Clean(name) != name:
+		return fmt.Errorf("notebook name %q is not a single path element", name)
+	}
+	return nil
+}
+
+// NoteIDReserver is implemented by DALs which can reserve the next note ID in
+// the current notebook atomically, so that concurrent writers never reserve
+// the same ID
+type NoteIDReserver interface {
+	ReserveNoteID() (*notes.Meta, error)
+}
+
+// ReserveNoteID increments the current notebook's latest ID and saves its
+// meta, returning the saved meta. The reservation is only atomic if the DAL
+// implements NoteIDReserver
+func ReserveNoteID(d DAL) (*notes.Meta, error) {
+	if reserver, ok := d.(NoteIDReserver); ok {
+		return reserver.ReserveNoteID()
+	}
+
+	meta, err := d.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("get meta: %w", err)
+	}
+
+	id := meta.LatestID + 1
+	if _, err := d.GetNoteMeta(id); err == nil {
+		return nil, fmt.Errorf("note ID %d (%x) already exists", id, id)
+	}
+	meta.LatestID = id
+
+	err = setMetaSize(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.SaveMeta(meta)
+	if err != nil {
+		return nil, fmt.Errorf("save meta: %w", err)
+	}
+	return meta, nil
+}
+
+// MetaUpdater is implemented by DALs which can update the current notebook's
+// meta atomically, so that concurrent changes to the meta aren't lost
+type MetaUpdater interface {
+	UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error)
+}
+
+// UpdateMeta applies update to the current notebook's meta and saves it,
+// returning the saved meta. The update is only atomic if the DAL implements
+// MetaUpdater
+func UpdateMeta(d DAL, update func(*notes.Meta)) (*notes.Meta, error) {
+	if updater, ok := d.(MetaUpdater); ok {
+		return updater.UpdateMeta(update)
+	}
+
+	meta, err := d.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("get meta: %w", err)
+	}
+
+	update(meta)
+	err = setMetaSize(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.SaveMeta(meta)
+	if err != nil {
+		return nil, fmt.Errorf("save meta: %w", err)
+	}
+	return meta, nil
+}
+
+func setMetaSize(meta *notes.Meta) error {
+	size, err := meta.ApproxSize()
+	if err != nil {
+		return fmt.Errorf("approximate meta size: %w", err)
+	}
+	meta.Size = size
+	return nil
+}
@@ -9,9 +9,11 @@ import (
 )
 
 func TestNewLocalDAL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
 	version := "totally not a semantic version"
 	dir := "notes_test_dir"
-	dal, err := NewLocal(dir, version)
+	dal, err := NewLocal(dir, version, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -41,6 +43,25 @@ func TestNewLocalDAL(t *testing.T) {
 	}
 }
 
+func TestNewLocalDALAbsolutePath(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := path.Join(t.TempDir(), "notes")
+
+	d, err := NewLocal(dir, "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+
+	if d.(*local).baseDirectory != dir {
+		t.Errorf("expected base directory %q, got %q", dir, d.(*local).baseDirectory)
+	}
+
+	_, err = os.Stat(path.Join(dir, defaultNotebook, defaultMetaFilename))
+	if err != nil {
+		t.Errorf("expected default notebook in absolute directory: %s", err)
+	}
+}
+
 func TestLocalDALGetMeta(t *testing.T) {
 }
 
@@ -55,3 +76,27 @@ func TestLocalDALSaveNote(t *testing.T) {
 
 func TestLocalDALRemoveNote(t *testing.T) {
 }
+
+func TestValidateNotebookName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"work", true},
+		{"work.old", true},
+		{"", false},
+		{".hidden", false},
+		{"..", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"a/../../x", false},
+		{"a..b", false},
+	}
+
+	for _, test := range tests {
+		err := ValidateNotebookName(test.name)
+		if (err == nil) != test.valid {
+			t.Errorf("ValidateNotebookName(%q) returned %v, expected valid = %t", test.name, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+const (
+	defaultQuarantineDirectory = ".quarantine"
+	quarantineTimeFormat       = "20060102T150405Z"
+)
+
+// ProblemKind categorizes an inconsistency found by a Doctor
+type ProblemKind string
+
+const (
+	ProblemCorruptFile     ProblemKind = "corrupt-file"
+	ProblemMissingMeta     ProblemKind = "missing-meta"
+	ProblemMissingIndex    ProblemKind = "missing-index"
+	ProblemMissingNoteFile ProblemKind = "missing-note-file"
+	ProblemUnindexedNote   ProblemKind = "unindexed-note"
+	ProblemMetaMismatch    ProblemKind = "meta-mismatch"
+	ProblemLatestID        ProblemKind = "latest-id"
+	ProblemEncryptedMeta   ProblemKind = "encrypted-meta"
+)
+
+// Problem describes an inconsistency in a notebook's stored data
+type Problem struct {
+	Notebook    string      `json:"notebook"`
+	Kind        ProblemKind `json:"kind"`
+	File        string      `json:"file,omitempty"` // relative to the notebook directory
+	NoteID      int         `json:"noteID,omitempty"`
+	Detail      string      `json:"detail"`
+	Repaired    bool        `json:"repaired"`
+	Quarantined string      `json:"quarantined,omitempty"` // where a corrupt file was moved
+}
+
+// Doctor is implemented by DALs that can check their stored data for
+// inconsistencies and repair them
+type Doctor interface {
+	Diagnose(notebook string, repair bool) ([]Problem, error)
+}
+
+// Diagnose cross-checks the notebook's meta, index, and note files. If repair
+// is set, the index and meta are corrected to match the note files, and
+// corrupt files are moved to the quarantine directory rather than deleted.
+// The meta of a notebook with encrypted notes is never replaced, as it holds
+// the notebook's sealed data key. Backup files left by interrupted writes are
+// reconciled when the notebook is locked, before it's diagnosed
+func (d *local) Diagnose(notebook string, repair bool) ([]Problem, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var problems []Problem
+	err := d.withNotebookLock(notebook, func() error {
+		var err error
+		problems, err = d.diagnose(notebook, repair)
+		return err
+	})
+	if err != nil {
+		return problems, err
+	}
+
+	return problems, nil
+}
+
+// diagnose performs the checks for Diagnose. The caller must hold the
+// notebook's lock
+func (d *local) diagnose(notebook string, repair bool) ([]Problem, error) {
+	notebookPath := path.Join(d.baseDirectory, notebook)
+	entries, err := os.ReadDir(notebookPath)
+	if err != nil {
+		return nil, fmt.Errorf("read notebook directory: %w", err)
+	}
+
+	var problems []Problem
+	report := func(problem Problem) {
+		problem.Notebook = notebook
+		problems = append(problems, problem)
+	}
+
+	quarantine := func(problem Problem) error {
+		if repair {
+			destination, err := d.quarantine(notebook, problem.File)
+			if err != nil {
+				return fmt.Errorf("quarantine %q: %w", problem.File, err)
+			}
+			problem.Repaired = true
+			problem.Quarantined = destination
+		}
+		report(problem)
+		return nil
+	}
+
+	files := make(map[int]notes.NoteMeta)
+	var sealed bool // whether any note is encrypted
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		if !noteFilenamePattern.MatchString(name) {
+			continue
+		}
+
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+
+		note, err := readNote(path.Join(notebookPath, name))
+		if err == nil && note.Meta.ID != id {
+			err = fmt.Errorf("note ID %x doesn't match file name", note.Meta.ID)
+		}
+		if err != nil {
+			err = quarantine(Problem{
+				Kind:   ProblemCorruptFile,
+				File:   name,
+				NoteID: id,
+				Detail: err.Error(),
+			})
+			if err != nil {
+				return problems, err
+			}
+			continue
+		}
+
+		files[id] = note.Meta
+		if IsSealed(note.Body) || IsSealed(note.Meta.Title) {
+			sealed = true
+		}
+	}
+
+	// the meta of an encrypted notebook holds its sealed data key, without
+	// which its notes can't be read, so it's never replaced
+	metaPath := path.Join(notebookPath, d.metaFilename)
+	meta, err := readMeta(metaPath)
+	var metaChanged bool
+	switch {
+	case err != nil && sealed:
+		report(Problem{
+			Kind:   ProblemEncryptedMeta,
+			File:   d.metaFilename,
+			Detail: fmt.Sprintf("notebook has encrypted notes, so its meta can't be rebuilt, restore it from a backup: %v", err),
+		})
+		meta = nil
+	case errors.Is(err, os.ErrNotExist):
+		report(Problem{
+			Kind:     ProblemMissingMeta,
+			File:     d.metaFilename,
+			Detail:   "meta file not found",
+			Repaired: repair,
+		})
+		meta, metaChanged = &notes.Meta{Version: d.version}, true
+	case err != nil:
+		err = quarantine(Problem{
+			Kind:   ProblemCorruptFile,
+			File:   d.metaFilename,
+			Detail: err.Error(),
+		})
+		if err != nil {
+			return problems, err
+		}
+		meta, metaChanged = &notes.Meta{Version: d.version}, true
+	}
+
+	indexPath := path.Join(notebookPath, d.indexFilename)
+	index, err := loadIndex(indexPath)
+	indexChanged := true
+	if errors.Is(err, os.ErrNotExist) {
+		report(Problem{
+			Kind:     ProblemMissingIndex,
+			File:     d.indexFilename,
+			Detail:   "index file not found",
+			Repaired: repair,
+		})
+	} else if err != nil {
+		err = quarantine(Problem{
+			Kind:   ProblemCorruptFile,
+			File:   d.indexFilename,
+			Detail: err.Error(),
+		})
+		if err != nil {
+			return problems, err
+		}
+	} else {
+		indexChanged = false
+	}
+
+	if indexChanged {
+		// the index is rebuilt from the note files, so there's nothing to
+		// cross-check
+		index = make(map[int]notes.NoteMeta, len(files))
+		for id, noteMeta := range files {
+			index[id] = noteMeta
+		}
+	}
+
+	for _, id := range sortedIDs(index) {
+		if _, ok := files[id]; ok {
+			continue
+		}
+
+		report(Problem{
+			Kind:     ProblemMissingNoteFile,
+			File:     fmt.Sprintf(d.noteFilenameFormat, id),
+			NoteID:   id,
+			Detail:   "note is indexed, but its file doesn't exist",
+			Repaired: repair,
+		})
+		delete(index, id)
+		indexChanged = true
+	}
+
+	for _, id := range sortedIDs(files) {
+		indexed, ok := index[id]
+		if !ok {
+			report(Problem{
+				Kind:     ProblemUnindexedNote,
+				File:     fmt.Sprintf(d.noteFilenameFormat, id),
+				NoteID:   id,
+				Detail:   "note file exists, but isn't indexed",
+				Repaired: repair,
+			})
+		} else if !sameNoteMeta(indexed, files[id]) {
+			report(Problem{
+				Kind:     ProblemMetaMismatch,
+				File:     fmt.Sprintf(d.noteFilenameFormat, id),
+				NoteID:   id,
+				Detail:   "indexed note meta doesn't match the note file",
+				Repaired: repair,
+			})
+		} else {
+			continue
+		}
+
+		index[id] = files[id]
+		indexChanged = true
+	}
+
+	var maxID int
+	for id := range index {
+		if id > maxID {
+			maxID = id
+		}
+	}
+
+	if meta != nil && meta.LatestID < maxID {
+		report(Problem{
+			Kind:     ProblemLatestID,
+			File:     d.metaFilename,
+			Detail:   fmt.Sprintf("latest ID %x is lower than note ID %x", meta.LatestID, maxID),
+			Repaired: repair,
+		})
+		meta.LatestID = maxID
+		metaChanged = true
+	}
+
+	if !repair {
+		return problems, nil
+	}
+
+	if indexChanged {
+		err = saveIndex(indexPath, index)
+		if err != nil {
+			return problems, fmt.Errorf("save index: %w", err)
+		}
+		d.indexes[notebook] = index
+		d.recordIndexInfo(notebook)
+	}
+
+	if metaChanged {
+		size, err := meta.ApproxSize()
+		if err != nil {
+			return problems, fmt.Errorf("approximate meta size: %w", err)
+		}
+		meta.Size = size
+
+		err = writeJSONAtomic(metaPath, meta)
+		if err != nil {
+			return problems, fmt.Errorf("write meta file: %w", err)
+		}
+	}
+
+	return problems, nil
+}
+
+// quarantine moves a file out of the notebook directory and into the
+// quarantine directory, returning its new path. A timestamp is appended to
+// the file name so that earlier quarantined files aren't overwritten
+func (d *local) quarantine(notebook, filename string) (string, error) {
+	quarantinePath := path.Join(d.baseDirectory, defaultQuarantineDirectory, notebook)
+	err := os.MkdirAll(quarantinePath, os.ModeDir|os.FileMode(0700))
+	if err != nil {
+		return "", fmt.Errorf("create quarantine directory: %w", err)
+	}
+
+	destination := path.Join(quarantinePath, filename+"."+time.Now().UTC().Format(quarantineTimeFormat))
+	err = os.Rename(path.Join(d.baseDirectory, notebook, filename), destination)
+	if err != nil {
+		return "", fmt.Errorf("move file: %w", err)
+	}
+
+	return destination, nil
+}
+
+// readNote decodes the note file at notePath
+func readNote(notePath string) (*notes.Note, error) {
+	b, err := os.ReadFile(notePath)
+	if err != nil {
+		return nil, fmt.Errorf("read note file: %w", err)
+	}
+
+	var note notes.Note
+	err = json.Unmarshal(b, &note)
+	if err != nil {
+		return nil, fmt.Errorf("decode note file: %w", err)
+	}
+	return &note, nil
+}
+
+// readMeta decodes the meta file at metaPath
+func readMeta(metaPath string) (*notes.Meta, error) {
+	b, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, fmt.Errorf("read meta file: %w", err)
+	}
+
+	var meta notes.Meta
+	err = json.Unmarshal(b, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("decode meta file: %w", err)
+	}
+	return &meta, nil
+}
+
+// sameNoteMeta compares note metas by their encoded form
+func sameNoteMeta(a, b notes.NoteMeta) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// sortedIDs lists the index's note IDs in ascending order
+func sortedIDs(index map[int]notes.NoteMeta) []int {
+	ids := make([]int, 0, len(index))
+	for id := range index {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,85 @@
+package dal
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+func TestLocalDiagnose(t *testing.T) {
+	d := newTestLocal(t)
+
+	for id := 1; id <= 3; id++ {
+		note := &notes.Note{
+			Meta: notes.NoteMeta{
+				ID:      id,
+				Title:   "note",
+				Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+			},
+		}
+
+		err := d.SaveNote(note)
+		if err != nil {
+			t.Fatalf("save note %d: %s", id, err)
+		}
+	}
+
+	notebookPath := path.Join(d.baseDirectory, defaultNotebook)
+	err := os.WriteFile(path.Join(notebookPath, "000002"), []byte("{"), 0644)
+	if err != nil {
+		t.Fatalf("corrupt note file: %s", err)
+	}
+
+	expected := []ProblemKind{
+		ProblemCorruptFile,
+		ProblemMissingNoteFile,
+		ProblemLatestID,
+	}
+
+	for _, repair := range []bool{false, true} {
+		problems, err := d.Diagnose(defaultNotebook, repair)
+		if err != nil {
+			t.Fatalf("diagnose with repair=%t: %s", repair, err)
+		}
+
+		if len(problems) != len(expected) {
+			t.Fatalf("diagnose with repair=%t found %d problems, expected %d: %+v", repair, len(problems), len(expected), problems)
+		}
+		for i, problem := range problems {
+			if problem.Kind != expected[i] || problem.Repaired != repair {
+				t.Errorf("problem %d = %s (repaired %t), expected %s (repaired %t)", i, problem.Kind, problem.Repaired, expected[i], repair)
+			}
+		}
+	}
+
+	problems, err := d.Diagnose(defaultNotebook, false)
+	if err != nil {
+		t.Fatalf("diagnose after repair: %s", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("diagnose after repair found problems: %+v", problems)
+	}
+
+	quarantined, err := os.ReadDir(path.Join(d.baseDirectory, defaultQuarantineDirectory, defaultNotebook))
+	if err != nil || len(quarantined) != 1 {
+		t.Errorf("quarantine directory contains %d files, expected 1 (err: %v)", len(quarantined), err)
+	}
+
+	b, err := os.ReadFile(path.Join(notebookPath, defaultMetaFilename))
+	if err != nil {
+		t.Fatalf("read meta: %s", err)
+	}
+
+	var meta notes.Meta
+	err = json.Unmarshal(b, &meta)
+	if err != nil {
+		t.Fatalf("decode meta: %s", err)
+	}
+	if meta.LatestID != 3 {
+		t.Errorf("latest ID = %d, expected 3", meta.LatestID)
+	}
+}
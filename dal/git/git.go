@@ -0,0 +1,365 @@
+// Package git implements a DAL decorator which keeps the local DAL's base
+// directory in a git repository, committing after every change
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+
+	"go.uber.org/zap"
+)
+
+const (
+	gitignoreFilename = ".gitignore"
+	defaultAuthorName = "notes"
+	defaultAuthorMail = "notes@localhost"
+)
+
+// gitignoreHeader marks a gitignore written by notes, which is kept up to
+// date with gitignoreEntries. A gitignore without it is left alone
+const gitignoreHeader = "# written by notes"
+
+// gitignoreEntries keeps lock files, interrupted writes, backups, index
+// snapshots, search indexes, quarantined files, and the command history, log,
+// saved notebook, and gc timestamp out of the repository
+var gitignoreEntries = []string{
+	".lock",
+	".*.tmp-*",
+	"*.bak",
+	".index.snapshot",
+	".search/",
+	".quarantine/",
+	".nts_history",
+	".nts_log",
+	".nts_notebook",
+	".nts_gc",
+}
+
+// ErrGitNotFound indicates that no git binary could be found in PATH
+var ErrGitNotFound = errors.New("git binary not found")
+
+// Repository wraps a DAL, committing the changes made through it to a git
+// repository
+type Repository interface {
+	dal.DAL
+	Log(notebook string, id int) ([]Commit, error)
+}
+
+// Commit describes a single commit touching a note
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// repository commits after each call that changes notes, meta, or notebooks.
+// A failed commit doesn't fail the change, which is already on disk, so it's
+// logged instead and the change is included in the next commit
+type repository struct {
+	dal.DAL
+	mu        sync.Mutex
+	directory string
+	identity  []string // config args used when no author is configured
+	logger    *zap.Logger
+}
+
+// New initializes directory as a git repository, if it isn't one already,
+// and returns a Repository committing changes made through d to it. The
+// directory must be the base directory of the local DAL. If logger is nil,
+// nothing is logged
+func New(d dal.DAL, directory string, logger *zap.Logger) (Repository, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	_, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGitNotFound, err)
+	}
+
+	r := &repository{
+		DAL:       d,
+		directory: directory,
+		logger:    logger,
+	}
+
+	_, err = os.Stat(path.Join(directory, ".git"))
+	initialize := os.IsNotExist(err)
+	if err != nil && !initialize {
+		return nil, fmt.Errorf("stat git directory: %w", err)
+	}
+
+	if initialize {
+		_, err = r.run("init", "--quiet")
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("initialized git repository", zap.String("directory", directory))
+	}
+
+	// commits shouldn't fail for want of an author, so one is provided if
+	// neither the repository nor the user's config has one
+	email, _ := r.run("config", "user.email")
+	if strings.TrimSpace(email) == "" {
+		r.identity = []string{
+			"-c", "user.name=" + defaultAuthorName,
+			"-c", "user.email=" + defaultAuthorMail,
+		}
+	}
+
+	added, err := writeGitignore(path.Join(directory, gitignoreFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	if initialize {
+		err = r.commit("initialize notes repository", ".")
+		if err != nil {
+			return nil, err
+		}
+	} else if len(added) > 0 {
+		// files matching the new entries may have been committed before they
+		// were ignored, and would otherwise keep being committed
+		_, err = r.run(append([]string{"rm", "-r", "--cached", "--quiet", "--ignore-unmatch", "--"}, added...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.commit(fmt.Sprintf("update %s", gitignoreFilename), gitignoreFilename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// writeGitignore writes the gitignore at ignorePath if it doesn't exist, or
+// appends the entries missing from one previously written by notes. It
+// returns the entries added to an existing gitignore
+func writeGitignore(ignorePath string) ([]string, error) {
+	content, err := os.ReadFile(ignorePath)
+	if os.IsNotExist(err) {
+		content := gitignoreHeader + "\n" + strings.Join(gitignoreEntries, "\n") + "\n"
+		err = os.WriteFile(ignorePath, []byte(content), 0644)
+		if err != nil {
+			return nil, fmt.Errorf("write %s: %w", gitignoreFilename, err)
+		}
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read %s: %w", gitignoreFilename, err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if strings.TrimSpace(lines[0]) != gitignoreHeader {
+		return nil, nil
+	}
+
+	existing := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	var added []string
+	for _, entry := range gitignoreEntries {
+		if !existing[entry] {
+			added = append(added, entry)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, strings.Join(added, "\n")+"\n"...)
+
+	err = os.WriteFile(ignorePath, content, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("write %s: %w", gitignoreFilename, err)
+	}
+	return added, nil
+}
+
+// run calls git in the repository directory, returning its output
+func (r *repository) run(args ...string) (string, error) {
+	cmd := exec.Command("git", append(append([]string{"-C", r.directory}, r.identity...), args...)...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return stdout.String(), fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// commit stages all changes under paths and commits them with the provided
+// message. Nothing is committed if nothing has changed. Notebook operations
+// stage the whole directory because a removed notebook which was never
+// committed can't be named as a path
+func (r *repository) commit(message string, paths ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.run(append([]string{"add", "--all", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+
+	// diff exits with status 1 when there are staged changes
+	_, err = r.run("diff", "--cached", "--quiet")
+	var exitErr *exec.ExitError
+	if err == nil {
+		return nil
+	} else if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return err
+	}
+
+	_, err = r.run("commit", "--quiet", "--message", message)
+	return err
+}
+
+// record commits the changes made by a successful call, logging rather than
+// returning commit errors
+func (r *repository) record(message string, paths ...string) {
+	err := r.commit(message, paths...)
+	if err != nil {
+		r.logger.Error("commit change", zap.String("message", message), zap.Error(err))
+		return
+	}
+	r.logger.Debug("committed change", zap.String("message", message))
+}
+
+func (r *repository) SaveMeta(meta *notes.Meta) error {
+	err := r.DAL.SaveMeta(meta)
+	if err != nil {
+		return err
+	}
+
+	notebook := r.DAL.GetNotebook()
+	r.record(fmt.Sprintf("save meta in %s", notebook), notebook)
+	return nil
+}
+
+func (r *repository) ReserveNoteID() (*notes.Meta, error) {
+	meta, err := dal.ReserveNoteID(r.DAL)
+	if err != nil {
+		return nil, err
+	}
+
+	notebook := r.DAL.GetNotebook()
+	r.record(fmt.Sprintf("save meta in %s", notebook), notebook)
+	return meta, nil
+}
+
+func (r *repository) UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error) {
+	meta, err := dal.UpdateMeta(r.DAL, update)
+	if err != nil {
+		return nil, err
+	}
+
+	notebook := r.DAL.GetNotebook()
+	r.record(fmt.Sprintf("save meta in %s", notebook), notebook)
+	return meta, nil
+}
+
+func (r *repository) CreateNotebook(name string) error {
+	err := r.DAL.CreateNotebook(name)
+	if err != nil {
+		return err
+	}
+
+	r.record(fmt.Sprintf("create notebook %s", name), ".")
+	return nil
+}
+
+func (r *repository) RenameNotebook(oldName, newName string) error {
+	err := r.DAL.RenameNotebook(oldName, newName)
+	if err != nil {
+		return err
+	}
+
+	r.record(fmt.Sprintf("rename notebook %s to %s", oldName, newName), ".")
+	return nil
+}
+
+func (r *repository) RemoveNotebook(name string, recursive bool) error {
+	err := r.DAL.RemoveNotebook(name, recursive)
+	if err != nil {
+		return err
+	}
+
+	r.record(fmt.Sprintf("remove notebook %s", name), ".")
+	return nil
+}
+
+func (r *repository) SaveNote(note *notes.Note) error {
+	err := r.DAL.SaveNote(note)
+	if err != nil {
+		return err
+	}
+
+	notebook := r.DAL.GetNotebook()
+	r.record(fmt.Sprintf("save note %x in %s", note.Meta.ID, notebook), notebook)
+	return nil
+}
+
+func (r *repository) RemoveNote(id int) error {
+	err := r.DAL.RemoveNote(id)
+	if err != nil {
+		return err
+	}
+
+	notebook := r.DAL.GetNotebook()
+	r.record(fmt.Sprintf("remove note %x from %s", id, notebook), notebook)
+	return nil
+}
+
+// Log lists the commits which changed the note's file, newest first
+func (r *repository) Log(notebook string, id int) ([]Commit, error) {
+	out, err := r.run("log", "--follow", "--format=%H%x00%an%x00%aI%x00%s", "--", dal.LocalNotePath(notebook, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("parse git log line %q", line)
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("parse commit date: %w", err)
+		}
+
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    date,
+			Subject: fields[3],
+		})
+	}
+
+	return commits, nil
+}
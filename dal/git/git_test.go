@@ -0,0 +1,205 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+func newTestRepository(t *testing.T) *repository {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	local, err := dal.NewLocal("notes", "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+
+	r, err := New(local, path.Join(home, "notes"), nil)
+	if err != nil {
+		t.Fatalf("new repository: %s", err)
+	}
+	return r.(*repository)
+}
+
+func subjects(t *testing.T, r *repository, args ...string) []string {
+	t.Helper()
+
+	out, err := r.run(append([]string{"log", "--format=%s"}, args...)...)
+	if err != nil {
+		t.Fatalf("git log: %s", err)
+	}
+	return strings.Split(strings.TrimSpace(out), "\n")
+}
+
+func TestRepositoryCommitsChanges(t *testing.T) {
+	r := newTestRepository(t)
+
+	note := &notes.Note{
+		Meta: notes.NoteMeta{
+			ID:      26,
+			Title:   "note",
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+		},
+		Body: "first",
+	}
+	if err := r.SaveNote(note); err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+
+	note.Body = "second"
+	if err := r.SaveNote(note); err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+
+	// saving an unchanged note shouldn't create an empty commit
+	if err := r.SaveNote(note); err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+
+	if err := r.CreateNotebook("work"); err != nil {
+		t.Fatalf("create notebook: %s", err)
+	}
+
+	if err := r.RemoveNote(26); err != nil {
+		t.Fatalf("remove note: %s", err)
+	}
+
+	expected := []string{
+		"remove note 1a from default",
+		"create notebook work",
+		"save note 1a in default",
+		"save note 1a in default",
+		"initialize notes repository",
+	}
+	actual := subjects(t, r)
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected commits %q, got %q", expected, actual)
+	}
+
+	// runtime files must not be committed
+	out, err := r.run("ls-files")
+	if err != nil {
+		t.Fatalf("git ls-files: %s", err)
+	}
+	if strings.Contains(out, ".lock") {
+		t.Errorf("lock files were committed:\n%s", out)
+	}
+
+	commits, err := r.Log("default", 26)
+	if err != nil {
+		t.Fatalf("log: %s", err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("expected 3 commits for note, got %d: %+v", len(commits), commits)
+	}
+	if commits[0].Subject != "remove note 1a from default" {
+		t.Errorf("expected newest commit first, got %q", commits[0].Subject)
+	}
+	if commits[0].Author != defaultAuthorName {
+		t.Errorf("expected author %q, got %q", defaultAuthorName, commits[0].Author)
+	}
+}
+
+func TestRepositoryExistingRepository(t *testing.T) {
+	r := newTestRepository(t)
+
+	// reopening the directory shouldn't initialize it again
+	again, err := New(r.DAL, r.directory, nil)
+	if err != nil {
+		t.Fatalf("new repository: %s", err)
+	}
+
+	meta, err := again.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	meta.LatestID = 3
+	if err := again.SaveMeta(meta); err != nil {
+		t.Fatalf("save meta: %s", err)
+	}
+
+	expected := []string{"save meta in default", "initialize notes repository"}
+	actual := subjects(t, r)
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected commits %q, got %q", expected, actual)
+	}
+}
+
+func TestRepositoryUpdatesGitignore(t *testing.T) {
+	r := newTestRepository(t)
+	ignorePath := path.Join(r.directory, gitignoreFilename)
+
+	// a gitignore written before .nts_gc was ignored, which let it be committed
+	old := gitignoreHeader + "\n" + strings.Join(gitignoreEntries[:len(gitignoreEntries)-1], "\n") + "\nmine"
+	if err := os.WriteFile(ignorePath, []byte(old), 0644); err != nil {
+		t.Fatalf("write gitignore: %s", err)
+	}
+	if err := os.WriteFile(path.Join(r.directory, ".nts_gc"), []byte("0"), 0644); err != nil {
+		t.Fatalf("write gc file: %s", err)
+	}
+	if err := r.commit("commit gc file", "."); err != nil {
+		t.Fatalf("commit: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := New(r.DAL, r.directory, nil); err != nil {
+			t.Fatalf("new repository: %s", err)
+		}
+	}
+
+	content, err := os.ReadFile(ignorePath)
+	if err != nil {
+		t.Fatalf("read gitignore: %s", err)
+	}
+	expected := old + "\n.nts_gc\n"
+	if string(content) != expected {
+		t.Errorf("expected gitignore %q, got %q", expected, content)
+	}
+
+	tracked, err := r.run("ls-files", "--", ".nts_gc")
+	if err != nil {
+		t.Fatalf("git ls-files: %s", err)
+	}
+	if tracked != "" {
+		t.Errorf("expected .nts_gc to be untracked")
+	}
+
+	expectedSubjects := []string{"update .gitignore", "commit gc file", "initialize notes repository"}
+	actual := subjects(t, r)
+	if strings.Join(actual, "\n") != strings.Join(expectedSubjects, "\n") {
+		t.Errorf("expected commits %q, got %q", expectedSubjects, actual)
+	}
+}
+
+func TestRepositoryKeepsUserGitignore(t *testing.T) {
+	r := newTestRepository(t)
+	ignorePath := path.Join(r.directory, gitignoreFilename)
+
+	if err := os.WriteFile(ignorePath, []byte("*.bak\n"), 0644); err != nil {
+		t.Fatalf("write gitignore: %s", err)
+	}
+	if _, err := New(r.DAL, r.directory, nil); err != nil {
+		t.Fatalf("new repository: %s", err)
+	}
+
+	content, err := os.ReadFile(ignorePath)
+	if err != nil {
+		t.Fatalf("read gitignore: %s", err)
+	}
+	if string(content) != "*.bak\n" {
+		t.Errorf("expected gitignore to be unchanged, got %q", content)
+	}
+}
@@ -7,21 +7,41 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"sync"
+	"time"
 
 	"github.com/subtlepseudonym/notes"
+
+	"go.uber.org/zap"
 )
 
 const (
 	defaultNotebook           = "default"
 	defaultMetaFilename       = "meta"
 	defaultIndexFilename      = "index"
+	defaultLockFilename       = ".lock"
 	defaultNoteFilenameFormat = "%06d"
-	noteFilenameRegex         = `[0-9]{6}`
+	noteFilenameRegex         = `^[0-9]{6,}$`
 	defaultIndexCapacity      = 256
+	defaultLockTimeout        = 5 * time.Second
+	lockRetryInterval         = 50 * time.Millisecond
 )
 
+// ErrNotebookLocked indicates that another process held a notebook's lock for
+// longer than the lock timeout
+var ErrNotebookLocked = errors.New("notebook is locked by another process")
+
+// ErrLocked indicates that another process held a file's lock for longer
+// than the lock timeout
+var ErrLocked = errors.New("locked by another process")
+
+// errLockHeld is returned by lockFile when the lock is held elsewhere
+var errLockHeld = errors.New("lock held")
+
+var noteFilenamePattern = regexp.MustCompile(noteFilenameRegex)
+
 type local struct {
 	sync.Mutex
 	baseDirectory      string
@@ -30,18 +50,44 @@ type local struct {
 	metaFilename       string
 	noteFilenameFormat string
 	version            string
+	lockTimeout        time.Duration
+	indexSnapshots     bool
+	logger             *zap.Logger
+
+	// notebooks are reconciled and their indexes loaded when they're first
+	// used, rather than when the DAL is initialized
+	indexes    map[string]map[int]notes.NoteMeta // map notebook name to map of IDs to NoteMeta
+	indexInfos map[string]os.FileInfo            // map notebook name to index file info as of loading
+	reconciled map[string]bool                   // notebooks reconciled since the DAL was initialized
+}
 
-	indexes map[string]map[int]notes.NoteMeta // map notebook name to map of IDs to NoteMeta
+// LocalOptions configure a local DAL
+type LocalOptions struct {
+	// IndexSnapshots keeps a binary snapshot of each notebook's index beside
+	// the JSON index, which is loaded in its place. A snapshot that doesn't
+	// match the index causes the index to be rebuilt from the note files
+	IndexSnapshots bool
 }
 
-// NewLocal initializes a DAL with the default options
-func NewLocal(dirName, version string) (DAL, error) {
-	home, err := os.UserHomeDir()
+// NewLocal initializes a DAL with the default options, storing notebooks in
+// the provided directory. A relative directory is relative to the user's home
+// directory. If logger is nil, nothing is logged
+func NewLocal(dirName, version string, logger *zap.Logger) (DAL, error) {
+	return NewLocalWithOptions(dirName, version, logger, LocalOptions{})
+}
+
+// NewLocalWithOptions initializes a DAL as NewLocal does, with the provided
+// options
+func NewLocalWithOptions(dirName, version string, logger *zap.Logger, options LocalOptions) (DAL, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	baseDirectory, err := resolveDirectory(dirName)
 	if err != nil {
-		return nil, fmt.Errorf("get home directory: %v", err)
+		return nil, err
 	}
 
-	baseDirectory := path.Join(home, dirName)
 	err = createDirectory(baseDirectory)
 	if err != nil {
 		return nil, fmt.Errorf("create base directory: %v", err)
@@ -53,57 +99,178 @@ func NewLocal(dirName, version string) (DAL, error) {
 		return nil, fmt.Errorf("create notebook directory: %v", err)
 	}
 
-	metaPath := path.Join(notebookDirectory, defaultMetaFilename)
-	_, err = os.Stat(metaPath)
-	if os.IsNotExist(err) {
-		err = buildMeta(baseDirectory, defaultNotebook, version)
-		if err != nil {
-			return nil, fmt.Errorf("build meta: %v", err)
+	d := &local{
+		baseDirectory:      baseDirectory,
+		notebook:           defaultNotebook,
+		metaFilename:       defaultMetaFilename,
+		indexFilename:      defaultIndexFilename,
+		noteFilenameFormat: defaultNoteFilenameFormat,
+		version:            version,
+		lockTimeout:        defaultLockTimeout,
+		indexSnapshots:     options.IndexSnapshots,
+		logger:             logger,
+		indexes:            make(map[string]map[int]notes.NoteMeta),
+		indexInfos:         make(map[string]os.FileInfo),
+		reconciled:         make(map[string]bool),
+	}
+
+	err = d.withNotebookLock(defaultNotebook, func() error {
+		metaPath := path.Join(notebookDirectory, defaultMetaFilename)
+		_, err := os.Stat(metaPath)
+		if os.IsNotExist(err) {
+			err = buildMeta(baseDirectory, defaultNotebook, version)
+			if err != nil {
+				return fmt.Errorf("build meta: %v", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("stat meta: %v", err)
 		}
-	} else if err != nil {
-		return nil, fmt.Errorf("stat meta: %v", err)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	baseDir, err := os.Open(baseDirectory)
-	if err != nil {
-		return nil, fmt.Errorf("stat base directory: %v", err)
+	return d, nil
+}
+
+// withNotebookLock calls fn while holding the notebook's advisory lock, which
+// guards the notebook's files against concurrent changes by other processes.
+// If the lock is held elsewhere, acquiring it is retried until the lock
+// timeout elapses. The first time a notebook is locked, writes to it that
+// were interrupted by a crash are reconciled
+func (d *local) withNotebookLock(notebook string, fn func() error) error {
+	lockPath := path.Join(d.baseDirectory, notebook, defaultLockFilename)
+	err := WithFileLock(lockPath, d.lockTimeout, func() error {
+		if !d.reconciled[notebook] {
+			err := reconcileNotebook(path.Join(d.baseDirectory, notebook))
+			if err != nil {
+				return fmt.Errorf("reconcile notebook %q: %w", notebook, err)
+			}
+			d.reconciled[notebook] = true
+		}
+
+		return fn()
+	})
+	if errors.Is(err, ErrLocked) {
+		return fmt.Errorf("lock notebook %q: %w", notebook, ErrNotebookLocked)
 	}
+	return err
+}
 
-	notebookInfos, err := baseDir.Readdir(0)
+// WithFileLock calls fn while holding an exclusive advisory lock on the file
+// at lockPath, which is created if it doesn't exist. If the lock is held
+// elsewhere, acquiring it is retried until timeout elapses, after which an
+// error wrapping ErrLocked is returned
+func WithFileLock(lockPath string, timeout time.Duration, fn func() error) error {
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
-		return nil, fmt.Errorf("read base directory contents: %v", err)
+		return fmt.Errorf("open lock file: %w", err)
 	}
+	defer file.Close()
 
-	indexes := make(map[string]map[int]notes.NoteMeta, len(notebookInfos))
-	for _, info := range notebookInfos {
-		notebook := info.Name()
-		if !info.IsDir() || IsHidden(notebook) {
-			continue
+	deadline := time.Now().Add(timeout)
+	for {
+		err = lockFile(file)
+		if err == nil {
+			break
 		}
 
-		var index map[int]notes.NoteMeta
-		index, err = loadIndex(path.Join(baseDirectory, notebook, defaultIndexFilename))
-		if errors.Is(err, os.ErrNotExist) {
-			index, err = buildIndex(baseDirectory, notebook)
-			if err != nil {
-				return nil, fmt.Errorf("build index for %q: %v", notebook, err)
-			}
-		} else if err != nil {
-			return nil, fmt.Errorf("stat index for %q: %v", notebook, err)
+		if !errors.Is(err, errLockHeld) {
+			return fmt.Errorf("lock file: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("lock %s: %w", path.Base(lockPath), ErrLocked)
 		}
+		time.Sleep(lockRetryInterval)
+	}
+	defer unlockFile(file)
 
-		indexes[notebook] = index
+	return fn()
+}
+
+// refreshIndex gets the notebook's index, loading it from file if it hasn't
+// been loaded yet or the index file has changed since it was last loaded or
+// saved by this DAL. The caller must hold the notebook's lock
+func (d *local) refreshIndex(notebook string) (map[int]notes.NoteMeta, error) {
+	indexPath := path.Join(d.baseDirectory, notebook, d.indexFilename)
+	info, err := os.Stat(indexPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return d.rebuildIndex(notebook)
+	} else if err != nil {
+		return nil, fmt.Errorf("stat index: %w", err)
+	}
+
+	index, ok := d.indexes[notebook]
+	loaded := d.indexInfos[notebook]
+	if ok && loaded != nil && os.SameFile(loaded, info) && loaded.ModTime().Equal(info.ModTime()) && loaded.Size() == info.Size() {
+		return index, nil
+	}
+
+	if d.indexSnapshots {
+		index, err = loadSnapshot(path.Join(d.baseDirectory, notebook, defaultSnapshotFilename), info)
+		switch {
+		case err == nil:
+			d.indexes[notebook] = index
+			d.indexInfos[notebook] = info
+			return index, nil
+		case errors.Is(err, errSnapshotMismatch):
+			d.logger.Info("rebuilding index", zap.String("notebook", notebook), zap.Error(err))
+			return d.rebuildIndex(notebook)
+		case !errors.Is(err, os.ErrNotExist):
+			d.logger.Warn("load index snapshot", zap.String("notebook", notebook), zap.Error(err))
+		}
 	}
 
-	return &local{
-		baseDirectory:      path.Join(home, dirName),
-		notebook:           defaultNotebook,
-		metaFilename:       defaultMetaFilename,
-		indexFilename:      defaultIndexFilename,
-		noteFilenameFormat: defaultNoteFilenameFormat,
-		version:            version,
-		indexes:            indexes,
-	}, nil
+	index, err = loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	d.indexes[notebook] = index
+	d.indexInfos[notebook] = info
+	d.snapshotIndex(notebook, info)
+	return index, nil
+}
+
+// rebuildIndex builds the notebook's index from its note files. The caller
+// must hold the notebook's lock
+func (d *local) rebuildIndex(notebook string) (map[int]notes.NoteMeta, error) {
+	index, err := buildIndex(d.baseDirectory, notebook, d.logger)
+	if err != nil {
+		return nil, fmt.Errorf("build index: %w", err)
+	}
+
+	d.indexes[notebook] = index
+	d.recordIndexInfo(notebook)
+	return index, nil
+}
+
+// recordIndexInfo notes the state of the notebook's index file after this
+// DAL has written it, so that it isn't needlessly reloaded, and snapshots the
+// index if snapshots are enabled
+func (d *local) recordIndexInfo(notebook string) {
+	info, err := os.Stat(path.Join(d.baseDirectory, notebook, d.indexFilename))
+	if err != nil {
+		delete(d.indexInfos, notebook)
+		return
+	}
+	d.indexInfos[notebook] = info
+	d.snapshotIndex(notebook, info)
+}
+
+// snapshotIndex writes a snapshot of the notebook's index, if snapshots are
+// enabled. Failing to write one is only logged, as the JSON index is current
+// and a stale snapshot won't match it
+func (d *local) snapshotIndex(notebook string, indexInfo os.FileInfo) {
+	if !d.indexSnapshots {
+		return
+	}
+
+	err := writeSnapshot(path.Join(d.baseDirectory, notebook, defaultSnapshotFilename), d.indexes[notebook], indexInfo)
+	if err != nil {
+		d.logger.Warn("write index snapshot", zap.String("notebook", notebook), zap.Error(err))
+	}
 }
 
 // GetMeta retrieves and decodes a Meta from file
@@ -111,8 +278,22 @@ func (d *local) GetMeta() (*notes.Meta, error) {
 	d.Lock()
 	defer d.Unlock()
 
-	notebookDirectory := path.Join(d.baseDirectory, d.notebook)
-	metaPath := path.Join(notebookDirectory, d.metaFilename)
+	var m *notes.Meta
+	err := d.withNotebookLock(d.notebook, func() error {
+		var err error
+		m, err = d.readMeta(d.notebook)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readMeta decodes the notebook's meta file. The caller must hold the
+// notebook's lock
+func (d *local) readMeta(notebook string) (*notes.Meta, error) {
+	metaPath := path.Join(d.baseDirectory, notebook, d.metaFilename)
 	metaFile, err := os.Open(metaPath)
 	if err != nil {
 		return nil, fmt.Errorf("open meta file: %v", err)
@@ -127,7 +308,7 @@ func (d *local) GetMeta() (*notes.Meta, error) {
 
 	err = metaFile.Close()
 	if err != nil {
-		return &m, fmt.Errorf("close meta file: %v", err)
+		return nil, fmt.Errorf("close meta file: %v", err)
 	}
 	return &m, nil
 }
@@ -137,64 +318,121 @@ func (d *local) SaveMeta(meta *notes.Meta) error {
 	d.Lock()
 	defer d.Unlock()
 
-	notebookDirectory := path.Join(d.baseDirectory, d.notebook)
-	metaPath := path.Join(notebookDirectory, d.metaFilename)
-	err := os.Rename(metaPath, metaPath+".bak")
+	return d.withNotebookLock(d.notebook, func() error {
+		notebookDirectory := path.Join(d.baseDirectory, d.notebook)
+		metaPath := path.Join(notebookDirectory, d.metaFilename)
+		err := writeJSONAtomic(metaPath, meta)
+		if err != nil {
+			return fmt.Errorf("write meta file: %v", err)
+		}
+		return nil
+	})
+}
+
+// ReserveNoteID increments the notebook's latest ID and saves its meta while
+// holding the notebook's lock, so that no other process can reserve the same
+// ID between reading and saving the meta
+func (d *local) ReserveNoteID() (*notes.Meta, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var meta *notes.Meta
+	err := d.withNotebookLock(d.notebook, func() error {
+		var err error
+		meta, err = d.readMeta(d.notebook)
+		if err != nil {
+			return err
+		}
+
+		index, err := d.refreshIndex(d.notebook)
+		if err != nil {
+			return fmt.Errorf("notebook %q index: %w", d.notebook, err)
+		}
+
+		id := meta.LatestID + 1
+		if _, exists := index[id]; exists {
+			return fmt.Errorf("note ID %d (%x) already exists", id, id)
+		}
+		meta.LatestID = id
+
+		return d.writeMeta(d.notebook, meta)
+	})
 	if err != nil {
-		return fmt.Errorf("backup old meta file: %v", err)
+		return nil, err
 	}
-	// TODO: remove meta backup
+	return meta, nil
+}
 
-	metaFile, err := os.Create(metaPath)
-	if err != nil {
-		err = os.Rename(metaPath+".bak", metaPath)
+// UpdateMeta applies update to the notebook's meta and saves it while holding
+// the notebook's lock, so that changes saved by other processes in the
+// meantime aren't overwritten
+func (d *local) UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var meta *notes.Meta
+	err := d.withNotebookLock(d.notebook, func() error {
+		var err error
+		meta, err = d.readMeta(d.notebook)
 		if err != nil {
-			return fmt.Errorf("restore meta backup: %v", err)
+			return err
 		}
-		return fmt.Errorf("create meta file: %v", err)
+
+		update(meta)
+		return d.writeMeta(d.notebook, meta)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return meta, nil
+}
 
-	err = json.NewEncoder(metaFile).Encode(meta)
+// writeMeta updates the meta's size and writes it to the notebook's meta file.
+// The caller must hold the notebook's lock
+func (d *local) writeMeta(notebook string, meta *notes.Meta) error {
+	err := setMetaSize(meta)
 	if err != nil {
-		metaFile.Close()
-		return fmt.Errorf("encode meta file: %v", err)
+		return err
 	}
 
-	err = metaFile.Close()
+	metaPath := path.Join(d.baseDirectory, notebook, d.metaFilename)
+	err = writeJSONAtomic(metaPath, meta)
 	if err != nil {
-		return fmt.Errorf("close meta file: %v", err)
+		return fmt.Errorf("write meta file: %v", err)
 	}
 	return nil
 }
 
 func (d *local) CreateNotebook(name string) error {
-	if name == "" {
-		return fmt.Errorf("notebook name cannot be blank string")
-	} else if name[0] == '.' {
-		return fmt.Errorf("notebook name cannot start with \".\"")
+	err := ValidateNotebookName(name)
+	if err != nil {
+		return err
 	}
 
 	d.Lock()
 	defer d.Unlock()
 
 	notebookPath := path.Join(d.baseDirectory, name)
-	err := createDirectory(notebookPath)
+	err = createDirectory(notebookPath)
 	if err != nil {
 		return fmt.Errorf("make notebook directory: %v", err)
 	}
 
-	err = buildMeta(d.baseDirectory, name, d.version)
-	if err != nil {
-		return fmt.Errorf("build meta: %v", err)
-	}
+	return d.withNotebookLock(name, func() error {
+		err := buildMeta(d.baseDirectory, name, d.version)
+		if err != nil {
+			return fmt.Errorf("build meta: %v", err)
+		}
 
-	index, err := buildIndex(d.baseDirectory, name)
-	if err != nil {
-		return fmt.Errorf("build index: %v", err)
-	}
-	d.indexes[name] = index
+		index, err := buildIndex(d.baseDirectory, name, d.logger)
+		if err != nil {
+			return fmt.Errorf("build index: %v", err)
+		}
+		d.indexes[name] = index
+		d.recordIndexInfo(name)
 
-	return nil
+		return nil
+	})
 }
 
 func (d *local) GetNotebook() string {
@@ -204,11 +442,17 @@ func (d *local) GetNotebook() string {
 	return d.notebook
 }
 
+// GetAllNotebooks lists the notebook directories, including those created by
+// other processes since this DAL was initialized
 func (d *local) GetAllNotebooks() []string {
 	d.Lock()
 	defer d.Unlock()
 
-	var notebooks []string
+	notebooks, err := listNotebooks(d.baseDirectory)
+	if err == nil {
+		return notebooks
+	}
+
 	for notebook := range d.indexes {
 		notebooks = append(notebooks, notebook)
 	}
@@ -239,9 +483,13 @@ func (d *local) SetNotebook(name string) error {
 }
 
 func (d *local) RenameNotebook(oldName, newName string) error {
-	if oldName == "" || newName == "" {
+	if oldName == "" {
 		return fmt.Errorf("notebook name cannot be blank string")
 	}
+	err := ValidateNotebookName(newName)
+	if err != nil {
+		return err
+	}
 
 	oldNotebookPath := path.Join(d.baseDirectory, oldName)
 	info, err := os.Stat(oldNotebookPath)
@@ -264,15 +512,20 @@ func (d *local) RenameNotebook(oldName, newName string) error {
 	d.Lock()
 	defer d.Unlock()
 
-	err = os.Rename(oldNotebookPath, newNotebookPath)
-	if err != nil {
-		return fmt.Errorf("rename notebook directory: %v", err)
-	}
+	return d.withNotebookLock(oldName, func() error {
+		err := os.Rename(oldNotebookPath, newNotebookPath)
+		if err != nil {
+			return fmt.Errorf("rename notebook directory: %v", err)
+		}
 
-	d.indexes[newName] = d.indexes[oldName]
-	delete(d.indexes, oldName)
+		d.indexes[newName] = d.indexes[oldName]
+		d.indexInfos[newName] = d.indexInfos[oldName]
+		delete(d.indexes, oldName)
+		delete(d.indexInfos, oldName)
+		delete(d.reconciled, oldName)
 
-	return nil
+		return nil
+	})
 }
 
 func (d *local) RemoveNotebook(name string, recursive bool) error {
@@ -290,21 +543,27 @@ func (d *local) RemoveNotebook(name string, recursive bool) error {
 		return fmt.Errorf("file %s exists, but is not a directory", notebookPath)
 	}
 
-	if recursive {
-		return os.RemoveAll(notebookPath)
-	}
+	d.Lock()
+	defer d.Unlock()
+
+	return d.withNotebookLock(name, func() error {
+		delete(d.indexes, name)
+		delete(d.indexInfos, name)
+		delete(d.reconciled, name)
 
-	// TODO: check for notebook contents, remove index, then os.Remove
-	return os.Remove(notebookPath)
+		if recursive {
+			return os.RemoveAll(notebookPath)
+		}
+
+		// TODO: check for notebook contents, remove index, then os.Remove
+		return os.Remove(notebookPath)
+	})
 }
 
 func (d *local) GetNoteMeta(id int) (*notes.NoteMeta, error) {
-	d.Lock()
-	defer d.Unlock()
-
-	index, ok := d.indexes[d.notebook]
-	if !ok {
-		return nil, fmt.Errorf("notebook %q index not found", d.notebook)
+	index, err := d.GetAllNoteMetas()
+	if err != nil {
+		return nil, err
 	}
 
 	noteMeta, ok := index[id]
@@ -318,9 +577,17 @@ func (d *local) GetAllNoteMetas() (map[int]notes.NoteMeta, error) {
 	d.Lock()
 	defer d.Unlock()
 
-	index, ok := d.indexes[d.notebook]
-	if !ok {
-		return nil, fmt.Errorf("notebook %q index not found", d.notebook)
+	var index map[int]notes.NoteMeta
+	err := d.withNotebookLock(d.notebook, func() error {
+		var err error
+		index, err = d.refreshIndex(d.notebook)
+		if err != nil {
+			return fmt.Errorf("notebook %q index: %w", d.notebook, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return index, nil
@@ -341,21 +608,27 @@ func (d *local) GetNote(id int) (*notes.Note, error) {
 		return nil, fmt.Errorf("get note path: %v", err)
 	}
 
-	noteFile, err := os.Open(notePath)
-	if err != nil {
-		return nil, fmt.Errorf("open note file: %v", err)
-	}
-
 	var n notes.Note
-	err = json.NewDecoder(noteFile).Decode(&n)
-	if err != nil {
-		noteFile.Close()
-		return nil, fmt.Errorf("decode note file: %v", err)
-	}
+	err = d.withNotebookLock(d.notebook, func() error {
+		noteFile, err := os.Open(notePath)
+		if err != nil {
+			return fmt.Errorf("open note file: %v", err)
+		}
+
+		err = json.NewDecoder(noteFile).Decode(&n)
+		if err != nil {
+			noteFile.Close()
+			return fmt.Errorf("decode note file: %v", err)
+		}
 
-	err = noteFile.Close()
+		err = noteFile.Close()
+		if err != nil {
+			return fmt.Errorf("close note file: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return &n, fmt.Errorf("close note file: %v", err)
+		return nil, err
 	}
 	return &n, nil
 }
@@ -370,35 +643,28 @@ func (d *local) SaveNote(note *notes.Note) error {
 		return fmt.Errorf("get note path: %v", err)
 	}
 
-	noteFile, err := os.Create(notePath)
-	if err != nil {
-		return fmt.Errorf("create note file: %v", err)
-	}
-
-	err = json.NewEncoder(noteFile).Encode(note)
-	if err != nil {
-		noteFile.Close()
-		return fmt.Errorf("encode note file: %v", err)
-	}
+	return d.withNotebookLock(d.notebook, func() error {
+		index, err := d.refreshIndex(d.notebook)
+		if err != nil {
+			return fmt.Errorf("notebook %q index: %w", d.notebook, err)
+		}
 
-	err = noteFile.Close()
-	if err != nil {
-		return fmt.Errorf("close note file: %v", err)
-	}
+		err = writeJSONAtomic(notePath, note)
+		if err != nil {
+			return fmt.Errorf("write note file: %v", err)
+		}
 
-	index, ok := d.indexes[d.notebook]
-	if !ok {
-		return fmt.Errorf("notebook %q index not found", d.notebook)
-	}
-	index[note.Meta.ID] = note.Meta
+		index[note.Meta.ID] = note.Meta
 
-	indexPath := path.Join(d.baseDirectory, d.notebook, defaultIndexFilename)
-	err = saveIndex(indexPath, index)
-	if err != nil {
-		return fmt.Errorf("save index: %v", err)
-	}
+		indexPath := path.Join(d.baseDirectory, d.notebook, defaultIndexFilename)
+		err = saveIndex(indexPath, index)
+		if err != nil {
+			return fmt.Errorf("save index: %v", err)
+		}
+		d.recordIndexInfo(d.notebook)
 
-	return nil
+		return nil
+	})
 }
 
 // RemoveNote deletes the note file
@@ -411,24 +677,66 @@ func (d *local) RemoveNote(id int) error {
 		return fmt.Errorf("get note path: %v", err)
 	}
 
-	err = os.Remove(notePath)
+	return d.withNotebookLock(d.notebook, func() error {
+		index, err := d.refreshIndex(d.notebook)
+		if err != nil {
+			return fmt.Errorf("notebook %q index: %w", d.notebook, err)
+		}
+
+		err = os.Remove(notePath)
+		if err != nil {
+			return fmt.Errorf("remove note file: %v", err)
+		}
+		delete(index, id)
+
+		indexPath := path.Join(d.baseDirectory, d.notebook, defaultIndexFilename)
+		err = saveIndex(indexPath, index)
+		if err != nil {
+			return fmt.Errorf("save index: %v", err)
+		}
+		d.recordIndexInfo(d.notebook)
+
+		return nil
+	})
+}
+
+// listNotebooks gets the names of the notebook directories in the base
+// directory
+func listNotebooks(baseDirectory string) ([]string, error) {
+	entries, err := os.ReadDir(baseDirectory)
 	if err != nil {
-		return fmt.Errorf("remove note file: %v", err)
+		return nil, fmt.Errorf("read base directory contents: %v", err)
 	}
 
-	index, ok := d.indexes[d.notebook]
-	if !ok {
-		return fmt.Errorf("notebook %q index not found", d.notebook)
+	var notebooks []string
+	for _, entry := range entries {
+		if !entry.IsDir() || IsHidden(entry.Name()) {
+			continue
+		}
+		notebooks = append(notebooks, entry.Name())
 	}
-	delete(index, id)
 
-	indexPath := path.Join(d.baseDirectory, d.notebook, defaultIndexFilename)
-	err = saveIndex(indexPath, index)
+	return notebooks, nil
+}
+
+// resolveDirectory makes a directory relative to the user's home directory
+// absolute, leaving absolute directories as they are
+func resolveDirectory(dirName string) (string, error) {
+	if filepath.IsAbs(dirName) {
+		return filepath.Clean(dirName), nil
+	}
+
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("save index: %v", err)
+		return "", fmt.Errorf("get home directory: %v", err)
 	}
+	return path.Join(home, dirName), nil
+}
 
-	return nil
+// LocalNotePath gets the path of the file in which the local DAL stores a
+// note, relative to its base directory
+func LocalNotePath(notebook string, id int) string {
+	return path.Join(notebook, fmt.Sprintf(defaultNoteFilenameFormat, id))
 }
 
 func createDirectory(dirname string) error {
@@ -449,29 +757,21 @@ func createDirectory(dirname string) error {
 func buildMeta(baseDirectory, notebook, version string) error {
 	notebookPath := path.Join(baseDirectory, notebook)
 	metaPath := path.Join(notebookPath, defaultMetaFilename)
-	metaFile, err := os.Create(metaPath)
-	if err != nil {
-		return fmt.Errorf("create meta file: %v", err)
-	}
 
 	m := &notes.Meta{
 		Version: version,
 	}
 
-	err = json.NewEncoder(metaFile).Encode(m)
+	err := writeJSONAtomic(metaPath, m)
 	if err != nil {
-		metaFile.Close()
-		return fmt.Errorf("encode meta file: %v", err)
-	}
-
-	err = metaFile.Close()
-	if err != nil {
-		return fmt.Errorf("close meta file: %v", err)
+		return fmt.Errorf("write meta file: %v", err)
 	}
 	return nil
 }
 
-func buildIndex(baseDirectory, notebook string) (map[int]notes.NoteMeta, error) {
+// buildIndex creates a notebook's index from its note files. Note files that
+// can't be read are logged and left out of the index
+func buildIndex(baseDirectory, notebook string, logger *zap.Logger) (map[int]notes.NoteMeta, error) {
 	notebookPath := path.Join(baseDirectory, notebook)
 	infos, err := ioutil.ReadDir(notebookPath)
 	if err != nil {
@@ -479,23 +779,22 @@ func buildIndex(baseDirectory, notebook string) (map[int]notes.NoteMeta, error)
 	}
 
 	index := make(map[int]notes.NoteMeta, defaultIndexCapacity)
-	nameRegex := regexp.MustCompile(noteFilenameRegex)
 	for _, info := range infos {
-		if info.IsDir() || !nameRegex.MatchString(info.Name()) {
+		if info.IsDir() || !noteFilenamePattern.MatchString(info.Name()) {
 			continue
 		}
 
 		noteFilename := path.Join(notebookPath, info.Name())
 		notefile, err := os.Open(noteFilename)
 		if err != nil {
-			// TODO: log this
+			logger.Warn("open note file", zap.Error(err), zap.String("notebook", notebook), zap.String("filename", info.Name()))
 			continue
 		}
 
 		var note notes.Note
 		err = json.NewDecoder(notefile).Decode(&note)
 		if err != nil {
-			// TODO: log this
+			logger.Warn("decode note file", zap.Error(err), zap.String("notebook", notebook), zap.String("filename", info.Name()))
 			notefile.Close()
 			continue
 		}
@@ -505,20 +804,9 @@ func buildIndex(baseDirectory, notebook string) (map[int]notes.NoteMeta, error)
 	}
 
 	indexPath := path.Join(notebookPath, defaultIndexFilename)
-	indexFile, err := os.Create(indexPath)
-	if err != nil {
-		return nil, fmt.Errorf("create index file: %w", err)
-	}
-
-	err = json.NewEncoder(indexFile).Encode(index)
+	err = writeJSONAtomic(indexPath, index)
 	if err != nil {
-		indexFile.Close()
-		return nil, fmt.Errorf("encode index file: %w", err)
-	}
-
-	err = indexFile.Close()
-	if err != nil {
-		return nil, fmt.Errorf("close index file: %w", err)
+		return nil, fmt.Errorf("write index file: %w", err)
 	}
 	return index, nil
 }
@@ -546,29 +834,9 @@ func loadIndex(indexPath string) (map[int]notes.NoteMeta, error) {
 // FIXME: saveIndex is called with default index path in SaveNote and
 // RemoveNote, which will lead to clobbering when changing notebooks
 func saveIndex(indexPath string, index map[int]notes.NoteMeta) error {
-	err := os.Rename(indexPath, indexPath+".bak")
-	if err != nil {
-		return fmt.Errorf("backup index file: %w", err)
-	}
-	// TODO: remove index backup
-
-	indexFile, err := os.Create(indexPath)
-	if err != nil {
-		err = os.Rename(indexPath+".bak", indexPath)
-		if err != nil {
-			return fmt.Errorf("restore index backup: %w", err)
-		}
-	}
-
-	err = json.NewEncoder(indexFile).Encode(index)
-	if err != nil {
-		indexFile.Close()
-		return fmt.Errorf("encode index file: %w", err)
-	}
-
-	err = indexFile.Close()
+	err := writeJSONAtomic(indexPath, index)
 	if err != nil {
-		return fmt.Errorf("close index file: %w", err)
+		return fmt.Errorf("write index file: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,239 @@
+package dal
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+func newTestLocal(t *testing.T) *local {
+	t.Helper()
+
+	d, err := NewLocal(t.TempDir(), "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+	return d.(*local)
+}
+
+func TestLocalReloadsChangedIndex(t *testing.T) {
+	first := newTestLocal(t)
+
+	// a second DAL over the same directory stands in for another process
+	second, err := NewLocal(first.baseDirectory, "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+
+	for i, d := range []DAL{first, second} {
+		note := &notes.Note{
+			Meta: notes.NoteMeta{
+				ID:      i + 1,
+				Title:   "note",
+				Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+			},
+		}
+
+		err = d.SaveNote(note)
+		if err != nil {
+			t.Fatalf("save note %d: %s", note.Meta.ID, err)
+		}
+	}
+
+	for _, d := range []DAL{first, second} {
+		index, err := d.GetAllNoteMetas()
+		if err != nil {
+			t.Fatalf("get all note metas: %s", err)
+		}
+		if len(index) != 2 {
+			t.Errorf("index contains %d notes, expected 2", len(index))
+		}
+	}
+}
+
+func TestLocalNotebookLocked(t *testing.T) {
+	d := newTestLocal(t)
+	d.lockTimeout = 2 * lockRetryInterval
+
+	file, err := os.OpenFile(path.Join(d.baseDirectory, defaultNotebook, defaultLockFilename), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatalf("open lock file: %s", err)
+	}
+	defer file.Close()
+
+	err = lockFile(file)
+	if err != nil {
+		t.Fatalf("lock file: %s", err)
+	}
+
+	err = d.SaveMeta(&notes.Meta{Version: "test"})
+	if !errors.Is(err, ErrNotebookLocked) {
+		t.Errorf("save meta with held lock returned %v, expected %v", err, ErrNotebookLocked)
+	}
+
+	err = unlockFile(file)
+	if err != nil {
+		t.Fatalf("unlock file: %s", err)
+	}
+
+	err = d.SaveMeta(&notes.Meta{Version: "test"})
+	if err != nil {
+		t.Errorf("save meta after unlocking: %s", err)
+	}
+}
+
+func TestLocalReserveNoteIDConcurrently(t *testing.T) {
+	first := newTestLocal(t)
+	first.lockTimeout = time.Minute
+
+	// separate DALs over the same directory only share the notebook lock,
+	// as separate processes would
+	dals := []*local{first}
+	for i := 0; i < 3; i++ {
+		d, err := NewLocal(first.baseDirectory, "test", nil)
+		if err != nil {
+			t.Fatalf("new local: %s", err)
+		}
+		d.(*local).lockTimeout = time.Minute
+		dals = append(dals, d.(*local))
+	}
+
+	const reservations = 20
+	ids := make(chan int, len(dals)*reservations)
+	errs := make(chan error, len(dals)*reservations)
+	var wg sync.WaitGroup
+	for _, d := range dals {
+		wg.Add(1)
+		go func(d *local) {
+			defer wg.Done()
+			for i := 0; i < reservations; i++ {
+				meta, err := d.ReserveNoteID()
+				if err != nil {
+					errs <- err
+					return
+				}
+				ids <- meta.LatestID
+			}
+		}(d)
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("reserve note ID: %s", err)
+	}
+
+	reserved := make(map[int]bool)
+	for id := range ids {
+		if reserved[id] {
+			t.Errorf("note ID %d reserved more than once", id)
+		}
+		reserved[id] = true
+	}
+
+	meta, err := first.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	if meta.LatestID != len(dals)*reservations {
+		t.Errorf("latest ID is %d, expected %d", meta.LatestID, len(dals)*reservations)
+	}
+}
+
+func TestLocalLoadsIndexesLazily(t *testing.T) {
+	first := newTestLocal(t)
+	for _, notebook := range []string{"first", "second"} {
+		err := first.CreateNotebook(notebook)
+		if err != nil {
+			t.Fatalf("create notebook: %s", err)
+		}
+	}
+
+	d, err := NewLocal(first.baseDirectory, "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+	l := d.(*local)
+	if len(l.indexes) != 0 {
+		t.Errorf("%d indexes loaded on initialization, expected none", len(l.indexes))
+	}
+
+	err = d.SetNotebook("second")
+	if err != nil {
+		t.Fatalf("set notebook: %s", err)
+	}
+	_, err = d.GetAllNoteMetas()
+	if err != nil {
+		t.Fatalf("get all note metas: %s", err)
+	}
+	if _, ok := l.indexes["second"]; !ok || len(l.indexes) != 1 {
+		t.Errorf("loaded indexes %v, expected only the second notebook's", l.indexes)
+	}
+}
+
+func TestLocalIndexSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	newSnapshotting := func() *local {
+		t.Helper()
+		d, err := NewLocalWithOptions(dir, "test", nil, LocalOptions{IndexSnapshots: true})
+		if err != nil {
+			t.Fatalf("new local: %s", err)
+		}
+		return d.(*local)
+	}
+
+	d := newSnapshotting()
+	err := d.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 1, Title: "first"}})
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+
+	snapshotPath := path.Join(dir, defaultNotebook, defaultSnapshotFilename)
+	_, err = os.Stat(snapshotPath)
+	if err != nil {
+		t.Fatalf("stat snapshot: %s", err)
+	}
+
+	// a DAL without snapshots leaves the snapshot stale, so the index is
+	// rebuilt from the note files
+	other, err := NewLocal(dir, "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+	err = other.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 2, Title: "second"}})
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+
+	// the JSON index is emptied, so that rebuilding the index can be told
+	// apart from loading it
+	indexPath := path.Join(dir, defaultNotebook, defaultIndexFilename)
+	err = saveIndex(indexPath, map[int]notes.NoteMeta{})
+	if err != nil {
+		t.Fatalf("save index: %s", err)
+	}
+
+	index, err := newSnapshotting().GetAllNoteMetas()
+	if err != nil {
+		t.Fatalf("get all note metas: %s", err)
+	}
+	if len(index) != 2 {
+		t.Errorf("index contains %d notes, expected 2 from rebuilding", len(index))
+	}
+
+	// rebuilding the index wrote a current snapshot
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatalf("stat index: %s", err)
+	}
+	_, err = loadSnapshot(snapshotPath, info)
+	if err != nil {
+		t.Errorf("load snapshot after rebuild: %s", err)
+	}
+}
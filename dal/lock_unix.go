@@ -0,0 +1,24 @@
+//go:build !windows
+
+package dal
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile places a non-blocking exclusive advisory lock on the file. If
+// another process holds the lock, errLockHeld is returned
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLockHeld
+	}
+	return err
+}
+
+// unlockFile releases a lock placed by lockFile
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,325 @@
+// Package remote implements a DAL backed by a notes server over HTTP
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+	"github.com/subtlepseudonym/notes/server"
+)
+
+const (
+	defaultNotebook   = "default"
+	defaultTimeout    = 10 * time.Second
+	defaultRetryDelay = 250 * time.Millisecond
+)
+
+var (
+	// ErrUnreachable indicates that the server couldn't be contacted, such
+	// as when working offline
+	ErrUnreachable = errors.New("notes server unreachable")
+
+	// ErrNotFound indicates that the requested notebook or note doesn't exist
+	ErrNotFound = errors.New("not found")
+)
+
+// Options configures the client used to reach the server
+type Options struct {
+	Timeout    time.Duration // per attempt at a request
+	Retries    int           // retries of idempotent requests after network errors or unavailability
+	RetryDelay time.Duration // doubled after each retry
+}
+
+type remote struct {
+	sync.Mutex
+	client   *http.Client
+	baseURL  *url.URL
+	token    string
+	notebook string
+	options  Options
+}
+
+// New creates a DAL which forwards each call to the server at baseURL,
+// authenticating with token. The server is contacted immediately so that an
+// unreachable server is reported before any other calls are made
+func New(baseURL, token string, options Options) (dal.DAL, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse server URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("server URL %q must use http or https", baseURL)
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = defaultRetryDelay
+	}
+
+	d := &remote{
+		client:   &http.Client{Timeout: options.Timeout},
+		baseURL:  u,
+		token:    token,
+		notebook: defaultNotebook,
+		options:  options,
+	}
+
+	var version server.VersionResponse
+	err = d.do(http.MethodGet, "/version", nil, nil, &version)
+	if err != nil {
+		return nil, err
+	}
+	if version.API != server.APIVersion {
+		return nil, fmt.Errorf("server API version %q is not supported, expected %q", version.API, server.APIVersion)
+	}
+
+	return d, nil
+}
+
+// do sends a request to the API path, encoding body and decoding the response
+// into out, if they aren't nil. Idempotent requests are retried when the
+// server can't be reached or is temporarily unavailable
+func (d *remote) do(method, path string, query url.Values, body, out interface{}) error {
+	var encoded []byte
+	if body != nil {
+		var err error
+		encoded, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	u := d.baseURL.JoinPath(server.APIVersion, path)
+	u.RawQuery = query.Encode()
+
+	attempts := 1
+	if method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete {
+		attempts += d.options.Retries
+	}
+
+	delay := d.options.RetryDelay
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var retry bool
+		retry, err = d.send(method, u, encoded, out)
+		if !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// send makes a single request, reporting whether a failure may succeed if
+// the request is retried
+func (d *remote) send(method string, u *url.URL, body []byte, out interface{}) (bool, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("%w at %s, check your connection or the remote URL: %v", ErrUnreachable, d.baseURL.Host, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if out == nil || res.StatusCode == http.StatusNoContent {
+			return false, nil
+		}
+
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			return false, fmt.Errorf("decode response: %w", err)
+		}
+		return false, nil
+	}
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if json.Unmarshal(b, &apiErr) != nil || apiErr.Error == "" {
+		apiErr.Error = strings.TrimSpace(string(b))
+	}
+
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return false, fmt.Errorf("%w: %s", ErrNotFound, apiErr.Error)
+	case http.StatusUnauthorized:
+		return false, fmt.Errorf("server rejected token: %s", apiErr.Error)
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, fmt.Errorf("%w at %s: %s", ErrUnreachable, d.baseURL.Host, res.Status)
+	}
+
+	return false, fmt.Errorf("server returned %s: %s", res.Status, apiErr.Error)
+}
+
+func notebookPath(notebook string) string {
+	return "notebooks/" + url.PathEscape(notebook)
+}
+
+func notePath(notebook string, id int) string {
+	return fmt.Sprintf("%s/notes/%x", notebookPath(notebook), id)
+}
+
+func (d *remote) GetMeta() (*notes.Meta, error) {
+	var meta notes.Meta
+	err := d.do(http.MethodGet, notebookPath(d.GetNotebook())+"/meta", nil, nil, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("get meta: %w", err)
+	}
+	return &meta, nil
+}
+
+func (d *remote) SaveMeta(meta *notes.Meta) error {
+	err := d.do(http.MethodPut, notebookPath(d.GetNotebook())+"/meta", nil, meta, nil)
+	if err != nil {
+		return fmt.Errorf("save meta: %w", err)
+	}
+	return nil
+}
+
+func (d *remote) CreateNotebook(name string) error {
+	err := d.do(http.MethodPost, "notebooks", nil, server.NotebookRequest{Name: name}, nil)
+	if err != nil {
+		return fmt.Errorf("create notebook: %w", err)
+	}
+	return nil
+}
+
+func (d *remote) GetNotebook() string {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.notebook
+}
+
+// GetAllNotebooks lists the server's notebooks. The DAL interface doesn't
+// allow for errors here, so none are listed if the request fails
+func (d *remote) GetAllNotebooks() []string {
+	var notebooks []string
+	err := d.do(http.MethodGet, "notebooks", nil, nil, &notebooks)
+	if err != nil {
+		return nil
+	}
+	return notebooks
+}
+
+// SetNotebook changes the notebook used by subsequent calls after checking
+// that it exists on the server
+func (d *remote) SetNotebook(name string) error {
+	if name == "" {
+		return fmt.Errorf("notebook name cannot be blank string")
+	}
+
+	err := d.do(http.MethodGet, notebookPath(name)+"/meta", nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("set notebook: %w", err)
+	}
+
+	d.Lock()
+	d.notebook = name
+	d.Unlock()
+
+	return nil
+}
+
+func (d *remote) RenameNotebook(oldName, newName string) error {
+	err := d.do(http.MethodPatch, notebookPath(oldName), nil, server.NotebookRequest{Name: newName}, nil)
+	if err != nil {
+		return fmt.Errorf("rename notebook: %w", err)
+	}
+
+	d.Lock()
+	if d.notebook == oldName {
+		d.notebook = newName
+	}
+	d.Unlock()
+
+	return nil
+}
+
+func (d *remote) RemoveNotebook(name string, recursive bool) error {
+	query := url.Values{"recursive": {strconv.FormatBool(recursive)}}
+	err := d.do(http.MethodDelete, notebookPath(name), query, nil, nil)
+	if err != nil {
+		return fmt.Errorf("remove notebook: %w", err)
+	}
+	return nil
+}
+
+func (d *remote) GetNoteMeta(id int) (*notes.NoteMeta, error) {
+	var meta notes.NoteMeta
+	err := d.do(http.MethodGet, notePath(d.GetNotebook(), id)+"/meta", nil, nil, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("get note meta: %w", err)
+	}
+	return &meta, nil
+}
+
+func (d *remote) GetAllNoteMetas() (map[int]notes.NoteMeta, error) {
+	var metas []notes.NoteMeta
+	query := url.Values{"deleted": {"true"}}
+	err := d.do(http.MethodGet, notebookPath(d.GetNotebook())+"/notes", query, nil, &metas)
+	if err != nil {
+		return nil, fmt.Errorf("get note metas: %w", err)
+	}
+
+	index := make(map[int]notes.NoteMeta, len(metas))
+	for _, meta := range metas {
+		index[meta.ID] = meta
+	}
+	return index, nil
+}
+
+func (d *remote) GetNote(id int) (*notes.Note, error) {
+	var note notes.Note
+	err := d.do(http.MethodGet, notePath(d.GetNotebook(), id), nil, nil, &note)
+	if err != nil {
+		return nil, fmt.Errorf("get note: %w", err)
+	}
+	return &note, nil
+}
+
+func (d *remote) SaveNote(note *notes.Note) error {
+	err := d.do(http.MethodPut, notePath(d.GetNotebook(), note.Meta.ID), nil, note, nil)
+	if err != nil {
+		return fmt.Errorf("save note: %w", err)
+	}
+	return nil
+}
+
+// RemoveNote permanently deletes the note from the server
+func (d *remote) RemoveNote(id int) error {
+	query := url.Values{"hard": {"true"}}
+	err := d.do(http.MethodDelete, notePath(d.GetNotebook(), id), query, nil, nil)
+	if err != nil {
+		return fmt.Errorf("remove note: %w", err)
+	}
+	return nil
+}
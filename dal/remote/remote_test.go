@@ -0,0 +1,158 @@
+package remote
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+	"github.com/subtlepseudonym/notes/server"
+
+	"go.uber.org/zap"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	data, err := dal.NewLocal("notes", "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+
+	return server.New(data, []string{testToken}, "test", zap.NewNop())
+}
+
+func TestRemote(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t))
+	defer ts.Close()
+
+	d, err := New(ts.URL, testToken, Options{})
+	if err != nil {
+		t.Fatalf("new remote: %s", err)
+	}
+
+	err = d.CreateNotebook("work")
+	if err != nil {
+		t.Fatalf("create notebook: %s", err)
+	}
+
+	err = d.SetNotebook("work")
+	if err != nil {
+		t.Fatalf("set notebook: %s", err)
+	}
+
+	note := &notes.Note{
+		Meta: notes.NoteMeta{
+			ID:      1,
+			Title:   "remote",
+			Created: notes.JSONTime{Time: time.Unix(1700000000, 0)},
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+		},
+		Body: "body\n",
+	}
+
+	err = d.SaveNote(note)
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+
+	err = d.SaveMeta(&notes.Meta{Version: "test", LatestID: 1})
+	if err != nil {
+		t.Fatalf("save meta: %s", err)
+	}
+
+	meta, err := d.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	if meta.LatestID != 1 {
+		t.Errorf("latest ID = %d, expected 1", meta.LatestID)
+	}
+
+	got, err := d.GetNote(1)
+	if err != nil {
+		t.Fatalf("get note: %s", err)
+	}
+	if got.Body != note.Body || got.Meta.Title != note.Meta.Title {
+		t.Errorf("got note with title %q and body %q", got.Meta.Title, got.Body)
+	}
+
+	index, err := d.GetAllNoteMetas()
+	if err != nil {
+		t.Fatalf("get all note metas: %s", err)
+	}
+	if _, ok := index[1]; !ok || len(index) != 1 {
+		t.Errorf("index = %v, expected only note 1", index)
+	}
+
+	err = d.RemoveNote(1)
+	if err != nil {
+		t.Fatalf("remove note: %s", err)
+	}
+
+	_, err = d.GetNoteMeta(1)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("get removed note meta returned %v, expected %v", err, ErrNotFound)
+	}
+
+	err = d.SetNotebook("missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("set missing notebook returned %v, expected %v", err, ErrNotFound)
+	}
+	if d.GetNotebook() != "work" {
+		t.Errorf("notebook = %q after failed switch, expected work", d.GetNotebook())
+	}
+}
+
+func TestRemoteRetries(t *testing.T) {
+	handler := newTestServer(t)
+
+	var requests, failures int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail every other request
+		if atomic.AddInt32(&requests, 1)%2 == 1 {
+			atomic.AddInt32(&failures, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	d, err := New(ts.URL, testToken, Options{Retries: 1, RetryDelay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("new remote: %s", err)
+	}
+
+	_, err = d.GetMeta()
+	if err != nil {
+		t.Errorf("get meta: %s", err)
+	}
+	if failures != 2 {
+		t.Errorf("%d requests failed, expected 2", failures)
+	}
+
+	// creating a notebook isn't idempotent, so it isn't retried
+	err = d.CreateNotebook("work")
+	if !errors.Is(err, ErrUnreachable) {
+		t.Errorf("create notebook returned %v, expected %v", err, ErrUnreachable)
+	}
+}
+
+func TestRemoteUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+
+	_, err := New(url, testToken, Options{Timeout: time.Second, RetryDelay: time.Millisecond})
+	if !errors.Is(err, ErrUnreachable) {
+		t.Errorf("new remote returned %v, expected %v", err, ErrUnreachable)
+	}
+}
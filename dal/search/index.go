@@ -0,0 +1,258 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+const (
+	indexVersion = 1
+
+	// BM25 tuning parameters
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// index is an inverted index of the notes in a single notebook
+type index struct {
+	Version     int                    `json:"version"`
+	Documents   map[int]document       `json:"documents"` // map noteID to document info
+	Postings    map[string]map[int]int `json:"postings"`  // map term to map of noteID to term frequency
+	TotalLength int                    `json:"totalLength"`
+}
+
+// document holds the information needed to rank and remove an indexed note
+type document struct {
+	Title   string   `json:"title"`
+	Deleted bool     `json:"deleted"`
+	Length  int      `json:"length"` // number of terms
+	Terms   []string `json:"terms"`  // unique terms, used for removal
+}
+
+// token is a single term and its byte offsets within the text it was
+// parsed from
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+func newIndex() *index {
+	return &index{
+		Version:   indexVersion,
+		Documents: make(map[int]document),
+		Postings:  make(map[string]map[int]int),
+	}
+}
+
+// add indexes the provided note, replacing any existing entry for its ID
+func (idx *index) add(note *notes.Note) {
+	idx.remove(note.Meta.ID)
+
+	tokens := tokenize(note.Meta.Title + "\n" + note.Body)
+	frequencies := make(map[string]int, len(tokens))
+	for _, t := range tokens {
+		frequencies[t.term]++
+	}
+
+	terms := make([]string, 0, len(frequencies))
+	for term, freq := range frequencies {
+		postings, ok := idx.Postings[term]
+		if !ok {
+			postings = make(map[int]int)
+			idx.Postings[term] = postings
+		}
+		postings[note.Meta.ID] = freq
+		terms = append(terms, term)
+	}
+
+	idx.Documents[note.Meta.ID] = document{
+		Title:   note.Meta.Title,
+		Deleted: note.Meta.IsDeleted(),
+		Length:  len(tokens),
+		Terms:   terms,
+	}
+	idx.TotalLength += len(tokens)
+}
+
+// remove deletes the note with the provided ID from the index
+func (idx *index) remove(id int) {
+	doc, ok := idx.Documents[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.Terms {
+		postings := idx.Postings[term]
+		delete(postings, id)
+		if len(postings) == 0 {
+			delete(idx.Postings, term)
+		}
+	}
+
+	idx.TotalLength -= doc.Length
+	delete(idx.Documents, id)
+}
+
+// score ranks every document containing at least one of the provided terms
+// using BM25
+func (idx *index) score(terms []string) map[int]float64 {
+	scores := make(map[int]float64)
+	if len(idx.Documents) == 0 {
+		return scores
+	}
+
+	numDocs := float64(len(idx.Documents))
+	avgLength := float64(idx.TotalLength) / numDocs
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	for _, term := range terms {
+		postings, ok := idx.Postings[term]
+		if !ok {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log((numDocs-df+0.5)/(df+0.5) + 1)
+		for id, freq := range postings {
+			tf := float64(freq)
+			length := float64(idx.Documents[id].Length)
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/avgLength))
+		}
+	}
+
+	return scores
+}
+
+func loadIndex(indexPath string) (*index, error) {
+	indexFile, err := os.Open(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("open search index file: %w", err)
+	}
+
+	var idx index
+	err = json.NewDecoder(indexFile).Decode(&idx)
+	if err != nil {
+		indexFile.Close()
+		return nil, fmt.Errorf("decode search index file: %w", err)
+	}
+
+	err = indexFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("close search index file: %w", err)
+	}
+
+	if idx.Version != indexVersion {
+		return nil, fmt.Errorf("search index version %d: %w", idx.Version, errStaleIndex)
+	}
+	return &idx, nil
+}
+
+func saveIndex(indexPath string, idx *index) error {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(idx)
+	if err != nil {
+		return fmt.Errorf("encode search index file: %w", err)
+	}
+
+	err = dal.WriteFileAtomic(indexPath, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("write search index file: %w", err)
+	}
+	return nil
+}
+
+// tokenize splits text into lowercase terms made up of letters and digits
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+
+	return tokens
+}
+
+// snippet extracts roughly length runes of text surrounding the first
+// occurrence of any of the provided terms
+func snippet(text string, terms []string, length int) string {
+	if length <= 0 {
+		return ""
+	}
+
+	match := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		match[term] = true
+	}
+
+	matchStart, matchEnd := 0, 0
+	for _, t := range tokenize(text) {
+		if match[t.term] {
+			matchStart, matchEnd = t.start, t.end
+			break
+		}
+	}
+
+	// widen the window around the match one rune at a time
+	start, end := matchStart, matchEnd
+	for utf8.RuneCountInString(text[start:end]) < length && (start > 0 || end < len(text)) {
+		if start > 0 {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+		if end < len(text) {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+	}
+
+	// avoid cutting words in half
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsSpace(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		end += size
+	}
+
+	s := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		s = "..." + s
+	}
+	if end < len(text) {
+		s = s + "..."
+	}
+	return s
+}
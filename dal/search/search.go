@@ -0,0 +1,477 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+const (
+	defaultSnippetLength = 80
+	defaultLockTimeout   = 5 * time.Second
+)
+
+var (
+	errStaleIndex = errors.New("search index is stale")
+
+	// errEncryptedNotebook is returned by getIndex for notebooks which are
+	// encrypted, which aren't indexed so that their contents aren't written
+	// to disk in the clear
+	errEncryptedNotebook = errors.New("notebook is encrypted")
+)
+
+// Searcher wraps a DAL, maintaining a full-text index of note titles and
+// bodies as notes are saved and removed
+type Searcher interface {
+	dal.DAL
+	Search(query string, options Options) ([]Result, error)
+	Rebuild(notebook string) error
+}
+
+// Options alters the behavior of Searcher.Search
+type Options struct {
+	Notebooks      []string `json:"notebooks"` // defaults to the current notebook
+	Limit          int      `json:"limit"`     // zero returns all results
+	IncludeDeleted bool     `json:"includeDeleted"`
+	SnippetLength  int      `json:"snippetLength"`
+}
+
+// Result is a single note matching a search query
+type Result struct {
+	Notebook string  `json:"notebook"`
+	ID       int     `json:"id"`
+	Title    string  `json:"title"`
+	Deleted  bool    `json:"deleted"`
+	Score    float64 `json:"score"`
+	Snippet  string  `json:"snippet"`
+}
+
+// searcher keeps one inverted index file per notebook in its directory.
+// Indexes are loaded, or built if missing, on first access and reloaded
+// whenever their file has been replaced by another process. Each notebook's
+// index is read, modified and written while holding a lock file so that
+// concurrent processes don't lose each other's updates
+type searcher struct {
+	dal.DAL
+	mu          sync.Mutex
+	directory   string
+	lockTimeout time.Duration
+	indexes     map[string]*index      // map notebook name to index
+	infos       map[string]os.FileInfo // map notebook name to index file info when loaded
+}
+
+// NewSearcher returns a Searcher that stores its indexes in the provided
+// directory
+func NewSearcher(d dal.DAL, directory string) Searcher {
+	return &searcher{
+		DAL:         d,
+		directory:   directory,
+		lockTimeout: defaultLockTimeout,
+		indexes:     make(map[string]*index),
+		infos:       make(map[string]os.FileInfo),
+	}
+}
+
+func (s *searcher) indexPath(notebook string) string {
+	return path.Join(s.directory, notebook)
+}
+
+// lockPath is hidden so that it can't collide with a notebook's index file
+func (s *searcher) lockPath(notebook string) string {
+	return path.Join(s.directory, "."+notebook+".lock")
+}
+
+// withLock calls fn while holding the notebook's index lock
+// It must be called with s.mu held
+func (s *searcher) withLock(notebook string, fn func() error) error {
+	err := s.ensureDirectory()
+	if err != nil {
+		return err
+	}
+
+	err = dal.WithFileLock(s.lockPath(notebook), s.lockTimeout, fn)
+	if errors.Is(err, dal.ErrLocked) {
+		return fmt.Errorf("lock search index for %q: %w", notebook, err)
+	}
+	return err
+}
+
+// ensureDirectory creates the index directory if it doesn't exist
+func (s *searcher) ensureDirectory() error {
+	info, err := os.Stat(s.directory)
+	if os.IsNotExist(err) {
+		err = os.MkdirAll(s.directory, os.ModeDir|os.FileMode(0700))
+		if err != nil {
+			return fmt.Errorf("create search index directory: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("stat search index directory: %w", err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("file %q exists, but is not a directory", s.directory)
+	}
+	return nil
+}
+
+// isCurrent reports whether the notebook's cached index matches its file,
+// which changes when another process saves or removes it
+// It must be called with s.mu held
+func (s *searcher) isCurrent(notebook string) bool {
+	loaded, ok := s.infos[notebook]
+	if !ok {
+		return false
+	}
+
+	info, err := os.Stat(s.indexPath(notebook))
+	if err != nil {
+		return false
+	}
+	return os.SameFile(info, loaded) &&
+		info.ModTime().Equal(loaded.ModTime()) &&
+		info.Size() == loaded.Size()
+}
+
+// recordInfo records the state of the notebook's index file so that later
+// changes to it can be detected
+// It must be called with s.mu held
+func (s *searcher) recordInfo(notebook string) error {
+	info, err := os.Stat(s.indexPath(notebook))
+	if err != nil {
+		return fmt.Errorf("stat search index file: %w", err)
+	}
+	s.infos[notebook] = info
+	return nil
+}
+
+// getIndex returns the index for the provided notebook, loading it from
+// file or building it from the notebook's notes if necessary
+// It must be called with s.mu and the notebook's index lock held
+func (s *searcher) getIndex(notebook string) (*index, error) {
+	if checker, ok := s.DAL.(dal.EncryptionChecker); ok {
+		encrypted, err := checker.IsEncrypted(notebook)
+		if err != nil {
+			return nil, fmt.Errorf("check notebook encryption: %w", err)
+		}
+		if encrypted {
+			err = s.invalidate(notebook)
+			if err != nil {
+				return nil, err
+			}
+			return nil, errEncryptedNotebook
+		}
+	}
+
+	if idx, ok := s.indexes[notebook]; ok && s.isCurrent(notebook) {
+		return idx, nil
+	}
+	delete(s.indexes, notebook)
+	delete(s.infos, notebook)
+
+	idx, err := loadIndex(s.indexPath(notebook))
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, errStaleIndex) {
+		idx, err = s.buildIndex(notebook)
+		if err != nil {
+			return nil, fmt.Errorf("build search index for %q: %w", notebook, err)
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		err = s.recordInfo(notebook)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.indexes[notebook] = idx
+	return idx, nil
+}
+
+// buildIndex reads every note in the provided notebook and saves the
+// resulting index to file
+// It must be called with s.mu and the notebook's index lock held
+func (s *searcher) buildIndex(notebook string) (*index, error) {
+	current := s.DAL.GetNotebook()
+	if notebook != current {
+		err := s.DAL.SetNotebook(notebook)
+		if err != nil {
+			return nil, fmt.Errorf("set notebook: %w", err)
+		}
+		defer s.DAL.SetNotebook(current)
+	}
+
+	metas, err := s.DAL.GetAllNoteMetas()
+	if err != nil {
+		return nil, fmt.Errorf("get note metas: %w", err)
+	}
+
+	idx := newIndex()
+	for id := range metas {
+		note, err := s.DAL.GetNote(id)
+		if err != nil {
+			// the note index may be out of date, index what can be read
+			continue
+		}
+		idx.add(note)
+	}
+
+	err = s.save(notebook, idx)
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// save writes the provided notebook's index to file
+// It must be called with s.mu and the notebook's index lock held
+func (s *searcher) save(notebook string, idx *index) error {
+	err := s.ensureDirectory()
+	if err != nil {
+		return err
+	}
+
+	err = saveIndex(s.indexPath(notebook), idx)
+	if err != nil {
+		return err
+	}
+	return s.recordInfo(notebook)
+}
+
+// invalidate drops the notebook's index so that it is rebuilt on next access
+// It must be called with s.mu held
+func (s *searcher) invalidate(notebook string) error {
+	delete(s.indexes, notebook)
+	delete(s.infos, notebook)
+
+	err := os.Remove(s.indexPath(notebook))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove search index file: %w", err)
+	}
+	return nil
+}
+
+// update applies fn to the current notebook's index and saves it, reloading
+// the index first if another process has changed it. If the index can't be
+// updated, it's invalidated rather than left inconsistent
+func (s *searcher) update(fn func(*index)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notebook := s.DAL.GetNotebook()
+	err := s.withLock(notebook, func() error {
+		idx, err := s.getIndex(notebook)
+		if errors.Is(err, errEncryptedNotebook) {
+			return nil
+		} else if err != nil {
+			return s.invalidate(notebook)
+		}
+
+		fn(idx)
+		err = s.save(notebook, idx)
+		if err != nil {
+			return s.invalidate(notebook)
+		}
+		return nil
+	})
+	if errors.Is(err, dal.ErrLocked) {
+		// the index can't be trusted if this update was skipped, so make sure
+		// it's rebuilt by whichever process next gets the lock
+		s.invalidate(notebook)
+	}
+	return err
+}
+
+// ReserveNoteID reserves the next note ID with the underlying DAL
+func (s *searcher) ReserveNoteID() (*notes.Meta, error) {
+	return dal.ReserveNoteID(s.DAL)
+}
+
+// UpdateMeta updates the meta with the underlying DAL
+func (s *searcher) UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error) {
+	return dal.UpdateMeta(s.DAL, update)
+}
+
+// SaveNote saves the note and updates the search index
+func (s *searcher) SaveNote(note *notes.Note) error {
+	err := s.DAL.SaveNote(note)
+	if err != nil {
+		return err
+	}
+
+	err = s.update(func(idx *index) { idx.add(note) })
+	if err != nil {
+		return fmt.Errorf("update search index: %w", err)
+	}
+	return nil
+}
+
+// RemoveNote removes the note and updates the search index
+func (s *searcher) RemoveNote(id int) error {
+	err := s.DAL.RemoveNote(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.update(func(idx *index) { idx.remove(id) })
+	if err != nil {
+		return fmt.Errorf("update search index: %w", err)
+	}
+	return nil
+}
+
+// RenameNotebook renames the notebook and moves its search index
+func (s *searcher) RenameNotebook(oldName, newName string) error {
+	err := s.DAL.RenameNotebook(oldName, newName)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.indexes, oldName)
+	delete(s.infos, oldName)
+	err = os.Rename(s.indexPath(oldName), s.indexPath(newName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rename search index file: %w", err)
+	}
+	return nil
+}
+
+// RemoveNotebook removes the notebook and its search index
+func (s *searcher) RemoveNotebook(name string, recursive bool) error {
+	err := s.DAL.RemoveNotebook(name, recursive)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.invalidate(name)
+}
+
+// Rebuild discards the notebook's search index and rebuilds it from the
+// notebook's notes
+func (s *searcher) Rebuild(notebook string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.withLock(notebook, func() error {
+		err := s.invalidate(notebook)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.getIndex(notebook)
+		if errors.Is(err, errEncryptedNotebook) {
+			return nil
+		}
+		return err
+	})
+}
+
+// Search ranks notes by their relevance to the query, returning those with
+// a non-zero score in descending order. Encrypted notebooks aren't searched
+func (s *searcher) Search(query string, options Options) ([]Result, error) {
+	var terms []string
+	for _, t := range tokenize(query) {
+		terms = append(terms, t.term)
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("query contains no searchable terms")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notebooks := options.Notebooks
+	if len(notebooks) == 0 {
+		notebooks = []string{s.DAL.GetNotebook()}
+	}
+
+	var results []Result
+	for _, notebook := range notebooks {
+		var idx *index
+		err := s.withLock(notebook, func() error {
+			var err error
+			idx, err = s.getIndex(notebook)
+			return err
+		})
+		if errors.Is(err, errEncryptedNotebook) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for id, score := range idx.score(terms) {
+			doc := idx.Documents[id]
+			if doc.Deleted && !options.IncludeDeleted {
+				continue
+			}
+
+			results = append(results, Result{
+				Notebook: notebook,
+				ID:       id,
+				Title:    doc.Title,
+				Deleted:  doc.Deleted,
+				Score:    score,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score == results[j].Score {
+			if results[i].Notebook == results[j].Notebook {
+				return results[i].ID > results[j].ID
+			}
+			return results[i].Notebook < results[j].Notebook
+		}
+		return results[i].Score > results[j].Score
+	})
+
+	if options.Limit > 0 && len(results) > options.Limit {
+		results = results[:options.Limit]
+	}
+
+	err := s.addSnippets(results, terms, options.SnippetLength)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// addSnippets populates each result's snippet from its note body
+// It must be called with s.mu held
+func (s *searcher) addSnippets(results []Result, terms []string, length int) error {
+	if length == 0 {
+		length = defaultSnippetLength
+	} else if length < 0 {
+		return nil
+	}
+
+	current := s.DAL.GetNotebook()
+	defer s.DAL.SetNotebook(current)
+
+	for i := range results {
+		if results[i].Notebook != s.DAL.GetNotebook() {
+			err := s.DAL.SetNotebook(results[i].Notebook)
+			if err != nil {
+				return fmt.Errorf("set notebook: %w", err)
+			}
+		}
+
+		note, err := s.DAL.GetNote(results[i].ID)
+		if err != nil {
+			continue
+		}
+		results[i].Snippet = snippet(note.Body, terms, length)
+	}
+
+	return nil
+}
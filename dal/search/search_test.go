@@ -0,0 +1,217 @@
+package search
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+func newTestSearcher(t *testing.T) Searcher {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	return openTestSearcher(t, home)
+}
+
+// openTestSearcher opens another searcher over the notes in home, as a
+// separate process would
+func openTestSearcher(t *testing.T, home string) Searcher {
+	t.Helper()
+
+	d, err := dal.NewSQLite(".notes", "v0.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSearcher(d, path.Join(home, ".notes", ".search"))
+}
+
+func newTestNote(id int, title, body string) *notes.Note {
+	return &notes.Note{
+		Meta: notes.NoteMeta{
+			ID:      id,
+			Title:   title,
+			Created: notes.JSONTime{Time: time.Now()},
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+		},
+		Body: body,
+	}
+}
+
+func resultIDs(results []Result) []int {
+	var ids []int
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	var terms []string
+	for _, tok := range tokenize("Hello, World! it's 2024-05-03") {
+		terms = append(terms, tok.term)
+	}
+
+	expected := []string{"hello", "world", "it", "s", "2024", "05", "03"}
+	if diff := deep.Equal(terms, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := "the quick brown fox jumps over the lazy dog"
+
+	got := snippet(text, []string{"fox"}, 11)
+	if got != "...brown fox jumps..." {
+		t.Errorf("snippet = %q", got)
+	}
+
+	got = snippet(text, []string{"quick"}, 100)
+	if got != text {
+		t.Errorf("snippet = %q", got)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	s := newTestSearcher(t)
+
+	for _, note := range []*notes.Note{
+		newTestNote(1, "groceries", "eggs milk bread"),
+		newTestNote(2, "deploy notes", "deploy the service, then deploy the worker"),
+		newTestNote(3, "standup", "talked about the deploy schedule and other things entirely"),
+	} {
+		err := s.SaveNote(note)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := s.Search("deploy", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(resultIDs(results), []int{2, 3}); diff != nil {
+		t.Error(diff)
+	}
+	if results[0].Snippet == "" {
+		t.Error("expected snippet")
+	}
+
+	err = s.RemoveNote(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err = s.Search("deploy", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(resultIDs(results), []int{3}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSearchDeleted(t *testing.T) {
+	s := newTestSearcher(t)
+
+	note := newTestNote(1, "secret", "hidden body")
+	note.Meta.Deleted = notes.JSONTime{Time: time.Now()}
+	err := s.SaveNote(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.Search("hidden", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results, got %v", resultIDs(results))
+	}
+
+	results, err = s.Search("hidden", Options{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(resultIDs(results), []int{1}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSearchRebuild(t *testing.T) {
+	s := newTestSearcher(t)
+
+	err := s.CreateNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// save directly to the underlying DAL so the search index isn't updated
+	err = s.(*searcher).DAL.SaveNote(newTestNote(1, "roadmap", "quarterly planning"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetNotebook("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Rebuild("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.GetNotebook() != "default" {
+		t.Errorf("notebook = %q, expected default", s.GetNotebook())
+	}
+
+	results, err := s.Search("planning", Options{Notebooks: []string{"default", "work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Notebook != "work" || results[0].Snippet != "quarterly planning" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestSearchSharedIndex(t *testing.T) {
+	a := newTestSearcher(t)
+	b := openTestSearcher(t, os.Getenv("HOME"))
+
+	// load the index into both searchers before either saves
+	for _, s := range []Searcher{a, b} {
+		_, err := s.Search("anything", Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := a.SaveNote(newTestNote(1, "alpha", "first body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.SaveNote(newTestNote(2, "beta", "second body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]Searcher{"a": a, "b": b} {
+		results, err := s.Search("body", Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := deep.Equal(resultIDs(results), []int{2, 1}); diff != nil {
+			t.Errorf("searcher %s: %v", name, diff)
+		}
+	}
+}
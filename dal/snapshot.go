@@ -0,0 +1,104 @@
+package dal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+const (
+	defaultSnapshotFilename = ".index.snapshot"
+	snapshotVersion         = 1
+)
+
+var snapshotMagic = [6]byte{'n', 't', 's', 'i', 'd', 'x'}
+
+// errSnapshotMismatch indicates that an index snapshot is corrupt or wasn't
+// taken of the index file as it is now
+var errSnapshotMismatch = errors.New("index snapshot doesn't match index")
+
+// snapshotHeader precedes the gob-encoded index in a snapshot file. The index
+// file's modification time and size identify the version of the index the
+// snapshot was taken of, so that a snapshot left stale by a process writing
+// only the JSON index isn't used
+type snapshotHeader struct {
+	Magic        [6]byte
+	Version      uint8
+	IndexModTime int64 // unix nanoseconds
+	IndexSize    int64
+	Checksum     [sha256.Size]byte // of the encoded index
+}
+
+// writeSnapshot writes a binary snapshot of the index, as described by the
+// index file's info after it was written
+func writeSnapshot(snapshotPath string, index map[int]notes.NoteMeta, indexInfo os.FileInfo) error {
+	var payload bytes.Buffer
+	err := gob.NewEncoder(&payload).Encode(index)
+	if err != nil {
+		return fmt.Errorf("encode index: %w", err)
+	}
+
+	header := snapshotHeader{
+		Magic:        snapshotMagic,
+		Version:      snapshotVersion,
+		IndexModTime: indexInfo.ModTime().UnixNano(),
+		IndexSize:    indexInfo.Size(),
+		Checksum:     sha256.Sum256(payload.Bytes()),
+	}
+
+	var buf bytes.Buffer
+	err = binary.Write(&buf, binary.LittleEndian, header)
+	if err != nil {
+		return fmt.Errorf("encode header: %w", err)
+	}
+	buf.Write(payload.Bytes())
+
+	return WriteFileAtomic(snapshotPath, buf.Bytes())
+}
+
+// loadSnapshot reads a binary snapshot of the index, returning an error
+// wrapping errSnapshotMismatch if the snapshot is corrupt or the index file,
+// as described by indexInfo, has changed since the snapshot was written
+func loadSnapshot(snapshotPath string, indexInfo os.FileInfo) (map[int]notes.NoteMeta, error) {
+	b, err := os.ReadFile(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot file: %w", err)
+	}
+
+	r := bytes.NewReader(b)
+	var header snapshotHeader
+	err = binary.Read(r, binary.LittleEndian, &header)
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", errSnapshotMismatch)
+	}
+
+	switch {
+	case header.Magic != snapshotMagic:
+		return nil, fmt.Errorf("not a snapshot file: %w", errSnapshotMismatch)
+	case header.Version != snapshotVersion:
+		return nil, fmt.Errorf("snapshot version %d: %w", header.Version, errSnapshotMismatch)
+	case header.IndexModTime != indexInfo.ModTime().UnixNano() || header.IndexSize != indexInfo.Size():
+		return nil, fmt.Errorf("index modified: %w", errSnapshotMismatch)
+	}
+
+	payload := b[len(b)-r.Len():]
+	if sha256.Sum256(payload) != header.Checksum {
+		return nil, fmt.Errorf("checksum: %w", errSnapshotMismatch)
+	}
+
+	var index map[int]notes.NoteMeta
+	err = gob.NewDecoder(bytes.NewReader(payload)).Decode(&index)
+	if err != nil {
+		return nil, fmt.Errorf("decode index: %w", errSnapshotMismatch)
+	}
+	if index == nil {
+		index = make(map[int]notes.NoteMeta, defaultIndexCapacity)
+	}
+	return index, nil
+}
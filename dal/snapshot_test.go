@@ -0,0 +1,106 @@
+package dal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := path.Join(dir, defaultIndexFilename)
+	snapshotPath := path.Join(dir, defaultSnapshotFilename)
+
+	index := map[int]notes.NoteMeta{
+		1: {
+			ID:      1,
+			Title:   "first",
+			Created: notes.JSONTime{Time: time.Unix(0, 1500000000000000000)},
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+			History: []notes.EditHistory{{Updated: notes.JSONTime{Time: time.Unix(0, 1600000000000000000)}, Size: 12}},
+			Tags:    []string{"a", "b"},
+		},
+		2: {ID: 2, Title: "second"},
+	}
+
+	err := saveIndex(indexPath, index)
+	if err != nil {
+		t.Fatalf("save index: %s", err)
+	}
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatalf("stat index: %s", err)
+	}
+
+	err = writeSnapshot(snapshotPath, index, info)
+	if err != nil {
+		t.Fatalf("write snapshot: %s", err)
+	}
+
+	loaded, err := loadSnapshot(snapshotPath, info)
+	if err != nil {
+		t.Fatalf("load snapshot: %s", err)
+	}
+
+	// compared as JSON, as that's how the index is stored
+	expected, _ := json.Marshal(index)
+	got, _ := json.Marshal(loaded)
+	if string(got) != string(expected) {
+		t.Errorf("loaded snapshot %s, expected %s", got, expected)
+	}
+}
+
+func TestSnapshotMismatch(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := path.Join(dir, defaultIndexFilename)
+	snapshotPath := path.Join(dir, defaultSnapshotFilename)
+
+	index := map[int]notes.NoteMeta{1: {ID: 1, Title: "note"}}
+	err := saveIndex(indexPath, index)
+	if err != nil {
+		t.Fatalf("save index: %s", err)
+	}
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatalf("stat index: %s", err)
+	}
+
+	for name, corrupt := range map[string]func(){
+		"index modified": func() {
+			later := info.ModTime().Add(time.Second)
+			os.Chtimes(indexPath, later, later)
+		},
+		"checksum": func() {
+			b, _ := os.ReadFile(snapshotPath)
+			b[len(b)-1] ^= 0xff
+			os.WriteFile(snapshotPath, b, defaultFileMode)
+		},
+		"truncated": func() {
+			os.WriteFile(snapshotPath, []byte("nts"), defaultFileMode)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			os.Chtimes(indexPath, info.ModTime(), info.ModTime())
+			err := writeSnapshot(snapshotPath, index, info)
+			if err != nil {
+				t.Fatalf("write snapshot: %s", err)
+			}
+			corrupt()
+
+			current, err := os.Stat(indexPath)
+			if err != nil {
+				t.Fatalf("stat index: %s", err)
+			}
+
+			_, err = loadSnapshot(snapshotPath, current)
+			if !errors.Is(err, errSnapshotMismatch) {
+				t.Errorf("load snapshot returned %v, expected %v", err, errSnapshotMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,567 @@
+package dal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/subtlepseudonym/notes"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	defaultDatabaseFilename = "notes.db"
+	// transactions take the write lock when they begin so that a transaction
+	// reading the meta before updating it can't be interleaved with another
+	// process's write
+	sqliteDSNOptions = "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+)
+
+var errNotebookExists = errors.New("notebook already exists")
+
+// sqliteMigrations are applied in order to bring the database schema up to
+// date. The database's user_version records how many have been applied
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS notebooks (
+		name TEXT PRIMARY KEY,
+		meta TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS notes (
+		notebook TEXT NOT NULL REFERENCES notebooks(name) ON UPDATE CASCADE ON DELETE CASCADE,
+		id       INTEGER NOT NULL,
+		meta     TEXT NOT NULL,
+		body     TEXT NOT NULL,
+		PRIMARY KEY (notebook, id)
+	);`,
+	`ALTER TABLE notes ADD COLUMN revisions TEXT NOT NULL DEFAULT 'null';`,
+}
+
+// sqlite stores notebooks, notes, and their meta information in a single
+// sqlite database. Meta and NoteMeta objects are stored as json so that
+// their encoding matches that of the local DAL
+type sqlite struct {
+	sync.Mutex
+	db       *sql.DB
+	notebook string
+	version  string
+}
+
+// NewSQLite initializes a DAL backed by a sqlite database in the provided
+// directory. A relative directory is relative to the user's home directory
+func NewSQLite(dirName, version string) (DAL, error) {
+	baseDirectory, err := resolveDirectory(dirName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = createDirectory(baseDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("create base directory: %v", err)
+	}
+
+	return newSQLite(path.Join(baseDirectory, defaultDatabaseFilename), version)
+}
+
+func newSQLite(dbPath, version string) (*sqlite, error) {
+	db, err := sql.Open("sqlite3", "file:"+dbPath+sqliteDSNOptions)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+	db.SetMaxOpenConns(1) // serialize access to the database file
+
+	err = migrate(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate schema: %v", err)
+	}
+
+	d := &sqlite{
+		db:       db,
+		notebook: defaultNotebook,
+		version:  version,
+	}
+
+	err = d.insertNotebook(defaultNotebook)
+	if err != nil && !errors.Is(err, errNotebookExists) {
+		db.Close()
+		return nil, fmt.Errorf("create default notebook: %v", err)
+	}
+
+	return d, nil
+}
+
+// migrate applies any sqliteMigrations that haven't yet been applied
+func migrate(db *sql.DB) error {
+	var version int
+	err := db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("get schema version: %v", err)
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("begin transaction: %v", err)
+		}
+
+		_, err = tx.Exec(sqliteMigrations[version])
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d: %v", version, err)
+		}
+
+		// pragma statements don't support placeholders
+		_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1))
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("set schema version: %v", err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf("commit migration %d: %v", version, err)
+		}
+	}
+
+	return nil
+}
+
+// insertNotebook creates the named notebook and its meta, returning
+// errNotebookExists if the notebook is already present
+func (d *sqlite) insertNotebook(name string) error {
+	b, err := json.Marshal(&notes.Meta{Version: d.version})
+	if err != nil {
+		return fmt.Errorf("encode meta: %v", err)
+	}
+
+	res, err := d.db.Exec(`INSERT OR IGNORE INTO notebooks (name, meta) VALUES (?, ?)`, name, string(b))
+	if err != nil {
+		return fmt.Errorf("insert notebook: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %v", err)
+	}
+	if n == 0 {
+		return errNotebookExists
+	}
+	return nil
+}
+
+func (d *sqlite) notebookExists(name string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM notebooks WHERE name = ?`, name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("query notebook: %v", err)
+	}
+	return count > 0, nil
+}
+
+// GetMeta retrieves and decodes the current notebook's Meta
+func (d *sqlite) GetMeta() (*notes.Meta, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var encoded string
+	err := d.db.QueryRow(`SELECT meta FROM notebooks WHERE name = ?`, d.notebook).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("notebook %q not found", d.notebook)
+	} else if err != nil {
+		return nil, fmt.Errorf("query meta: %v", err)
+	}
+
+	var m notes.Meta
+	err = json.Unmarshal([]byte(encoded), &m)
+	if err != nil {
+		return nil, fmt.Errorf("decode meta: %v", err)
+	}
+	return &m, nil
+}
+
+// SaveMeta encodes and saves the provided Meta to the current notebook
+func (d *sqlite) SaveMeta(meta *notes.Meta) error {
+	d.Lock()
+	defer d.Unlock()
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("encode meta: %v", err)
+	}
+
+	res, err := d.db.Exec(`UPDATE notebooks SET meta = ? WHERE name = ?`, string(b), d.notebook)
+	if err != nil {
+		return fmt.Errorf("update meta: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("notebook %q not found", d.notebook)
+	}
+	return nil
+}
+
+// ReserveNoteID increments the current notebook's latest ID and saves its meta
+// in a single transaction, so that concurrent writers never reserve the same ID
+func (d *sqlite) ReserveNoteID() (*notes.Meta, error) {
+	return d.updateMeta(func(tx *sql.Tx, meta *notes.Meta) error {
+		id := meta.LatestID + 1
+
+		var count int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM notes WHERE notebook = ? AND id = ?`, d.notebook, id).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("query note: %v", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("note ID %d (%x) already exists", id, id)
+		}
+
+		meta.LatestID = id
+		return nil
+	})
+}
+
+// UpdateMeta applies update to the current notebook's meta and saves it in a
+// single transaction
+func (d *sqlite) UpdateMeta(update func(*notes.Meta)) (*notes.Meta, error) {
+	return d.updateMeta(func(_ *sql.Tx, meta *notes.Meta) error {
+		update(meta)
+		return nil
+	})
+}
+
+// updateMeta reads the current notebook's meta, applies update, and saves the
+// result within one transaction. Nothing is saved if update returns an error
+func (d *sqlite) updateMeta(update func(*sql.Tx, *notes.Meta) error) (*notes.Meta, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var encoded string
+	err = tx.QueryRow(`SELECT meta FROM notebooks WHERE name = ?`, d.notebook).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("notebook %q not found", d.notebook)
+	} else if err != nil {
+		return nil, fmt.Errorf("query meta: %v", err)
+	}
+
+	var meta notes.Meta
+	err = json.Unmarshal([]byte(encoded), &meta)
+	if err != nil {
+		return nil, fmt.Errorf("decode meta: %v", err)
+	}
+
+	err = update(tx, &meta)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setMetaSize(&meta)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(&meta)
+	if err != nil {
+		return nil, fmt.Errorf("encode meta: %v", err)
+	}
+
+	_, err = tx.Exec(`UPDATE notebooks SET meta = ? WHERE name = ?`, string(b), d.notebook)
+	if err != nil {
+		return nil, fmt.Errorf("update meta: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("commit meta: %v", err)
+	}
+	return &meta, nil
+}
+
+func (d *sqlite) CreateNotebook(name string) error {
+	err := ValidateNotebookName(name)
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	err = d.insertNotebook(name)
+	if err != nil && !errors.Is(err, errNotebookExists) {
+		return fmt.Errorf("create notebook: %v", err)
+	}
+
+	return nil
+}
+
+func (d *sqlite) GetNotebook() string {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.notebook
+}
+
+func (d *sqlite) GetAllNotebooks() []string {
+	d.Lock()
+	defer d.Unlock()
+
+	rows, err := d.db.Query(`SELECT name FROM notebooks`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var notebooks []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			continue
+		}
+		notebooks = append(notebooks, name)
+	}
+
+	return notebooks
+}
+
+func (d *sqlite) SetNotebook(name string) error {
+	if name == "" {
+		return fmt.Errorf("notebook name cannot be blank string")
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	exists, err := d.notebookExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("notebook %q not found", name)
+	}
+
+	d.notebook = name
+	return nil
+}
+
+func (d *sqlite) RenameNotebook(oldName, newName string) error {
+	if oldName == "" {
+		return fmt.Errorf("notebook name cannot be blank string")
+	}
+	err := ValidateNotebookName(newName)
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	exists, err := d.notebookExists(newName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("notebook %q already exists", newName)
+	}
+
+	res, err := d.db.Exec(`UPDATE notebooks SET name = ? WHERE name = ?`, newName, oldName)
+	if err != nil {
+		return fmt.Errorf("rename notebook: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("notebook %q not found", oldName)
+	}
+
+	if d.notebook == oldName {
+		d.notebook = newName
+	}
+	return nil
+}
+
+func (d *sqlite) RemoveNotebook(name string, recursive bool) error {
+	if name == "" {
+		return fmt.Errorf("notebook name cannot be blank string")
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	exists, err := d.notebookExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("notebook %q not found", name)
+	}
+
+	if !recursive {
+		var count int
+		err = d.db.QueryRow(`SELECT COUNT(*) FROM notes WHERE notebook = ?`, name).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("count notes: %v", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("notebook %q is not empty", name)
+		}
+	}
+
+	// notes are removed by foreign key cascade
+	_, err = d.db.Exec(`DELETE FROM notebooks WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete notebook: %v", err)
+	}
+
+	return nil
+}
+
+func (d *sqlite) GetNoteMeta(id int) (*notes.NoteMeta, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var encoded string
+	err := d.db.QueryRow(`SELECT meta FROM notes WHERE notebook = ? AND id = ?`, d.notebook, id).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("note meta not in index")
+	} else if err != nil {
+		return nil, fmt.Errorf("query note meta: %v", err)
+	}
+
+	var noteMeta notes.NoteMeta
+	err = json.Unmarshal([]byte(encoded), &noteMeta)
+	if err != nil {
+		return nil, fmt.Errorf("decode note meta: %v", err)
+	}
+	return &noteMeta, nil
+}
+
+func (d *sqlite) GetAllNoteMetas() (map[int]notes.NoteMeta, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	rows, err := d.db.Query(`SELECT id, meta FROM notes WHERE notebook = ?`, d.notebook)
+	if err != nil {
+		return nil, fmt.Errorf("query note metas: %v", err)
+	}
+	defer rows.Close()
+
+	index := make(map[int]notes.NoteMeta, defaultIndexCapacity)
+	for rows.Next() {
+		var id int
+		var encoded string
+		err = rows.Scan(&id, &encoded)
+		if err != nil {
+			return nil, fmt.Errorf("scan note meta: %v", err)
+		}
+
+		var noteMeta notes.NoteMeta
+		err = json.Unmarshal([]byte(encoded), &noteMeta)
+		if err != nil {
+			return nil, fmt.Errorf("decode note meta %d: %v", id, err)
+		}
+		index[id] = noteMeta
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate note metas: %v", err)
+	}
+	return index, nil
+}
+
+// GetNote retrieves and decodes a Note from the current notebook
+func (d *sqlite) GetNote(id int) (*notes.Note, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var encoded, body, revisions string
+	err := d.db.QueryRow(`SELECT meta, body, revisions FROM notes WHERE notebook = ? AND id = ?`, d.notebook, id).Scan(&encoded, &body, &revisions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("note %d not found: %w", id, os.ErrNotExist)
+	} else if err != nil {
+		return nil, fmt.Errorf("query note: %v", err)
+	}
+
+	n := notes.Note{
+		Body: body,
+	}
+	err = json.Unmarshal([]byte(encoded), &n.Meta)
+	if err != nil {
+		return nil, fmt.Errorf("decode note meta: %v", err)
+	}
+
+	err = json.Unmarshal([]byte(revisions), &n.Revisions)
+	if err != nil {
+		return nil, fmt.Errorf("decode note revisions: %v", err)
+	}
+	return &n, nil
+}
+
+// SaveNote encodes and saves the provided Note to the current notebook
+func (d *sqlite) SaveNote(note *notes.Note) error {
+	d.Lock()
+	defer d.Unlock()
+
+	b, err := json.Marshal(note.Meta)
+	if err != nil {
+		return fmt.Errorf("encode note meta: %v", err)
+	}
+
+	revisions, err := json.Marshal(note.Revisions)
+	if err != nil {
+		return fmt.Errorf("encode note revisions: %v", err)
+	}
+
+	_, err = d.db.Exec(
+		`INSERT INTO notes (notebook, id, meta, body, revisions) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (notebook, id) DO UPDATE SET meta = excluded.meta, body = excluded.body, revisions = excluded.revisions`,
+		d.notebook,
+		note.Meta.ID,
+		string(b),
+		note.Body,
+		string(revisions),
+	)
+	if err != nil {
+		return fmt.Errorf("save note: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveNote deletes the note from the current notebook
+func (d *sqlite) RemoveNote(id int) error {
+	d.Lock()
+	defer d.Unlock()
+
+	res, err := d.db.Exec(`DELETE FROM notes WHERE notebook = ? AND id = ?`, d.notebook, id)
+	if err != nil {
+		return fmt.Errorf("delete note: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("remove note: %w", os.ErrNotExist)
+	}
+
+	return nil
+}
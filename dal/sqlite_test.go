@@ -0,0 +1,309 @@
+package dal
+
+import (
+	"encoding/json"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/subtlepseudonym/notes"
+)
+
+func newTestSQLite(t *testing.T) *sqlite {
+	t.Helper()
+
+	return openTestSQLite(t, path.Join(t.TempDir(), defaultDatabaseFilename))
+}
+
+// openTestSQLite opens the database at dbPath, closing it when the test
+// finishes
+func openTestSQLite(t *testing.T, dbPath string) *sqlite {
+	t.Helper()
+
+	d, err := newSQLite(dbPath, "v0.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.db.Close() })
+
+	return d
+}
+
+// assertJSONEqual compares values by their json encoding, as deep.Equal
+// can't compare notes.JSONTime values
+func assertJSONEqual(t *testing.T, got, expected interface{}) {
+	t.Helper()
+
+	g, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(g) != string(e) {
+		t.Errorf("got %s, expected %s", g, e)
+	}
+}
+
+func TestSQLiteDALMetaPerNotebook(t *testing.T) {
+	d := newTestSQLite(t)
+
+	meta, err := d.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.LatestID = 7
+	err = d.SaveMeta(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.CreateNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SetNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err = d.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.LatestID != 0 {
+		t.Errorf("new notebook latestID = %d, expected 0", meta.LatestID)
+	}
+
+	err = d.SetNotebook(defaultNotebook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err = d.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.LatestID != 7 {
+		t.Errorf("default notebook latestID = %d, expected 7", meta.LatestID)
+	}
+}
+
+func TestSQLiteDALSaveNote(t *testing.T) {
+	d := newTestSQLite(t)
+
+	note := &notes.Note{
+		Meta: notes.NoteMeta{
+			ID:      1,
+			Title:   "title",
+			Created: notes.JSONTime{Time: time.Unix(0, 100)},
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+		},
+		Body: "body",
+	}
+	err := d.SaveNote(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// soft delete is recorded by saving the note with a deletion time
+	note.Meta.Deleted = notes.JSONTime{Time: time.Unix(0, 200)}
+	err = d.SaveNote(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.GetNote(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, got, note)
+
+	index, err := d.GetAllNoteMetas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, index, map[int]notes.NoteMeta{1: note.Meta})
+
+	err = d.CreateNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SetNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.GetNote(1); err == nil {
+		t.Error("expected note to be scoped to its notebook")
+	}
+}
+
+func TestSQLiteDALRemoveNote(t *testing.T) {
+	d := newTestSQLite(t)
+
+	err := d.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.RemoveNote(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.GetNoteMeta(1); err == nil {
+		t.Error("expected note meta to be removed")
+	}
+
+	err = d.RemoveNote(1)
+	if err == nil {
+		t.Error("expected error removing missing note")
+	}
+}
+
+func TestSQLiteDALRemoveNotebook(t *testing.T) {
+	d := newTestSQLite(t)
+
+	err := d.CreateNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SetNotebook("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.RemoveNotebook("work", false)
+	if err == nil {
+		t.Error("expected error removing non-empty notebook")
+	}
+
+	err = d.RemoveNotebook("work", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(d.GetAllNotebooks(), []string{defaultNotebook}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSQLiteDALReserveNoteIDConcurrently(t *testing.T) {
+	dbPath := path.Join(t.TempDir(), defaultDatabaseFilename)
+
+	// separate DALs over the same database only share the database's locks,
+	// as separate processes would
+	var dals []*sqlite
+	for i := 0; i < 4; i++ {
+		dals = append(dals, openTestSQLite(t, dbPath))
+	}
+
+	const reservations = 20
+	ids := make(chan int, len(dals)*reservations)
+	errs := make(chan error, len(dals)*reservations)
+	var wg sync.WaitGroup
+	for _, d := range dals {
+		wg.Add(1)
+		go func(d *sqlite) {
+			defer wg.Done()
+			for i := 0; i < reservations; i++ {
+				meta, err := d.ReserveNoteID()
+				if err != nil {
+					errs <- err
+					return
+				}
+				ids <- meta.LatestID
+			}
+		}(d)
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("reserve note ID: %s", err)
+	}
+
+	reserved := make(map[int]bool)
+	for id := range ids {
+		if reserved[id] {
+			t.Errorf("note ID %d reserved more than once", id)
+		}
+		reserved[id] = true
+	}
+
+	meta, err := dals[0].GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	if meta.LatestID != len(dals)*reservations {
+		t.Errorf("latest ID is %d, expected %d", meta.LatestID, len(dals)*reservations)
+	}
+}
+
+func TestSQLiteDALUpdateMetaConcurrently(t *testing.T) {
+	dbPath := path.Join(t.TempDir(), defaultDatabaseFilename)
+
+	var dals []*sqlite
+	for i := 0; i < 4; i++ {
+		dals = append(dals, openTestSQLite(t, dbPath))
+	}
+
+	const updates = 10
+	var wg sync.WaitGroup
+	for _, d := range dals {
+		wg.Add(1)
+		go func(d *sqlite) {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				_, err := d.UpdateMeta(func(meta *notes.Meta) {
+					meta.LatestID++
+					// give other DALs the chance to read the meta before
+					// this update is saved
+					time.Sleep(time.Millisecond)
+				})
+				if err != nil {
+					t.Errorf("update meta: %s", err)
+					return
+				}
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	meta, err := dals[0].GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	if meta.LatestID != len(dals)*updates {
+		t.Errorf("latest ID is %d, expected %d", meta.LatestID, len(dals)*updates)
+	}
+}
+
+func TestSQLiteDALReserveNoteIDExists(t *testing.T) {
+	d := newTestSQLite(t)
+
+	err := d.SaveNote(&notes.Note{Meta: notes.NoteMeta{ID: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = d.ReserveNoteID()
+	if err == nil {
+		t.Fatal("expected an error reserving an existing note's ID")
+	}
+
+	meta, err := d.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.LatestID != 0 {
+		t.Errorf("latest ID is %d, expected the failed reservation to save nothing", meta.LatestID)
+	}
+}
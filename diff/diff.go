@@ -0,0 +1,257 @@
+// Package diff computes line-based differences between texts
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxEditDistance bounds the work done searching for a minimal diff. Inputs
+// that differ by more lines than this are diffed as a wholesale replacement
+const maxEditDistance = 1024
+
+// Op is the kind of operation performed by an Edit
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is a single line operation in the transformation of one text into
+// another
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// SplitLines splits text into lines, retaining line endings so that joining
+// the result reproduces the original text
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the edits that transform a into b
+func Lines(a, b []string) []Edit {
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Op: Equal, Line: line})
+	}
+
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Op: Equal, Line: line})
+	}
+	return edits
+}
+
+// myers finds a shortest edit script using Myers' O(ND) algorithm, falling
+// back to replacing a with b if the edit distance exceeds maxEditDistance
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD > maxEditDistance {
+		maxD = maxEditDistance
+	}
+
+	offset := maxD + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		return replace(a, b)
+	}
+
+	// walk the trace backwards to recover the edit script
+	var reversed []Edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Edit{Op: Equal, Line: a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Edit{Op: Insert, Line: b[prevY]})
+			} else {
+				reversed = append(reversed, Edit{Op: Delete, Line: a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	edits := make([]Edit, len(reversed))
+	for i, edit := range reversed {
+		edits[len(reversed)-1-i] = edit
+	}
+	return edits
+}
+
+func replace(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, Edit{Op: Delete, Line: line})
+	}
+	for _, line := range b {
+		edits = append(edits, Edit{Op: Insert, Line: line})
+	}
+	return edits
+}
+
+// Unified formats the differences between a and b as a unified diff with the
+// provided number of context lines
+func Unified(aName, bName, a, b string, context int) string {
+	edits := Lines(SplitLines(a), SplitLines(b))
+
+	var changed bool
+	for _, edit := range edits {
+		if edit.Op != Equal {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+
+	// aLines and bLines track the 0-indexed line number preceding each edit
+	aLines := make([]int, len(edits)+1)
+	bLines := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if edit.Op != Insert {
+			aLines[i+1]++
+		}
+		if edit.Op != Delete {
+			bLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+
+		// extend the hunk until there are more than 2*context equal lines
+		// between changes
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		writeHunk(&sb, edits[start:end], aLines[start], aLines[end]-aLines[start], bLines[start], bLines[end]-bLines[start])
+		i = end
+	}
+
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, edits []Edit, aStart, aCount, bStart, bCount int) {
+	// unified diffs use 1-indexed line numbers, except for empty ranges
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+
+	for _, edit := range edits {
+		switch edit.Op {
+		case Equal:
+			sb.WriteString(" ")
+		case Delete:
+			sb.WriteString("-")
+		case Insert:
+			sb.WriteString("+")
+		}
+
+		sb.WriteString(edit.Line)
+		if !strings.HasSuffix(edit.Line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
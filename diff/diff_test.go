@@ -0,0 +1,56 @@
+package diff
+
+import (
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+
+	expected := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+`
+
+	got := Unified("a", "b", a, b, 3)
+	if got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	if Unified("a", "b", a, a, 3) != "" {
+		t.Error("expected empty diff for equal texts")
+	}
+}
+
+func TestLines(t *testing.T) {
+	a := SplitLines("a\nb\nc\nd\n")
+	b := SplitLines("b\nc\ne\nd\n")
+
+	var ops []Op
+	for _, edit := range Lines(a, b) {
+		ops = append(ops, edit.Op)
+	}
+
+	expected := []Op{Delete, Equal, Equal, Insert, Equal}
+	if len(ops) != len(expected) {
+		t.Fatalf("got %v, expected %v", ops, expected)
+	}
+	for i := range ops {
+		if ops[i] != expected[i] {
+			t.Fatalf("got %v, expected %v", ops, expected)
+		}
+	}
+}
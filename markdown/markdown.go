@@ -0,0 +1,177 @@
+// Package markdown converts notes to and from markdown documents with YAML
+// front matter
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/subtlepseudonym/notes"
+
+	"golang.org/x/text/unicode/norm"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	frontMatterDelimiter = "---"
+	maxSlugLength        = 64
+)
+
+// FrontMatter holds the NoteMeta fields encoded at the top of a markdown
+// document
+type FrontMatter struct {
+	ID      int        `yaml:"id"`
+	Title   string     `yaml:"title"`
+	Created time.Time  `yaml:"created"`
+	Deleted *time.Time `yaml:"deleted,omitempty"`
+	Tags    []string   `yaml:"tags,omitempty"`
+	History []History  `yaml:"history,omitempty"`
+}
+
+// History mirrors notes.EditHistory
+type History struct {
+	Updated time.Time `yaml:"updated"`
+	Size    int       `yaml:"size"`
+}
+
+// NewFrontMatter creates front matter from the provided note meta
+func NewFrontMatter(meta notes.NoteMeta) FrontMatter {
+	fm := FrontMatter{
+		ID:      meta.ID,
+		Title:   meta.Title,
+		Created: meta.Created.UTC(),
+		Tags:    meta.Tags,
+	}
+
+	if meta.IsDeleted() {
+		deleted := meta.Deleted.UTC()
+		fm.Deleted = &deleted
+	}
+
+	for _, h := range meta.History {
+		fm.History = append(fm.History, History{
+			Updated: h.Updated.UTC(),
+			Size:    h.Size,
+		})
+	}
+
+	return fm
+}
+
+// NoteMeta converts the front matter into note meta
+func (fm FrontMatter) NoteMeta() notes.NoteMeta {
+	meta := notes.NoteMeta{
+		ID:      fm.ID,
+		Title:   fm.Title,
+		Created: notes.JSONTime{Time: fm.Created},
+		Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+	}
+	meta.AddTags(fm.Tags...)
+
+	if fm.Deleted != nil {
+		meta.Deleted = notes.JSONTime{Time: *fm.Deleted}
+	}
+
+	for _, h := range fm.History {
+		meta.History = append(meta.History, notes.EditHistory{
+			Updated: notes.JSONTime{Time: h.Updated},
+			Size:    h.Size,
+		})
+	}
+
+	return meta
+}
+
+// Marshal encodes the note as a markdown document, with its meta information
+// as front matter followed by its body
+func Marshal(note *notes.Note) ([]byte, error) {
+	fm, err := yaml.Marshal(NewFrontMatter(note.Meta))
+	if err != nil {
+		return nil, fmt.Errorf("encode front matter: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.Write(fm)
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.WriteString(note.Body)
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal splits a markdown document into its front matter and body. If
+// the document has no front matter, the returned FrontMatter is nil
+func Unmarshal(data []byte) (*FrontMatter, string, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	if !strings.HasPrefix(text, frontMatterDelimiter+"\n") {
+		return nil, text, nil
+	}
+
+	rest := text[len(frontMatterDelimiter)+1:]
+	var encoded, body string
+	if strings.HasPrefix(rest, frontMatterDelimiter+"\n") {
+		body = rest[len(frontMatterDelimiter)+1:]
+	} else {
+		end := strings.Index(rest, "\n"+frontMatterDelimiter+"\n")
+		if end < 0 {
+			if !strings.HasSuffix(rest, "\n"+frontMatterDelimiter) {
+				return nil, text, nil
+			}
+			end = len(rest) - len(frontMatterDelimiter) - 1
+			encoded, body = rest[:end], ""
+		} else {
+			encoded, body = rest[:end], rest[end+len(frontMatterDelimiter)+2:]
+		}
+	}
+
+	var fm FrontMatter
+	err := yaml.Unmarshal([]byte(encoded), &fm)
+	if err != nil {
+		return nil, "", fmt.Errorf("decode front matter: %w", err)
+	}
+
+	return &fm, body, nil
+}
+
+// Slugify converts a title into a lowercase, hyphen separated string safe
+// for use in filenames
+func Slugify(title string) string {
+	var sb strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(title) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop combining marks left over from decomposing accented letters
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if hyphen && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			hyphen = false
+		default:
+			hyphen = true
+		}
+
+		if sb.Len() >= maxSlugLength {
+			break
+		}
+	}
+
+	return sb.String()
+}
+
+// Filename generates the markdown filename for a note. The note ID is
+// included so that notes with the same title don't collide
+func Filename(meta notes.NoteMeta) string {
+	slug := Slugify(meta.Title)
+	if slug == "" {
+		return fmt.Sprintf("%04x.md", meta.ID)
+	}
+	return fmt.Sprintf("%04x-%s.md", meta.ID, slug)
+}
@@ -0,0 +1,80 @@
+package markdown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":              "hello-world",
+		"  Crème brûlée -- recipe  ": "creme-brulee-recipe",
+		"Mon, 02 Jan 2006 15:04 MST": "mon-02-jan-2006-15-04-mst",
+		"!!!":                        "",
+	}
+
+	for title, expected := range tests {
+		if got := Slugify(title); got != expected {
+			t.Errorf("Slugify(%q) = %q, expected %q", title, got, expected)
+		}
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	created := time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)
+	note := &notes.Note{
+		Meta: notes.NoteMeta{
+			ID:      10,
+			Title:   "release: notes",
+			Created: notes.JSONTime{Time: created},
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
+			History: []notes.EditHistory{
+				{Updated: notes.JSONTime{Time: created.Add(time.Hour)}, Size: 42},
+			},
+			Tags: []string{"release"},
+		},
+		Body: "---\nbody with a horizontal rule\n",
+	}
+
+	b, err := Marshal(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fm, body, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm == nil {
+		t.Fatal("expected front matter")
+	}
+	if body != note.Body {
+		t.Errorf("body = %q, expected %q", body, note.Body)
+	}
+
+	meta := fm.NoteMeta()
+	if meta.ID != 10 || meta.Title != note.Meta.Title || !meta.Created.Equal(created) {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+	if !meta.Deleted.Equal(time.Unix(0, 0)) {
+		t.Errorf("deleted = %v, expected unix epoch", meta.Deleted)
+	}
+	if len(meta.History) != 1 || meta.History[0].Size != 42 || !meta.HasTag("release") {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+}
+
+func TestUnmarshalWithoutFrontMatter(t *testing.T) {
+	fm, body, err := Unmarshal([]byte("# plain markdown\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm != nil {
+		t.Errorf("expected nil front matter, got %+v", fm)
+	}
+	if body != "# plain markdown\n" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,164 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ANSI escape sequences used when rendering
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiCyan      = "\x1b[36m"
+)
+
+// Render styles a markdown document with ANSI escape sequences for display in
+// a terminal. The document's text is left intact so that it reads the same
+// with or without styling
+func Render(text string) string {
+	var sb strings.Builder
+	var fenced bool
+	lines := strings.SplitAfter(text, "\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		content := strings.TrimSuffix(line, "\n")
+		newline := line[len(content):]
+		trimmed := strings.TrimLeft(content, " \t")
+
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fenced = !fenced
+			sb.WriteString(style(content, ansiDim))
+		case fenced:
+			sb.WriteString(style(content, ansiCyan))
+		case isHeading(trimmed):
+			base := ansiBold
+			if strings.HasPrefix(trimmed, "# ") {
+				base += ansiUnderline
+			}
+			sb.WriteString(base + inline(content, base) + ansiReset)
+		case isRule(trimmed):
+			sb.WriteString(style(content, ansiDim))
+		case strings.HasPrefix(trimmed, ">"):
+			sb.WriteString(ansiItalic + inline(content, ansiItalic) + ansiReset)
+		default:
+			indent := content[:len(content)-len(trimmed)]
+			if marker := listMarker(trimmed); marker != "" {
+				sb.WriteString(indent + style(marker, ansiCyan) + inline(trimmed[len(marker):], ""))
+			} else {
+				sb.WriteString(inline(content, ""))
+			}
+		}
+		sb.WriteString(newline)
+	}
+
+	return sb.String()
+}
+
+// style wraps non-empty text in the provided escape sequence
+func style(text, sequence string) string {
+	if text == "" {
+		return text
+	}
+	return sequence + text + ansiReset
+}
+
+// isHeading determines whether the line is an ATX heading
+func isHeading(line string) bool {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 {
+		return false
+	}
+	return len(line) == level || line[level] == ' '
+}
+
+// isRule determines whether the line is a thematic break
+func isRule(line string) bool {
+	line = strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(line) < 3 {
+		return false
+	}
+
+	for _, c := range []string{"-", "*", "_"} {
+		if strings.Trim(line, c) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// listMarker gets the bullet or number, including the following space, that
+// begins a list item
+func listMarker(line string) string {
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, bullet) {
+			return bullet
+		}
+	}
+
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	if digits > 0 && digits < 10 && strings.HasPrefix(line[digits:], ". ") {
+		return line[:digits+2]
+	}
+	return ""
+}
+
+// inline styles code spans, strong emphasis, and emphasis within a line.
+// Because each span ends by resetting all attributes, base is reapplied
+// afterward
+func inline(text, base string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		var delimiter, sequence string
+		switch {
+		case text[i] == '`':
+			delimiter, sequence = "`", ansiCyan
+		case strings.HasPrefix(text[i:], "**") || strings.HasPrefix(text[i:], "__"):
+			delimiter, sequence = text[i:i+2], ansiBold
+		case text[i] == '*' || text[i] == '_':
+			delimiter, sequence = text[i:i+1], ansiItalic
+		}
+
+		end := -1
+		if delimiter != "" && opensSpan(text, i, delimiter) {
+			end = strings.Index(text[i+len(delimiter):], delimiter)
+		}
+		if end <= 0 {
+			sb.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		end += i + 2*len(delimiter)
+		sb.WriteString(sequence + text[i:end] + ansiReset + base)
+		i = end
+	}
+
+	return sb.String()
+}
+
+// opensSpan determines whether the delimiter at position i can begin a
+// span. Emphasis must be followed by a non-space character and underscores
+// can't appear within a word, such as in snake_case identifiers
+func opensSpan(text string, i int, delimiter string) bool {
+	if delimiter == "`" {
+		return true
+	}
+
+	next := i + len(delimiter)
+	if next >= len(text) || text[next] == ' ' {
+		return false
+	}
+
+	if delimiter[0] == '_' && i > 0 {
+		prev := rune(text[i-1])
+		return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+	}
+	return true
+}
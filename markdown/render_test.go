@@ -0,0 +1,38 @@
+package markdown
+
+import (
+	"regexp"
+	"testing"
+)
+
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9]+m")
+
+func TestRender(t *testing.T) {
+	tests := map[string]string{
+		"# Title\n":                   ansiBold + ansiUnderline + "# Title" + ansiReset + "\n",
+		"some `code` here":            "some " + ansiCyan + "`code`" + ansiReset + " here",
+		"**bold** and *it*\n":         ansiBold + "**bold**" + ansiReset + " and " + ansiItalic + "*it*" + ansiReset + "\n",
+		"snake_case_name":             "snake_case_name",
+		"2 * 3 * 4":                   "2 * 3 * 4",
+		"- item\n":                    ansiCyan + "- " + ansiReset + "item\n",
+		"```\nx := 1\n```\n":          ansiDim + "```" + ansiReset + "\n" + ansiCyan + "x := 1" + ansiReset + "\n" + ansiDim + "```" + ansiReset + "\n",
+		"## `code` heading":           ansiBold + "## " + ansiCyan + "`code`" + ansiReset + ansiBold + " heading" + ansiReset,
+		"---\n":                       ansiDim + "---" + ansiReset + "\n",
+		"#hashtag is not a heading\n": "#hashtag is not a heading\n",
+	}
+
+	for input, expected := range tests {
+		if got := Render(input); got != expected {
+			t.Errorf("Render(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestRenderPreservesText(t *testing.T) {
+	input := "# Notes\n\n> quoted _text_\n1. first **item**\n\n```go\nfmt.Println(\"*\")\n```\nend"
+
+	got := ansiPattern.ReplaceAllString(Render(input), "")
+	if got != input {
+		t.Errorf("rendered text without escapes = %q, expected %q", got, input)
+	}
+}
@@ -3,6 +3,7 @@ package notes
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -16,6 +17,31 @@ type Meta struct {
 	OldVersions []string `json:"oldVersions"`
 	LatestID    int      `json:"latestID"`
 	Size        int      `json:"size"` // meta file size in bytes
+
+	Encryption *Encryption     `json:"encryption,omitempty"` // nil if the notebook isn't encrypted
+	Forwards   map[int]Forward `json:"forwards,omitempty"`   // notes moved to other notebooks, by their old ID
+}
+
+// Forward records where a note moved out of a notebook can now be found
+type Forward struct {
+	Notebook string   `json:"notebook"`
+	ID       int      `json:"id"`
+	Moved    JSONTime `json:"moved"`
+}
+
+// Encryption describes how a notebook's notes are encrypted. Notes are
+// encrypted with a random data key, which is stored sealed with a key derived
+// from the notebook's passphrase so that changing the passphrase doesn't
+// require re-encrypting every note
+type Encryption struct {
+	Cipher string `json:"cipher"`
+	KDF    string `json:"kdf"`
+	Salt   []byte `json:"salt"`
+	N      int    `json:"n"`      // scrypt CPU/memory cost
+	R      int    `json:"r"`      // scrypt block size
+	P      int    `json:"p"`      // scrypt parallelization
+	Key    []byte `json:"key"`    // sealed data key
+	Titles bool   `json:"titles"` // whether note titles are encrypted
 }
 
 // UpdateVersion replaces the existing version with the provided new version
@@ -67,6 +93,62 @@ type NoteMeta struct {
 	Created JSONTime      `json:"created"`
 	Deleted JSONTime      `json:"deleted"`
 	History []EditHistory `json:"history"`
+	Tags    []string      `json:"tags,omitempty"` // sorted, without duplicates
+}
+
+// IsDeleted determines whether the note has been soft-deleted
+func (m NoteMeta) IsDeleted() bool {
+	return !m.Deleted.IsZero() && !m.Deleted.Equal(time.Unix(0, 0))
+}
+
+// AddTags adds the provided tags to the note meta's tag set, ignoring
+// tags that are already present
+func (m *NoteMeta) AddTags(tags ...string) *NoteMeta {
+	for _, tag := range tags {
+		i := sort.SearchStrings(m.Tags, tag)
+		if i < len(m.Tags) && m.Tags[i] == tag {
+			continue
+		}
+
+		m.Tags = append(m.Tags, "")
+		copy(m.Tags[i+1:], m.Tags[i:])
+		m.Tags[i] = tag
+	}
+
+	return m
+}
+
+// RemoveTags removes the provided tags from the note meta's tag set
+func (m *NoteMeta) RemoveTags(tags ...string) *NoteMeta {
+	for _, tag := range tags {
+		i := sort.SearchStrings(m.Tags, tag)
+		if i < len(m.Tags) && m.Tags[i] == tag {
+			m.Tags = append(m.Tags[:i], m.Tags[i+1:]...)
+		}
+	}
+
+	if len(m.Tags) == 0 {
+		m.Tags = nil
+	}
+	return m
+}
+
+// HasTag determines whether the provided tag is in the note meta's tag set
+func (m NoteMeta) HasTag(tag string) bool {
+	i := sort.SearchStrings(m.Tags, tag)
+	return i < len(m.Tags) && m.Tags[i] == tag
+}
+
+// TagCounts counts the number of notes in the index with each tag
+func TagCounts(index map[int]NoteMeta) map[string]int {
+	counts := make(map[string]int)
+	for _, meta := range index {
+		for _, tag := range meta.Tags {
+			counts[tag]++
+		}
+	}
+
+	return counts
 }
 
 // EditHistory holds meta information that changes over time
@@ -78,8 +160,9 @@ type EditHistory struct {
 // Note includes the content of the note as well as its meta information as backup in
 // case we need to recreate the meta file from scratch
 type Note struct {
-	Meta NoteMeta `json:"meta"`
-	Body string   `json:"body"`
+	Meta      NoteMeta   `json:"meta"`
+	Body      string     `json:"body"`
+	Revisions []Revision `json:"revisions,omitempty"` // newest first
 }
 
 // ApproxSize gets the approximate encoded size of the note object by encoding
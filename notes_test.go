@@ -2,6 +2,8 @@ package notes
 
 import (
 	"testing"
+
+	"github.com/go-test/deep"
 )
 
 func TestMetaApproxSize(t *testing.T) {
@@ -12,3 +14,38 @@ func TestNoteApproxSize(t *testing.T) {
 
 func TestNoteAppendEdit(t *testing.T) {
 }
+
+func TestNoteMetaTags(t *testing.T) {
+	var meta NoteMeta
+	meta.AddTags("work", "alpha", "work", "zeta")
+	if diff := deep.Equal(meta.Tags, []string{"alpha", "work", "zeta"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if !meta.HasTag("work") || meta.HasTag("beta") {
+		t.Errorf("unexpected HasTag result for tags %v", meta.Tags)
+	}
+
+	meta.RemoveTags("work", "beta")
+	if diff := deep.Equal(meta.Tags, []string{"alpha", "zeta"}); diff != nil {
+		t.Error(diff)
+	}
+
+	meta.RemoveTags("alpha", "zeta")
+	if meta.Tags != nil {
+		t.Errorf("expected nil tags, got %v", meta.Tags)
+	}
+}
+
+func TestTagCounts(t *testing.T) {
+	index := map[int]NoteMeta{
+		1: {ID: 1, Tags: []string{"a", "b"}},
+		2: {ID: 2, Tags: []string{"b"}},
+		3: {ID: 3},
+	}
+
+	expected := map[string]int{"a": 1, "b": 2}
+	if diff := deep.Equal(TagCounts(index), expected); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,45 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// BodyOptions specifies where a note body is read from when it isn't
+// provided by the user interactively. Sources are checked in field order
+type BodyOptions struct {
+	Body    string    `json:"body"`
+	HasBody bool      `json:"hasBody"` // use Body even if it's empty
+	File    string    `json:"file"`    // "-" reads from Reader
+	Reader  io.Reader `json:"-"`       // typically stdin
+}
+
+// ReadBody gets a note body from the first source provided in options. If no
+// source is provided, ok is false
+func ReadBody(options BodyOptions) (body string, ok bool, err error) {
+	if options.HasBody || options.Body != "" {
+		return options.Body, true, nil
+	}
+
+	if options.File != "" && options.File != "-" {
+		b, err := os.ReadFile(options.File)
+		if err != nil {
+			return "", false, fmt.Errorf("read body file: %v", err)
+		}
+		return string(b), true, nil
+	}
+
+	if options.Reader == nil {
+		if options.File == "-" {
+			return "", false, fmt.Errorf("read body: no reader provided")
+		}
+		return "", false, nil
+	}
+
+	b, err := io.ReadAll(options.Reader)
+	if err != nil {
+		return "", false, fmt.Errorf("read body: %v", err)
+	}
+	return string(b), true, nil
+}
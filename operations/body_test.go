@@ -0,0 +1,45 @@
+package operations
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	filename := path.Join(t.TempDir(), "body")
+	err := os.WriteFile(filename, []byte("from file"), 0644)
+	if err != nil {
+		t.Fatalf("write body file: %s", err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		options  BodyOptions
+		body     string
+		ok       bool
+		hasError bool
+	}{
+		{name: "no source"},
+		{name: "body", options: BodyOptions{Body: "from flag"}, body: "from flag", ok: true},
+		{name: "empty body", options: BodyOptions{HasBody: true}, ok: true},
+		{name: "body before file", options: BodyOptions{Body: "from flag", File: filename}, body: "from flag", ok: true},
+		{name: "file", options: BodyOptions{File: filename}, body: "from file", ok: true},
+		{name: "file before reader", options: BodyOptions{File: filename, Reader: strings.NewReader("from reader")}, body: "from file", ok: true},
+		{name: "missing file", options: BodyOptions{File: filename + ".missing"}, hasError: true},
+		{name: "reader", options: BodyOptions{Reader: strings.NewReader("from reader")}, body: "from reader", ok: true},
+		{name: "dash", options: BodyOptions{File: "-", Reader: strings.NewReader("from reader")}, body: "from reader", ok: true},
+		{name: "dash without reader", options: BodyOptions{File: "-"}, hasError: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			body, ok, err := ReadBody(test.options)
+			if (err != nil) != test.hasError {
+				t.Fatalf("read body returned error %v, expected error: %t", err, test.hasError)
+			}
+			if body != test.body || ok != test.ok {
+				t.Errorf("read body returned %q (ok: %t), expected %q (ok: %t)", body, ok, test.body, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/subtlepseudonym/notes/dal"
+
+	"go.uber.org/zap"
+)
+
+// newTestContext creates a context for a new local DAL in a temporary
+// directory, creating the provided notebooks alongside the default notebook
+func newTestContext(t *testing.T, notebooks ...string) *Context {
+	t.Helper()
+
+	data, err := dal.NewLocal(t.TempDir(), "test", zap.NewNop())
+	if err != nil {
+		t.Fatalf("new local dal: %s", err)
+	}
+	for _, notebook := range notebooks {
+		err = data.CreateNotebook(notebook)
+		if err != nil {
+			t.Fatalf("create notebook: %s", err)
+		}
+	}
+
+	meta, err := data.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	return &Context{Meta: meta, DAL: data, Logger: zap.NewNop()}
+}
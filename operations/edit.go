@@ -7,12 +7,19 @@ import (
 	"go.uber.org/zap"
 )
 
+// EditNoteOptions provides values by which to alter the Note modified by EditNote
 type EditNoteOptions struct {
-	Title     string `json:"title"`
-	Body      string `json:"body"`
-	NoHistory bool   `json:"noHistory"`
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Append    bool     `json:"append"` // append Body to the existing body rather than replacing it
+	Editor    Editor   `json:"-"`      // used in place of Body and Append, if set
+	Tags      []string `json:"tags"`   // added to the note's existing tags
+	NoHistory bool     `json:"noHistory"`
 }
 
+// EditNote modifies an existing note according to the provided options,
+// restoring it if it has been soft-deleted. The editor, if any, is given the
+// note with its title and tags already changed
 func EditNote(ctx *Context, options EditNoteOptions, noteID int) (*Context, error) {
 	note, err := ctx.DAL.GetNote(noteID)
 	if err != nil {
@@ -22,7 +29,7 @@ func EditNote(ctx *Context, options EditNoteOptions, noteID int) (*Context, erro
 	var changed bool
 
 	// restore soft-deleted notes
-	if !note.Meta.Deleted.Time.Equal(time.Unix(0, 0)) {
+	if note.Meta.IsDeleted() {
 		ctx.Logger.Debug(
 			"restored soft-deleted note",
 			zap.Int("noteID", note.Meta.ID),
@@ -39,8 +46,32 @@ func EditNote(ctx *Context, options EditNoteOptions, noteID int) (*Context, erro
 		changed = true
 	}
 
-	if options.Body != note.Body {
-		note.Body = options.Body
+	for _, tag := range options.Tags {
+		if !note.Meta.HasTag(tag) {
+			note.Meta.AddTags(tag)
+			changed = true
+		}
+	}
+
+	body := options.Body
+	if options.Editor != nil {
+		body, err = options.Editor(note)
+		if err != nil {
+			return ctx, fmt.Errorf("edit note: %w", err)
+		}
+	} else if options.Append {
+		body = appendBody(note.Body, options.Body)
+	}
+
+	if body != note.Body {
+		if options.NoHistory {
+			note, err = note.AmendBody(body)
+			if err != nil {
+				return ctx, fmt.Errorf("amend note body: %v", err)
+			}
+		} else {
+			note = note.UpdateBody(body, time.Now())
+		}
 		changed = true
 	}
 
@@ -63,3 +94,11 @@ func EditNote(ctx *Context, options EditNoteOptions, noteID int) (*Context, erro
 
 	return ctx, nil
 }
+
+// appendBody joins addition to the end of body, separated by a newline
+func appendBody(body, addition string) string {
+	if body == "" || addition == "" || body[len(body)-1] == '\n' {
+		return body + addition
+	}
+	return body + "\n" + addition
+}
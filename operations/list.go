@@ -0,0 +1,71 @@
+package operations
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+// ListNotesOptions filters and limits the notes listed by ListNotes
+type ListNotesOptions struct {
+	Deleted     bool     `json:"deleted"`     // include soft-deleted notes
+	Tags        []string `json:"tags"`        // only list notes with every tag
+	ExcludeTags []string `json:"excludeTags"` // don't list notes with any tag
+	Limit       int      `json:"limit"`       // only list the most recent notes. If zero, all notes are listed
+	Reverse     bool     `json:"reverse"`     // list the most recent notes first
+}
+
+// ListNotes gets the meta of the notes matching the provided options, in
+// order of ID
+func ListNotes(ctx *Context, options ListNotesOptions) (*Context, []notes.NoteMeta, error) {
+	index, err := ctx.DAL.GetAllNoteMetas()
+	if err != nil {
+		return ctx, nil, fmt.Errorf("get note metas: %v", err)
+	}
+
+	metas := make([]notes.NoteMeta, 0, len(index))
+	for _, meta := range index {
+		if !options.Deleted && meta.IsDeleted() {
+			continue
+		}
+		if !MatchesTags(meta, options.Tags, options.ExcludeTags) {
+			continue
+		}
+		metas = append(metas, meta)
+	}
+
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].ID < metas[j].ID
+	})
+
+	if options.Limit > 0 && len(metas) > options.Limit {
+		metas = metas[len(metas)-options.Limit:]
+	}
+
+	if options.Reverse {
+		for l, r := 0, len(metas)-1; l < r; l, r = l+1, r-1 {
+			metas[l], metas[r] = metas[r], metas[l]
+		}
+	}
+
+	return ctx, metas, nil
+}
+
+// MatchesTags determines whether the note meta has every tag in include and
+// none of the tags in exclude
+func MatchesTags(meta notes.NoteMeta, include, exclude []string) bool {
+	for _, tag := range include {
+		if !meta.HasTag(tag) {
+			return false
+		}
+	}
+
+	for _, tag := range exclude {
+		if meta.HasTag(tag) {
+			return false
+		}
+	}
+
+	return true
+}
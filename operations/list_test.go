@@ -0,0 +1,66 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+func TestListNotes(t *testing.T) {
+	ctx := newTestContext(t)
+
+	for _, tags := range [][]string{{"work"}, {"work", "draft"}, nil, {"work"}} {
+		_, err := NewNote(ctx, NewNoteOptions{Title: "note", Tags: tags})
+		if err != nil {
+			t.Fatalf("new note: %s", err)
+		}
+	}
+	_, err := RemoveNote(ctx, RemoveNoteOptions{}, 4)
+	if err != nil {
+		t.Fatalf("remove note: %s", err)
+	}
+
+	// a note whose deletion time was never set isn't deleted
+	note, err := ctx.DAL.GetNote(3)
+	if err != nil {
+		t.Fatalf("get note: %s", err)
+	}
+	note.Meta.Deleted = notes.JSONTime{}
+	err = ctx.DAL.SaveNote(note)
+	if err != nil {
+		t.Fatalf("save note: %s", err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		options  ListNotesOptions
+		expected []int
+	}{
+		{name: "all", expected: []int{1, 2, 3}},
+		{name: "deleted", options: ListNotesOptions{Deleted: true}, expected: []int{1, 2, 3, 4}},
+		{name: "tags", options: ListNotesOptions{Tags: []string{"work"}}, expected: []int{1, 2}},
+		{name: "exclude tags", options: ListNotesOptions{Tags: []string{"work"}, ExcludeTags: []string{"draft"}}, expected: []int{1}},
+		{name: "limit", options: ListNotesOptions{Limit: 2}, expected: []int{2, 3}},
+		{name: "reverse", options: ListNotesOptions{Limit: 2, Reverse: true}, expected: []int{3, 2}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, metas, err := ListNotes(ctx, test.options)
+			if err != nil {
+				t.Fatalf("list notes: %s", err)
+			}
+
+			var ids []int
+			for _, meta := range metas {
+				ids = append(ids, meta.ID)
+			}
+			if len(ids) != len(test.expected) {
+				t.Fatalf("listed notes %v, expected %v", ids, test.expected)
+			}
+			for i := range ids {
+				if ids[i] != test.expected[i] {
+					t.Fatalf("listed notes %v, expected %v", ids, test.expected)
+				}
+			}
+		})
+	}
+}
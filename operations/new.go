@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
 
 	"go.uber.org/zap"
 )
@@ -14,20 +15,32 @@ const (
 	defaultDateTitleLocation = "UTC"
 )
 
+// Editor gets a note body from the user, starting from the note as it is.
+// Callers may save the note while the user is editing it
+type Editor func(note *notes.Note) (string, error)
+
 // NewNoteOptions provides values by which to alter the Note created by NewNote
 type NewNoteOptions struct {
-	Title        string `json:"title"`
-	DateFormat   string `json:"dateFormat"`
-	DateLocation string `json:"dateLocation"`
+	Title        string   `json:"title"`
+	DateFormat   string   `json:"dateFormat"`
+	DateLocation string   `json:"dateLocation"`
+	Tags         []string `json:"tags"`
+	Body         string   `json:"body"`
+	Editor       Editor   `json:"-"` // used in place of Body, if set
+	NoHistory    bool     `json:"noHistory"`
 }
 
-// NewNote creates a new note object according to the provided options and populates
-// the body with the provided UpdateBodyFunc
+// NewNote creates a new note object according to the provided options. The
+// note's ID is reserved in the notebook's meta before the body is written or
+// the note is saved, so that the ID isn't reused while an editor is open or
+// if saving the note fails
 func NewNote(ctx *Context, options NewNoteOptions) (*Context, error) {
-	newNoteID := ctx.Meta.LatestID + 1
-	if _, err := ctx.DAL.GetNoteMeta(newNoteID); err == nil {
-		return ctx, fmt.Errorf("note ID %d (%x) already exists", newNoteID, newNoteID)
+	meta, err := dal.ReserveNoteID(ctx.DAL)
+	if err != nil {
+		return ctx, fmt.Errorf("reserve note ID: %w", err)
 	}
+	ctx.Meta = meta
+	ctx.Logger.Debug("reserved note ID", zap.Int("noteID", meta.LatestID))
 
 	title := options.Title
 	if title == "" {
@@ -36,14 +49,34 @@ func NewNote(ctx *Context, options NewNoteOptions) (*Context, error) {
 
 	note := &notes.Note{
 		Meta: notes.NoteMeta{
-			ID:      newNoteID,
+			ID:      meta.LatestID,
 			Title:   title,
-			Created: notes.JSONTime{time.Now()},
-			Deleted: notes.JSONTime{time.Unix(0, 0)},
+			Created: notes.JSONTime{Time: time.Now()},
+			Deleted: notes.JSONTime{Time: time.Unix(0, 0)},
 		},
 	}
+	note.Meta.AddTags(options.Tags...)
+
+	body := options.Body
+	if options.Editor != nil {
+		body, err = options.Editor(note)
+		if err != nil {
+			return ctx, fmt.Errorf("edit note: %w", err)
+		}
+	}
+
+	if body != "" {
+		note.UpdateBody(body, note.Meta.Created.Time)
+
+		if !options.NoHistory {
+			note, err = note.AppendEdit(time.Now())
+			if err != nil {
+				return ctx, fmt.Errorf("append edit to note history: %v", err)
+			}
+		}
+	}
 
-	err := ctx.DAL.SaveNote(note)
+	err = ctx.DAL.SaveNote(note)
 	if err != nil {
 		return ctx, fmt.Errorf("save note: %v", err)
 	}
@@ -53,19 +86,6 @@ func NewNote(ctx *Context, options NewNoteOptions) (*Context, error) {
 		zap.String("notebook", ctx.DAL.GetNotebook()),
 	)
 
-	ctx.Meta.LatestID = note.Meta.ID
-	metaSize, err := ctx.Meta.ApproxSize()
-	if err != nil {
-		ctx.Logger.Error("failed to approximate meta size", zap.Error(err))
-	} else {
-		ctx.Meta.Size = metaSize
-	}
-
-	err = ctx.DAL.SaveMeta(ctx.Meta)
-	if err != nil {
-		return ctx, fmt.Errorf("save meta: %v", err)
-	}
-
 	return ctx, nil
 }
 
@@ -0,0 +1,59 @@
+package operations
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/subtlepseudonym/notes"
+)
+
+func TestNewNoteReservesID(t *testing.T) {
+	ctx := newTestContext(t)
+
+	var reserved int
+	editor := func(note *notes.Note) (string, error) {
+		meta, err := ctx.DAL.GetMeta()
+		if err != nil {
+			return "", err
+		}
+		reserved = meta.LatestID
+		return "written in an editor", nil
+	}
+
+	_, err := NewNote(ctx, NewNoteOptions{Title: "note", Editor: editor})
+	if err != nil {
+		t.Fatalf("new note: %s", err)
+	}
+	if reserved != 1 {
+		t.Errorf("latest ID was %d while editing, expected 1", reserved)
+	}
+
+	note, err := ctx.DAL.GetNote(1)
+	if err != nil {
+		t.Fatalf("get note: %s", err)
+	}
+	if note.Body != "written in an editor" || len(note.Meta.History) != 1 {
+		t.Errorf("note has body %q and %d history entries, expected the editor's body and 1 entry", note.Body, len(note.Meta.History))
+	}
+
+	// an ID reserved for a note that wasn't saved isn't reused
+	failing := func(*notes.Note) (string, error) {
+		return "", errors.New("editor failed")
+	}
+	_, err = NewNote(ctx, NewNoteOptions{Editor: failing})
+	if err == nil {
+		t.Fatalf("new note with failing editor succeeded")
+	}
+
+	_, err = NewNote(ctx, NewNoteOptions{Title: "next"})
+	if err != nil {
+		t.Fatalf("new note: %s", err)
+	}
+	note, err = ctx.DAL.GetNote(3)
+	if err != nil {
+		t.Fatalf("get note: %s", err)
+	}
+	if note.Meta.Title != "next" {
+		t.Errorf("note 3 has title %q, expected %q", note.Meta.Title, "next")
+	}
+}
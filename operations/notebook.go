@@ -0,0 +1,68 @@
+package operations
+
+import (
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// NotebookOptions provides values by which to alter the notebook created or
+// renamed by CreateNotebook and RenameNotebook
+type NotebookOptions struct {
+	Use bool `json:"use"` // make the notebook current afterward
+}
+
+// ListNotebooks gets the names of every notebook, sorted
+func ListNotebooks(ctx *Context) (*Context, []string) {
+	notebooks := append([]string(nil), ctx.DAL.GetAllNotebooks()...)
+	sort.Strings(notebooks)
+	return ctx, notebooks
+}
+
+// CreateNotebook creates a new, empty notebook
+func CreateNotebook(ctx *Context, options NotebookOptions, name string) (*Context, error) {
+	err := ctx.DAL.CreateNotebook(name)
+	if err != nil {
+		return ctx, fmt.Errorf("create notebook: %v", err)
+	}
+	ctx.Logger.Debug("created notebook", zap.String("notebook", name))
+
+	if options.Use {
+		return UseNotebook(ctx, name)
+	}
+	return ctx, nil
+}
+
+// RenameNotebook renames a notebook. Renaming the current notebook always
+// makes the new name current
+func RenameNotebook(ctx *Context, options NotebookOptions, oldName, newName string) (*Context, error) {
+	current := ctx.DAL.GetNotebook()
+
+	err := ctx.DAL.RenameNotebook(oldName, newName)
+	if err != nil {
+		return ctx, fmt.Errorf("rename notebook: %v", err)
+	}
+	ctx.Logger.Debug("renamed notebook", zap.String("from", oldName), zap.String("to", newName))
+
+	if options.Use || current == oldName {
+		return UseNotebook(ctx, newName)
+	}
+	return ctx, nil
+}
+
+// UseNotebook makes the notebook current, loading its meta into the context
+func UseNotebook(ctx *Context, name string) (*Context, error) {
+	err := ctx.DAL.SetNotebook(name)
+	if err != nil {
+		return ctx, fmt.Errorf("set notebook: %v", err)
+	}
+
+	meta, err := ctx.DAL.GetMeta()
+	if err != nil {
+		return ctx, fmt.Errorf("get meta: %v", err)
+	}
+	ctx.Meta = meta
+
+	return ctx, nil
+}
@@ -0,0 +1,49 @@
+package operations
+
+import (
+	"testing"
+)
+
+func TestNotebooks(t *testing.T) {
+	ctx := newTestContext(t)
+
+	_, err := NewNote(ctx, NewNoteOptions{Title: "note"})
+	if err != nil {
+		t.Fatalf("new note: %s", err)
+	}
+
+	ctx, err = CreateNotebook(ctx, NotebookOptions{Use: true}, "work")
+	if err != nil {
+		t.Fatalf("create notebook: %s", err)
+	}
+	if ctx.DAL.GetNotebook() != "work" || ctx.Meta.LatestID != 0 {
+		t.Errorf("using notebook %q with latest ID %d, expected the new notebook's meta", ctx.DAL.GetNotebook(), ctx.Meta.LatestID)
+	}
+
+	_, err = CreateNotebook(ctx, NotebookOptions{}, "personal")
+	if err != nil {
+		t.Fatalf("create notebook: %s", err)
+	}
+	if ctx.DAL.GetNotebook() != "work" {
+		t.Errorf("creating a notebook without using it changed the current notebook to %q", ctx.DAL.GetNotebook())
+	}
+
+	ctx, err = UseNotebook(ctx, "default")
+	if err != nil {
+		t.Fatalf("use notebook: %s", err)
+	}
+	if ctx.Meta.LatestID != 1 {
+		t.Errorf("default notebook's latest ID is %d, expected 1", ctx.Meta.LatestID)
+	}
+
+	_, notebooks := ListNotebooks(ctx)
+	expected := []string{"default", "personal", "work"}
+	if len(notebooks) != len(expected) {
+		t.Fatalf("listed notebooks %v, expected %v", notebooks, expected)
+	}
+	for i := range notebooks {
+		if notebooks[i] != expected[i] {
+			t.Fatalf("listed notebooks %v, expected %v", notebooks, expected)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/subtlepseudonym/notes"
+
+	"go.uber.org/zap"
+)
+
+// ShowNoteOptions provides values by which to select the note body gotten by
+// ShowNote
+type ShowNoteOptions struct {
+	Revision       int  `json:"revision"`       // if zero, the current revision is shown
+	FollowForwards bool `json:"followForwards"` // show the note a moved note was forwarded to
+}
+
+// ShownNote is a note's body as of the revision shown
+type ShownNote struct {
+	Notebook string         `json:"notebook"` // the notebook the note was found in
+	Note     *notes.Note    `json:"note"`
+	Body     string         `json:"body"`
+	Revision notes.Revision `json:"revision"` // zero if the note has no revisions
+}
+
+// ShowNote gets a note's body as of the provided revision
+func ShowNote(ctx *Context, options ShowNoteOptions, noteID int) (*Context, ShownNote, error) {
+	notebook, note, err := getNote(ctx, noteID, options.FollowForwards)
+	if err != nil {
+		return ctx, ShownNote{}, err
+	}
+
+	shown := ShownNote{
+		Notebook: notebook,
+		Note:     note,
+		Body:     note.Body,
+	}
+
+	number := options.Revision
+	if number == 0 {
+		number = note.CurrentRevision()
+	} else {
+		shown.Body, err = note.BodyAt(number)
+		if err != nil {
+			return ctx, ShownNote{}, fmt.Errorf("get revision %d: %v", number, err)
+		}
+	}
+
+	for _, revision := range note.ListRevisions() {
+		if revision.Number == number {
+			shown.Revision = revision
+			break
+		}
+	}
+
+	return ctx, shown, nil
+}
+
+// NoteInfoOptions provides values by which to select the note described by
+// NoteInfo
+type NoteInfoOptions struct {
+	FollowForwards bool `json:"followForwards"` // describe the note a moved note was forwarded to
+}
+
+// NoteInfo describes a note
+type NoteInfo struct {
+	Notebook string         `json:"notebook"` // the notebook the note was found in
+	Meta     notes.NoteMeta `json:"meta"`
+}
+
+// GetNoteInfo gets the information describing a note
+func GetNoteInfo(ctx *Context, options NoteInfoOptions, noteID int) (*Context, NoteInfo, error) {
+	notebook, note, err := getNote(ctx, noteID, options.FollowForwards)
+	if err != nil {
+		return ctx, NoteInfo{}, err
+	}
+
+	return ctx, NoteInfo{Notebook: notebook, Meta: note.Meta}, nil
+}
+
+// getNote gets a note from the current notebook or, if follow is set and the
+// note was moved, from the notebook it was moved to. The current notebook is
+// left unchanged
+func getNote(ctx *Context, noteID int, follow bool) (string, *notes.Note, error) {
+	notebook := ctx.DAL.GetNotebook()
+	if _, moved := ctx.Meta.Forwards[noteID]; follow && moved {
+		var err error
+		notebook, noteID, err = ResolveForward(ctx, noteID)
+		if err != nil {
+			return "", nil, fmt.Errorf("resolve moved note: %v", err)
+		}
+		ctx.Logger.Debug("following moved note", zap.String("notebook", notebook), zap.Int("noteID", noteID))
+
+		current := ctx.DAL.GetNotebook()
+		defer ctx.DAL.SetNotebook(current)
+
+		err = ctx.DAL.SetNotebook(notebook)
+		if err != nil {
+			return "", nil, fmt.Errorf("set notebook: %v", err)
+		}
+	}
+
+	note, err := ctx.DAL.GetNote(noteID)
+	if err != nil {
+		return "", nil, fmt.Errorf("get note: %v", err)
+	}
+	return notebook, note, nil
+}
@@ -0,0 +1,79 @@
+package operations
+
+import (
+	"testing"
+)
+
+func TestShowNote(t *testing.T) {
+	ctx := newTestContext(t)
+
+	_, err := NewNote(ctx, NewNoteOptions{Title: "note", Body: "first\n"})
+	if err != nil {
+		t.Fatalf("new note: %s", err)
+	}
+	_, err = EditNote(ctx, EditNoteOptions{Body: "second\n"}, 1)
+	if err != nil {
+		t.Fatalf("edit note: %s", err)
+	}
+
+	for _, test := range []struct {
+		revision int
+		body     string
+		number   int
+	}{
+		{revision: 0, body: "second\n", number: 2},
+		{revision: 1, body: "first\n", number: 1},
+	} {
+		_, shown, err := ShowNote(ctx, ShowNoteOptions{Revision: test.revision}, 1)
+		if err != nil {
+			t.Fatalf("show revision %d: %s", test.revision, err)
+		}
+		if shown.Body != test.body || shown.Revision.Number != test.number {
+			t.Errorf("revision %d shows revision %d with body %q, expected revision %d with body %q", test.revision, shown.Revision.Number, shown.Body, test.number, test.body)
+		}
+	}
+
+	_, _, err = ShowNote(ctx, ShowNoteOptions{Revision: 3}, 1)
+	if err == nil {
+		t.Errorf("showing a revision which doesn't exist succeeded")
+	}
+}
+
+func TestShowNoteFollowsForwards(t *testing.T) {
+	ctx := newTestContext(t)
+	_, err := CreateNotebook(ctx, NotebookOptions{}, "archive")
+	if err != nil {
+		t.Fatalf("create notebook: %s", err)
+	}
+
+	_, err = NewNote(ctx, NewNoteOptions{Title: "moved", Body: "body"})
+	if err != nil {
+		t.Fatalf("new note: %s", err)
+	}
+	_, newID, err := TransferNote(ctx, TransferNoteOptions{Notebook: "archive", Move: true}, 1)
+	if err != nil {
+		t.Fatalf("move note: %s", err)
+	}
+
+	meta, err := ctx.DAL.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	ctx.Meta = meta
+
+	_, _, err = ShowNote(ctx, ShowNoteOptions{}, 1)
+	if err == nil {
+		t.Errorf("showing a moved note without following forwards succeeded")
+	}
+
+	_, info, err := GetNoteInfo(ctx, NoteInfoOptions{FollowForwards: true}, 1)
+	if err != nil {
+		t.Fatalf("get note info: %s", err)
+	}
+	if info.Notebook != "archive" || info.Meta.ID != newID {
+		t.Errorf("found note %d in %q, expected note %d in %q", info.Meta.ID, info.Notebook, newID, "archive")
+	}
+	if notebook := ctx.DAL.GetNotebook(); notebook != "default" {
+		t.Errorf("current notebook is %q after following a forward, expected %q", notebook, "default")
+	}
+}
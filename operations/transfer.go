@@ -0,0 +1,155 @@
+package operations
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+
+	"go.uber.org/zap"
+)
+
+// TransferNoteOptions provides values by which to alter the transfer made by
+// TransferNote
+type TransferNoteOptions struct {
+	Notebook string `json:"notebook"` // destination notebook
+	Move     bool   `json:"move"`     // remove the note from the current notebook
+}
+
+// TransferNote copies a note from the current notebook to another, where it's
+// given the next ID from that notebook's meta. Its creation time, edit
+// history, and revisions are kept. If options.Move is set, the note is then
+// removed from the current notebook and a forwarding record is left in its
+// place so that its old ID can be resolved.
+//
+// Changes are ordered so that an interruption leaves the note in both
+// notebooks rather than neither: the destination ID is reserved, the note is
+// saved to the destination, the forward is saved, and only then is the
+// original removed. The ID of the new note is returned
+func TransferNote(ctx *Context, options TransferNoteOptions, noteID int) (*Context, int, error) {
+	source := ctx.DAL.GetNotebook()
+	if options.Notebook == "" {
+		return ctx, 0, fmt.Errorf("destination notebook required")
+	} else if options.Notebook == source {
+		return ctx, 0, fmt.Errorf("note is already in notebook %q", source)
+	}
+
+	note, err := ctx.DAL.GetNote(noteID)
+	if err != nil {
+		return ctx, 0, fmt.Errorf("get note: %v", err)
+	}
+
+	newID, err := saveToNotebook(ctx, options.Notebook, note)
+	if err != nil {
+		return ctx, 0, err
+	}
+	ctx.Logger.Debug(
+		"copied note",
+		zap.Int("noteID", noteID),
+		zap.String("notebook", source),
+		zap.Int("newNoteID", newID),
+		zap.String("newNotebook", options.Notebook),
+	)
+
+	if !options.Move {
+		return ctx, newID, nil
+	}
+
+	// the forward is added to the meta as it is now, rather than as it was
+	// read, so that IDs reserved in the meantime aren't reused
+	meta, err := dal.UpdateMeta(ctx.DAL, func(meta *notes.Meta) {
+		if meta.Forwards == nil {
+			meta.Forwards = make(map[int]notes.Forward)
+		}
+		meta.Forwards[noteID] = notes.Forward{
+			Notebook: options.Notebook,
+			ID:       newID,
+			Moved:    notes.JSONTime{Time: time.Now()},
+		}
+	})
+	if err != nil {
+		return ctx, newID, fmt.Errorf("save forward: %w", err)
+	}
+	ctx.Meta = meta
+
+	err = ctx.DAL.RemoveNote(noteID)
+	if err != nil {
+		return ctx, newID, fmt.Errorf("remove note: %v", err)
+	}
+	ctx.Logger.Debug("removed moved note", zap.Int("noteID", noteID), zap.String("notebook", source))
+
+	return ctx, newID, nil
+}
+
+// saveToNotebook saves a copy of the note under the next ID in the provided
+// notebook, switching back to the current notebook before returning
+func saveToNotebook(ctx *Context, notebook string, note *notes.Note) (newID int, err error) {
+	source := ctx.DAL.GetNotebook()
+	err = ctx.DAL.SetNotebook(notebook)
+	if err != nil {
+		return 0, fmt.Errorf("set notebook: %v", err)
+	}
+	defer func() {
+		setErr := ctx.DAL.SetNotebook(source)
+		if setErr != nil && err == nil {
+			err = fmt.Errorf("restore notebook: %v", setErr)
+		}
+	}()
+
+	// reserve the ID before the note is saved so that it's never reused
+	meta, err := dal.ReserveNoteID(ctx.DAL)
+	if err != nil {
+		return 0, fmt.Errorf("reserve note ID in notebook %q: %w", notebook, err)
+	}
+	newID = meta.LatestID
+
+	transferred := *note
+	transferred.Meta.ID = newID
+	err = ctx.DAL.SaveNote(&transferred)
+	if err != nil {
+		return 0, fmt.Errorf("save note: %v", err)
+	}
+
+	return newID, nil
+}
+
+// noteLocation identifies a note across notebooks
+type noteLocation struct {
+	notebook string
+	id       int
+}
+
+// ResolveForward follows the forwarding records left by moved notes, starting
+// from the note ID in the current notebook, and returns the notebook and ID
+// at which the note can now be found. Notes which haven't been moved resolve
+// to themselves
+func ResolveForward(ctx *Context, noteID int) (string, int, error) {
+	source := ctx.DAL.GetNotebook()
+	defer ctx.DAL.SetNotebook(source)
+
+	location := noteLocation{notebook: source, id: noteID}
+	visited := make(map[noteLocation]bool)
+	meta := ctx.Meta
+	for {
+		forward, ok := meta.Forwards[location.id]
+		if !ok {
+			return location.notebook, location.id, nil
+		}
+
+		visited[location] = true
+		location = noteLocation{notebook: forward.Notebook, id: forward.ID}
+		if visited[location] {
+			return "", 0, fmt.Errorf("note %x in notebook %q is forwarded in a loop", noteID, source)
+		}
+
+		err := ctx.DAL.SetNotebook(location.notebook)
+		if err != nil {
+			return "", 0, fmt.Errorf("note was moved to notebook %q: set notebook: %v", location.notebook, err)
+		}
+		meta, err = ctx.DAL.GetMeta()
+		if err != nil {
+			return "", 0, fmt.Errorf("get meta: %v", err)
+		}
+	}
+}
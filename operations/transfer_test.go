@@ -0,0 +1,114 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/subtlepseudonym/notes/dal"
+)
+
+func TestTransferNote(t *testing.T) {
+	ctx := newTestContext(t, "work", "archive")
+
+	for i := 0; i < 2; i++ {
+		_, err := NewNote(ctx, NewNoteOptions{Title: "plans", Body: "first draft"})
+		if err != nil {
+			t.Fatalf("new note: %s", err)
+		}
+	}
+	original, err := ctx.DAL.GetNote(2)
+	if err != nil {
+		t.Fatalf("get note: %s", err)
+	}
+
+	_, copyID, err := TransferNote(ctx, TransferNoteOptions{Notebook: "work"}, 2)
+	if err != nil {
+		t.Fatalf("copy note: %s", err)
+	}
+	_, moveID, err := TransferNote(ctx, TransferNoteOptions{Notebook: "work", Move: true}, 2)
+	if err != nil {
+		t.Fatalf("move note: %s", err)
+	}
+	if copyID != 1 || moveID != 2 {
+		t.Errorf("expected IDs 1 and 2 in destination, got %d and %d", copyID, moveID)
+	}
+
+	if ctx.DAL.GetNotebook() != "default" {
+		t.Errorf("expected to remain in default notebook, got %q", ctx.DAL.GetNotebook())
+	}
+	if _, err := ctx.DAL.GetNote(2); err == nil {
+		t.Errorf("expected moved note to be removed from source")
+	}
+	if _, err := ctx.DAL.GetNote(1); err != nil {
+		t.Errorf("expected other note to remain in source: %s", err)
+	}
+
+	ctx.DAL.SetNotebook("work")
+	moved, err := ctx.DAL.GetNote(moveID)
+	if err != nil {
+		t.Fatalf("get moved note: %s", err)
+	}
+	if moved.Body != original.Body || !moved.Meta.Created.Equal(original.Meta.Created.Time) || len(moved.Meta.History) != len(original.Meta.History) {
+		t.Errorf("expected moved note to keep body, creation time, and history")
+	}
+	workMeta, err := ctx.DAL.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	if workMeta.LatestID != moveID {
+		t.Errorf("expected destination latest ID %d, got %d", moveID, workMeta.LatestID)
+	}
+
+	// move it on again, so the original ID is forwarded twice
+	workCtx := &Context{Meta: workMeta, DAL: ctx.DAL, Logger: ctx.Logger}
+	_, finalID, err := TransferNote(workCtx, TransferNoteOptions{Notebook: "archive", Move: true}, moveID)
+	if err != nil {
+		t.Fatalf("move note again: %s", err)
+	}
+
+	ctx.DAL.SetNotebook("default")
+	meta, err := ctx.DAL.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	notebook, id, err := ResolveForward(&Context{Meta: meta, DAL: ctx.DAL, Logger: ctx.Logger}, 2)
+	if err != nil {
+		t.Fatalf("resolve forward: %s", err)
+	}
+	if notebook != "archive" || id != finalID {
+		t.Errorf("expected note to resolve to archive/%d, got %s/%d", finalID, notebook, id)
+	}
+	if ctx.DAL.GetNotebook() != "default" {
+		t.Errorf("expected resolving to restore the notebook, got %q", ctx.DAL.GetNotebook())
+	}
+}
+
+func TestTransferNoteKeepsReservedIDs(t *testing.T) {
+	ctx := newTestContext(t, "work")
+
+	_, err := NewNote(ctx, NewNoteOptions{Title: "plans"})
+	if err != nil {
+		t.Fatalf("new note: %s", err)
+	}
+
+	// another process reserves an ID after ctx.Meta was read
+	_, err = dal.ReserveNoteID(ctx.DAL)
+	if err != nil {
+		t.Fatalf("reserve note ID: %s", err)
+	}
+
+	_, _, err = TransferNote(ctx, TransferNoteOptions{Notebook: "work", Move: true}, 1)
+	if err != nil {
+		t.Fatalf("move note: %s", err)
+	}
+
+	meta, err := ctx.DAL.GetMeta()
+	if err != nil {
+		t.Fatalf("get meta: %s", err)
+	}
+	if meta.LatestID != 2 {
+		t.Errorf("latest ID = %d after moving a note, expected 2", meta.LatestID)
+	}
+	if _, ok := meta.Forwards[1]; !ok {
+		t.Errorf("moved note has no forward")
+	}
+}
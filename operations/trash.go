@@ -0,0 +1,87 @@
+package operations
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/subtlepseudonym/notes"
+
+	"go.uber.org/zap"
+)
+
+// PurgeNotesOptions provides values by which to select the notes removed by
+// PurgeNotes
+type PurgeNotesOptions struct {
+	DeletedBefore time.Time `json:"deletedBefore"` // if zero, every soft-deleted note is removed
+	DryRun        bool      `json:"dryRun"`        // select notes without removing them
+}
+
+// TrashedNotes lists the soft-deleted notes in the current notebook, ordered
+// by ID
+func TrashedNotes(ctx *Context) ([]notes.NoteMeta, error) {
+	index, err := ctx.DAL.GetAllNoteMetas()
+	if err != nil {
+		return nil, fmt.Errorf("get note metas: %v", err)
+	}
+
+	var trashed []notes.NoteMeta
+	for _, meta := range index {
+		if meta.IsDeleted() {
+			trashed = append(trashed, meta)
+		}
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].ID < trashed[j].ID
+	})
+
+	return trashed, nil
+}
+
+// RestoreNote undoes the soft deletion of a note
+func RestoreNote(ctx *Context, noteID int) (*Context, error) {
+	note, err := ctx.DAL.GetNote(noteID)
+	if err != nil {
+		return ctx, fmt.Errorf("get note: %v", err)
+	}
+	if !note.Meta.IsDeleted() {
+		return ctx, fmt.Errorf("note %x is not deleted", noteID)
+	}
+
+	note.Meta.Deleted.Time = time.Unix(0, 0)
+	err = ctx.DAL.SaveNote(note)
+	if err != nil {
+		return ctx, fmt.Errorf("save note: %v", err)
+	}
+
+	ctx.Logger.Debug("restored soft-deleted note", zap.Int("noteID", noteID))
+	return ctx, nil
+}
+
+// PurgeNotes hard deletes the soft-deleted notes in the current notebook
+// which were deleted before options.DeletedBefore. The selected notes are
+// returned, ordered by ID, whether or not they were removed
+func PurgeNotes(ctx *Context, options PurgeNotesOptions) (*Context, []notes.NoteMeta, error) {
+	trashed, err := TrashedNotes(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	var purged []notes.NoteMeta
+	for _, meta := range trashed {
+		if !options.DeletedBefore.IsZero() && !meta.Deleted.Before(options.DeletedBefore) {
+			continue
+		}
+
+		if !options.DryRun {
+			err = ctx.DAL.RemoveNote(meta.ID)
+			if err != nil {
+				return ctx, purged, fmt.Errorf("delete note %x: %v", meta.ID, err)
+			}
+			ctx.Logger.Debug("deleted note", zap.Int("noteID", meta.ID))
+		}
+		purged = append(purged, meta)
+	}
+
+	return ctx, purged, nil
+}
@@ -0,0 +1,63 @@
+package operations
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeNotes(t *testing.T) {
+	ctx := newTestContext(t)
+
+	for i := 0; i < 3; i++ {
+		_, err := NewNote(ctx, NewNoteOptions{Title: "note"})
+		if err != nil {
+			t.Fatalf("new note: %s", err)
+		}
+	}
+
+	// note 1 was deleted long ago, note 2 recently, and note 3 not at all
+	for id, deleted := range map[int]time.Time{1: time.Now().Add(-48 * time.Hour), 2: time.Now()} {
+		note, err := ctx.DAL.GetNote(id)
+		if err != nil {
+			t.Fatalf("get note: %s", err)
+		}
+		note.Meta.Deleted.Time = deleted
+		err = ctx.DAL.SaveNote(note)
+		if err != nil {
+			t.Fatalf("save note: %s", err)
+		}
+	}
+
+	options := PurgeNotesOptions{DeletedBefore: time.Now().Add(-24 * time.Hour), DryRun: true}
+	_, purged, err := PurgeNotes(ctx, options)
+	if err != nil {
+		t.Fatalf("dry run purge: %s", err)
+	}
+	if len(purged) != 1 || purged[0].ID != 1 {
+		t.Fatalf("expected only note 1 to be selected, got %v", purged)
+	}
+	if _, err := ctx.DAL.GetNote(1); err != nil {
+		t.Errorf("expected dry run to keep note 1: %s", err)
+	}
+
+	options.DryRun = false
+	_, _, err = PurgeNotes(ctx, options)
+	if err != nil {
+		t.Fatalf("purge: %s", err)
+	}
+	if _, err := ctx.DAL.GetNote(1); err == nil {
+		t.Errorf("expected note 1 to be removed")
+	}
+
+	_, err = RestoreNote(ctx, 2)
+	if err != nil {
+		t.Fatalf("restore note: %s", err)
+	}
+	trashed, err := TrashedNotes(ctx)
+	if err != nil {
+		t.Fatalf("trashed notes: %s", err)
+	}
+	if len(trashed) != 0 {
+		t.Errorf("expected empty trash, got %v", trashed)
+	}
+}
@@ -0,0 +1,185 @@
+package notes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/subtlepseudonym/notes/diff"
+)
+
+// Revision holds a saved version of a note's body. Only the most recent
+// revision's body is stored in full, as Note.Body. Each older revision stores
+// a delta which, when applied to the body of the revision that follows it,
+// reproduces its own body
+type Revision struct {
+	Number  int       `json:"number"`
+	Updated JSONTime  `json:"updated"`
+	Size    int       `json:"size"` // body size in bytes
+	Delta   []DeltaOp `json:"delta,omitempty"`
+}
+
+// DeltaOp either copies a range of lines from the source body or inserts
+// new lines
+type DeltaOp struct {
+	Start int      `json:"s,omitempty"` // first source line to copy
+	Count int      `json:"n,omitempty"` // number of source lines to copy
+	Lines []string `json:"l,omitempty"` // lines to insert
+}
+
+// NewDelta computes the delta that transforms from into to
+func NewDelta(from, to string) []DeltaOp {
+	var delta []DeltaOp
+	var source int
+	for _, edit := range diff.Lines(diff.SplitLines(from), diff.SplitLines(to)) {
+		last := len(delta) - 1
+		switch edit.Op {
+		case diff.Equal:
+			if last >= 0 && delta[last].Lines == nil && delta[last].Start+delta[last].Count == source {
+				delta[last].Count++
+			} else {
+				delta = append(delta, DeltaOp{Start: source, Count: 1})
+			}
+			source++
+		case diff.Delete:
+			source++
+		case diff.Insert:
+			if last >= 0 && delta[last].Lines != nil {
+				delta[last].Lines = append(delta[last].Lines, edit.Line)
+			} else {
+				delta = append(delta, DeltaOp{Lines: []string{edit.Line}})
+			}
+		}
+	}
+
+	return delta
+}
+
+// ApplyDelta applies the delta to the provided source text
+func ApplyDelta(source string, delta []DeltaOp) (string, error) {
+	lines := diff.SplitLines(source)
+
+	var sb strings.Builder
+	for _, op := range delta {
+		if op.Lines != nil {
+			for _, line := range op.Lines {
+				sb.WriteString(line)
+			}
+			continue
+		}
+
+		if op.Start < 0 || op.Count < 0 || op.Start+op.Count > len(lines) {
+			return "", fmt.Errorf("delta copies lines [%d,%d) from %d line source", op.Start, op.Start+op.Count, len(lines))
+		}
+		for _, line := range lines[op.Start : op.Start+op.Count] {
+			sb.WriteString(line)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// seedRevisions records the note's existing body as its first revision if
+// the note predates revision tracking
+func (n *Note) seedRevisions() {
+	if len(n.Revisions) > 0 || n.Body == "" {
+		return
+	}
+
+	updated := n.Meta.Created
+	if len(n.Meta.History) > 0 {
+		updated = n.Meta.History[0].Updated
+	}
+
+	n.Revisions = []Revision{
+		{
+			Number:  1,
+			Updated: updated,
+			Size:    len(n.Body),
+		},
+	}
+}
+
+// ListRevisions gets the note's revisions, newest first, including the
+// implicit first revision of notes that predate revision tracking
+func (n Note) ListRevisions() []Revision {
+	n.seedRevisions()
+	return n.Revisions
+}
+
+// CurrentRevision gets the number of the note's most recent revision
+func (n Note) CurrentRevision() int {
+	revisions := n.ListRevisions()
+	if len(revisions) == 0 {
+		return 0
+	}
+	return revisions[0].Number
+}
+
+// UpdateBody replaces the note's body, retaining the previous body as an
+// older revision
+func (n *Note) UpdateBody(body string, timestamp time.Time) *Note {
+	n.seedRevisions()
+	if len(n.Revisions) > 0 && body == n.Body {
+		return n
+	}
+
+	number := 1
+	if len(n.Revisions) > 0 {
+		n.Revisions[0].Delta = NewDelta(body, n.Body)
+		number = n.Revisions[0].Number + 1
+	}
+
+	revision := Revision{
+		Number:  number,
+		Updated: JSONTime{Time: timestamp},
+		Size:    len(body),
+	}
+	n.Revisions = append([]Revision{revision}, n.Revisions...)
+	n.Body = body
+
+	return n
+}
+
+// AmendBody replaces the note's body without creating a new revision
+func (n *Note) AmendBody(body string) (*Note, error) {
+	n.seedRevisions()
+	if len(n.Revisions) == 0 {
+		return n.UpdateBody(body, time.Now()), nil
+	}
+
+	if len(n.Revisions) > 1 {
+		previous, err := ApplyDelta(n.Body, n.Revisions[1].Delta)
+		if err != nil {
+			return n, fmt.Errorf("apply revision %d delta: %w", n.Revisions[1].Number, err)
+		}
+		n.Revisions[1].Delta = NewDelta(body, previous)
+	}
+
+	n.Revisions[0].Size = len(body)
+	n.Body = body
+
+	return n, nil
+}
+
+// BodyAt reconstructs the note's body as of the provided revision number
+func (n Note) BodyAt(number int) (string, error) {
+	n.seedRevisions()
+
+	body := n.Body
+	for i, revision := range n.Revisions {
+		if revision.Number == number {
+			return body, nil
+		}
+
+		if i+1 < len(n.Revisions) {
+			var err error
+			body, err = ApplyDelta(body, n.Revisions[i+1].Delta)
+			if err != nil {
+				return "", fmt.Errorf("apply revision %d delta: %w", n.Revisions[i+1].Number, err)
+			}
+		}
+	}
+
+	return "", fmt.Errorf("revision %d not found", number)
+}
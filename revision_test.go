@@ -0,0 +1,92 @@
+package notes
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNoteRevisions(t *testing.T) {
+	bodies := []string{
+		"first line\n",
+		"first line\nsecond line\n",
+		"zeroth line\nfirst line\nsecond line",
+		"",
+		"entirely new\n",
+	}
+
+	note := &Note{}
+	for _, body := range bodies {
+		note.UpdateBody(body, time.Now())
+	}
+
+	if note.CurrentRevision() != len(bodies) {
+		t.Errorf("current revision = %d, expected %d", note.CurrentRevision(), len(bodies))
+	}
+
+	for i, expected := range bodies {
+		body, err := note.BodyAt(i + 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != expected {
+			t.Errorf("revision %d = %q, expected %q", i+1, body, expected)
+		}
+	}
+
+	if _, err := note.BodyAt(len(bodies) + 1); err == nil {
+		t.Error("expected error for missing revision")
+	}
+}
+
+func TestNoteAmendBody(t *testing.T) {
+	note := &Note{}
+	note.UpdateBody("one\n", time.Now())
+	note.UpdateBody("one\ntwo\n", time.Now())
+
+	_, err := note.AmendBody("one\ntwo\nthree\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(note.Revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(note.Revisions))
+	}
+
+	body, err := note.BodyAt(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "one\n" {
+		t.Errorf("revision 1 = %q", body)
+	}
+}
+
+func TestNoteRevisionsSeeded(t *testing.T) {
+	note := &Note{Body: "written before revisions\n"}
+	note.UpdateBody("written after revisions\n", time.Now())
+
+	body, err := note.BodyAt(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "written before revisions\n" {
+		t.Errorf("revision 1 = %q", body)
+	}
+}
+
+func TestDeltaLarge(t *testing.T) {
+	var from, to string
+	for i := 0; i < 5000; i++ {
+		from += fmt.Sprintf("line %d\n", i)
+		to += fmt.Sprintf("line %d\n", 5000-i)
+	}
+
+	got, err := ApplyDelta(from, NewDelta(from, to))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != to {
+		t.Error("delta did not reproduce target text")
+	}
+}
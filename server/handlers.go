@@ -0,0 +1,494 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+	"github.com/subtlepseudonym/notes/operations"
+)
+
+// VersionResponse is the body of a version request
+type VersionResponse struct {
+	API     string `json:"api"`
+	Version string `json:"version"`
+}
+
+// NotebookRequest is the body of a request creating or renaming a notebook
+type NotebookRequest struct {
+	Name string `json:"name"`
+}
+
+// EditNoteRequest is the body of a request editing a note. Unlike
+// operations.EditNoteOptions, the body is left unchanged if it's omitted
+type EditNoteRequest struct {
+	Title     string   `json:"title"`
+	Body      *string  `json:"body"`
+	Append    bool     `json:"append"`
+	Tags      []string `json:"tags"`
+	NoHistory bool     `json:"noHistory"`
+}
+
+func (s *Server) getVersion(w http.ResponseWriter, r *http.Request, segments []string) error {
+	writeJSON(w, http.StatusOK, VersionResponse{
+		API:     APIVersion,
+		Version: s.version,
+	})
+	return nil
+}
+
+func (s *Server) listNotebooks(w http.ResponseWriter, r *http.Request, segments []string) error {
+	s.mu.Lock()
+	_, notebooks := operations.ListNotebooks(&operations.Context{DAL: s.data, Logger: s.logger})
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, notebooks)
+	return nil
+}
+
+func (s *Server) createNotebook(w http.ResponseWriter, r *http.Request, segments []string) error {
+	var req NotebookRequest
+	err := readJSON(r, &req)
+	if err != nil {
+		return err
+	}
+
+	err = dal.ValidateNotebookName(req.Name)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "invalid notebook name: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.notebookExists(req.Name) {
+		return errorf(http.StatusConflict, "notebook %q already exists", req.Name)
+	}
+
+	err = s.data.CreateNotebook(req.Name)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "create notebook: %v", err)
+	}
+
+	w.Header().Set("Location", notebookPath(req.Name))
+	writeJSON(w, http.StatusCreated, req)
+	return nil
+}
+
+func (s *Server) renameNotebook(w http.ResponseWriter, r *http.Request, segments []string) error {
+	var req NotebookRequest
+	err := readJSON(r, &req)
+	if err != nil {
+		return err
+	}
+
+	err = dal.ValidateNotebookName(req.Name)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "invalid notebook name: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.notebookExists(segments[1]) {
+		return errorf(http.StatusNotFound, "notebook %q not found", segments[1])
+	}
+
+	err = s.data.RenameNotebook(segments[1], req.Name)
+	if err != nil {
+		return fmt.Errorf("rename notebook: %w", err)
+	}
+
+	w.Header().Set("Location", notebookPath(req.Name))
+	writeJSON(w, http.StatusOK, req)
+	return nil
+}
+
+func (s *Server) removeNotebook(w http.ResponseWriter, r *http.Request, segments []string) error {
+	recursive, err := queryBool(r, "recursive")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notebook := segments[1]
+	if !s.notebookExists(notebook) {
+		return errorf(http.StatusNotFound, "notebook %q not found", notebook)
+	}
+	if notebook == s.data.GetNotebook() {
+		return errorf(http.StatusConflict, "notebook %q is the current notebook", notebook)
+	}
+
+	err = s.data.RemoveNotebook(notebook, recursive)
+	if err != nil {
+		return errorf(http.StatusConflict, "remove notebook: %v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) getMeta(w http.ResponseWriter, r *http.Request, segments []string) error {
+	return s.withNotebook(segments[1], func() error {
+		meta, err := s.data.GetMeta()
+		if err != nil {
+			return fmt.Errorf("get meta: %w", err)
+		}
+
+		writeJSON(w, http.StatusOK, meta)
+		return nil
+	})
+}
+
+func (s *Server) putMeta(w http.ResponseWriter, r *http.Request, segments []string) error {
+	var meta notes.Meta
+	err := readJSON(r, &meta)
+	if err != nil {
+		return err
+	}
+
+	return s.withNotebook(segments[1], func() error {
+		err := s.data.SaveMeta(&meta)
+		if err != nil {
+			return fmt.Errorf("save meta: %w", err)
+		}
+
+		writeJSON(w, http.StatusOK, meta)
+		return nil
+	})
+}
+
+func (s *Server) listNotes(w http.ResponseWriter, r *http.Request, segments []string) error {
+	includeDeleted, err := queryBool(r, "deleted")
+	if err != nil {
+		return err
+	}
+	tags := r.URL.Query()["tag"]
+
+	return s.withNotebook(segments[1], func() error {
+		ctx, err := s.operationsContext()
+		if err != nil {
+			return err
+		}
+
+		options := operations.ListNotesOptions{
+			Deleted: includeDeleted,
+			Tags:    tags,
+		}
+
+		_, metas, err := operations.ListNotes(ctx, options)
+		if err != nil {
+			return fmt.Errorf("list notes: %w", err)
+		}
+
+		writeJSON(w, http.StatusOK, metas)
+		return nil
+	})
+}
+
+func (s *Server) createNote(w http.ResponseWriter, r *http.Request, segments []string) error {
+	var options operations.NewNoteOptions
+	err := readJSON(r, &options)
+	if err != nil {
+		return err
+	}
+
+	return s.withNotebook(segments[1], func() error {
+		ctx, err := s.operationsContext()
+		if err != nil {
+			return err
+		}
+
+		ctx, err = operations.NewNote(ctx, options)
+		if err != nil {
+			return fmt.Errorf("new note: %w", err)
+		}
+
+		note, err := s.data.GetNote(ctx.Meta.LatestID)
+		if err != nil {
+			return fmt.Errorf("get note: %w", err)
+		}
+
+		w.Header().Set("Location", notePath(segments[1], note.Meta.ID))
+		return writeNote(w, http.StatusCreated, note)
+	})
+}
+
+func (s *Server) getNote(w http.ResponseWriter, r *http.Request, segments []string) error {
+	return s.withNote(segments, func(note *notes.Note, etag string) error {
+		if r.Header.Get("If-None-Match") == etag {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+
+		return writeNote(w, http.StatusOK, note)
+	})
+}
+
+func (s *Server) putNote(w http.ResponseWriter, r *http.Request, segments []string) error {
+	id, err := parseNoteID(segments[3])
+	if err != nil {
+		return err
+	}
+
+	var note notes.Note
+	err = readJSON(r, &note)
+	if err != nil {
+		return err
+	}
+	if note.Meta.ID != id {
+		return errorf(http.StatusBadRequest, "note ID %x doesn't match path", note.Meta.ID)
+	}
+
+	return s.withNotebook(segments[1], func() error {
+		status := http.StatusCreated
+		if _, err := s.data.GetNoteMeta(id); err == nil {
+			status = http.StatusOK
+
+			existing, err := s.data.GetNote(id)
+			if err != nil {
+				return fmt.Errorf("get note: %w", err)
+			}
+
+			etag, err := noteETag(existing)
+			if err != nil {
+				return err
+			}
+
+			err = checkPrecondition(r, etag)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := s.data.SaveNote(&note)
+		if err != nil {
+			return fmt.Errorf("save note: %w", err)
+		}
+
+		return writeNote(w, status, &note)
+	})
+}
+
+func (s *Server) getNoteMeta(w http.ResponseWriter, r *http.Request, segments []string) error {
+	return s.withNote(segments, func(note *notes.Note, etag string) error {
+		w.Header().Set("ETag", etag)
+		writeJSON(w, http.StatusOK, note.Meta)
+		return nil
+	})
+}
+
+func (s *Server) editNote(w http.ResponseWriter, r *http.Request, segments []string) error {
+	var req EditNoteRequest
+	err := readJSON(r, &req)
+	if err != nil {
+		return err
+	}
+
+	return s.withNote(segments, func(note *notes.Note, etag string) error {
+		err := checkPrecondition(r, etag)
+		if err != nil {
+			return err
+		}
+
+		options := operations.EditNoteOptions{
+			Title:     req.Title,
+			Body:      note.Body,
+			Append:    req.Append,
+			Tags:      req.Tags,
+			NoHistory: req.NoHistory,
+		}
+		if req.Body != nil {
+			options.Body = *req.Body
+		} else if req.Append {
+			options.Body = ""
+		}
+
+		ctx, err := s.operationsContext()
+		if err != nil {
+			return err
+		}
+
+		_, err = operations.EditNote(ctx, options, note.Meta.ID)
+		if err != nil {
+			return fmt.Errorf("edit note: %w", err)
+		}
+
+		note, err = s.data.GetNote(note.Meta.ID)
+		if err != nil {
+			return fmt.Errorf("get note: %w", err)
+		}
+		return writeNote(w, http.StatusOK, note)
+	})
+}
+
+func (s *Server) removeNote(w http.ResponseWriter, r *http.Request, segments []string) error {
+	hard, err := queryBool(r, "hard")
+	if err != nil {
+		return err
+	}
+
+	return s.withNote(segments, func(note *notes.Note, etag string) error {
+		err := checkPrecondition(r, etag)
+		if err != nil {
+			return err
+		}
+
+		ctx, err := s.operationsContext()
+		if err != nil {
+			return err
+		}
+
+		_, err = operations.RemoveNote(ctx, operations.RemoveNoteOptions{HardDelete: hard}, note.Meta.ID)
+		if err != nil {
+			return fmt.Errorf("remove note: %w", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// withNotebook calls fn with the DAL switched to the provided notebook,
+// switching back afterward
+func (s *Server) withNotebook(notebook string, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.notebookExists(notebook) {
+		return errorf(http.StatusNotFound, "notebook %q not found", notebook)
+	}
+
+	previous := s.data.GetNotebook()
+	err := s.data.SetNotebook(notebook)
+	if err != nil {
+		return fmt.Errorf("set notebook: %w", err)
+	}
+	defer s.data.SetNotebook(previous)
+
+	return fn()
+}
+
+// withNote calls fn with the note identified by the path segments and its
+// current ETag
+func (s *Server) withNote(segments []string, fn func(*notes.Note, string) error) error {
+	id, err := parseNoteID(segments[3])
+	if err != nil {
+		return err
+	}
+
+	return s.withNotebook(segments[1], func() error {
+		if _, err := s.data.GetNoteMeta(id); err != nil {
+			return errorf(http.StatusNotFound, "note %x not found", id)
+		}
+
+		note, err := s.data.GetNote(id)
+		if err != nil {
+			return fmt.Errorf("get note: %w", err)
+		}
+
+		etag, err := noteETag(note)
+		if err != nil {
+			return err
+		}
+		return fn(note, etag)
+	})
+}
+
+// notebookExists determines whether the notebook exists. The caller must hold
+// the server's lock
+func (s *Server) notebookExists(notebook string) bool {
+	for _, nb := range s.data.GetAllNotebooks() {
+		if nb == notebook {
+			return true
+		}
+	}
+	return false
+}
+
+// operationsContext creates the context for calling operations on the
+// current notebook
+func (s *Server) operationsContext() (*operations.Context, error) {
+	meta, err := s.data.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("get meta: %w", err)
+	}
+
+	return &operations.Context{
+		Meta:   meta,
+		DAL:    s.data,
+		Logger: s.logger,
+	}, nil
+}
+
+// checkPrecondition enforces the request's If-Match header, if any
+func checkPrecondition(r *http.Request, etag string) error {
+	match := r.Header.Get("If-Match")
+	if match == "" || match == "*" || match == etag {
+		return nil
+	}
+	return errorf(http.StatusPreconditionFailed, "note has changed, current ETag is %s", etag)
+}
+
+// noteETag derives an entity tag from the note's encoded form, which changes
+// whenever its meta or body do
+func noteETag(note *notes.Note) (string, error) {
+	b, err := json.Marshal(note)
+	if err != nil {
+		return "", fmt.Errorf("encode note: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf(`"%x"`, sum[:16]), nil
+}
+
+// writeNote encodes the note as the response body, along with its ETag
+func writeNote(w http.ResponseWriter, status int, note *notes.Note) error {
+	etag, err := noteETag(note)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", etag)
+	writeJSON(w, status, note)
+	return nil
+}
+
+// parseNoteID parses a hexadecimal note ID path segment
+func parseNoteID(segment string) (int, error) {
+	id, err := strconv.ParseInt(segment, 16, 64)
+	if err != nil {
+		return 0, errorf(http.StatusBadRequest, "parse note ID: %v", err)
+	}
+	return int(id), nil
+}
+
+// queryBool parses an optional boolean query parameter
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errorf(http.StatusBadRequest, "parse %s parameter: %v", name, err)
+	}
+	return b, nil
+}
+
+func notebookPath(notebook string) string {
+	return fmt.Sprintf("/%s/notebooks/%s", APIVersion, url.PathEscape(notebook))
+}
+
+func notePath(notebook string, id int) string {
+	return fmt.Sprintf("%s/notes/%x", notebookPath(notebook), id)
+}
@@ -0,0 +1,266 @@
+// Package server exposes a DAL and the note operations as a versioned REST
+// API with JSON bodies. Note IDs in paths are hexadecimal, as they are on the
+// command line
+//
+//	GET    /v1/version
+//	GET    /v1/notebooks
+//	POST   /v1/notebooks                          {"name": ...}
+//	PATCH  /v1/notebooks/{notebook}               {"name": ...}
+//	DELETE /v1/notebooks/{notebook}[?recursive=true]
+//	GET    /v1/notebooks/{notebook}/meta
+//	PUT    /v1/notebooks/{notebook}/meta          notes.Meta
+//	GET    /v1/notebooks/{notebook}/notes[?deleted=true][&tag=...]
+//	POST   /v1/notebooks/{notebook}/notes         operations.NewNoteOptions
+//	GET    /v1/notebooks/{notebook}/notes/{id}
+//	PUT    /v1/notebooks/{notebook}/notes/{id}    notes.Note
+//	PATCH  /v1/notebooks/{notebook}/notes/{id}    EditNoteRequest
+//	DELETE /v1/notebooks/{notebook}/notes/{id}[?hard=true]
+//	GET    /v1/notebooks/{notebook}/notes/{id}/meta
+//
+// Note responses carry an ETag. Requests that change a note may send it in
+// an If-Match header so that they fail with 412 Precondition Failed if the
+// note has changed since it was read. The PUT endpoints store their bodies as
+// is, bypassing the operations, so that the API can back a DAL
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/subtlepseudonym/notes/dal"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// APIVersion prefixes every API path. Breaking changes to the API are
+	// made under a new version
+	APIVersion = "v1"
+
+	maxRequestBodySize = 10 << 20
+)
+
+// Server handles API requests by calling through to a DAL. Because the DAL
+// has a single current notebook, requests are handled one at a time
+type Server struct {
+	mu      sync.Mutex
+	data    dal.DAL
+	tokens  [][sha256.Size]byte
+	version string
+	logger  *zap.Logger
+}
+
+// New creates a Server for the provided DAL. Requests must carry one of the
+// provided tokens as a bearer token
+func New(data dal.DAL, tokens []string, version string, logger *zap.Logger) *Server {
+	s := &Server{
+		data:    data,
+		version: version,
+		logger:  logger,
+	}
+
+	for _, token := range tokens {
+		s.tokens = append(s.tokens, sha256.Sum256([]byte(token)))
+	}
+
+	return s
+}
+
+// LoadTokens reads API tokens from a file containing one token per line.
+// Blank lines and lines starting with '#' are ignored
+func LoadTokens(filename string) ([]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read token file: %w", err)
+	}
+
+	var tokens []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("token file %q contains no tokens", filename)
+	}
+	return tokens, nil
+}
+
+// statusError is an error with the HTTP status it should be reported with
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// errorf creates an error reported with the provided HTTP status
+func errorf(status int, format string, args ...interface{}) error {
+	return &statusError{
+		status: status,
+		err:    fmt.Errorf(format, args...),
+	}
+}
+
+// handlerFunc handles a request for a route. The route's path segments
+// follow the API version
+type handlerFunc func(w http.ResponseWriter, r *http.Request, segments []string) error
+
+// ServeHTTP authenticates and routes the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.With(zap.String("method", r.Method), zap.String("path", r.URL.Path))
+
+	err := s.serve(w, r)
+	if err == nil {
+		logger.Debug("request handled")
+		return
+	}
+
+	status := http.StatusInternalServerError
+	var se *statusError
+	if errors.As(err, &se) {
+		status = se.status
+	}
+
+	if status >= http.StatusInternalServerError {
+		logger.Error("request failed", zap.Error(err))
+	} else {
+		logger.Info("request rejected", zap.Int("status", status), zap.Error(err))
+	}
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="notes"`)
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) error {
+	if !s.authorized(r) {
+		return errorf(http.StatusUnauthorized, "missing or invalid token")
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return errorf(http.StatusBadRequest, "unescape path: %v", err)
+		}
+		segments = append(segments, unescaped)
+	}
+
+	if segments[0] != APIVersion {
+		return errorf(http.StatusNotFound, "unknown API version %q", segments[0])
+	}
+	segments = segments[1:]
+
+	handlers, err := s.route(segments)
+	if err != nil {
+		return err
+	}
+
+	handler, ok := handlers[r.Method]
+	if !ok {
+		var allowed []string
+		for method := range handlers {
+			allowed = append(allowed, method)
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		return errorf(http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	return handler(w, r, segments)
+}
+
+// route gets the handlers, by method, for the provided path segments
+func (s *Server) route(segments []string) (map[string]handlerFunc, error) {
+	switch {
+	case len(segments) == 1 && segments[0] == "version":
+		return map[string]handlerFunc{http.MethodGet: s.getVersion}, nil
+	case len(segments) == 0 || segments[0] != "notebooks":
+	case len(segments) == 1:
+		return map[string]handlerFunc{
+			http.MethodGet:  s.listNotebooks,
+			http.MethodPost: s.createNotebook,
+		}, nil
+	case len(segments) == 2:
+		return map[string]handlerFunc{
+			http.MethodPatch:  s.renameNotebook,
+			http.MethodDelete: s.removeNotebook,
+		}, nil
+	case len(segments) == 3 && segments[2] == "meta":
+		return map[string]handlerFunc{
+			http.MethodGet: s.getMeta,
+			http.MethodPut: s.putMeta,
+		}, nil
+	case len(segments) == 3 && segments[2] == "notes":
+		return map[string]handlerFunc{
+			http.MethodGet:  s.listNotes,
+			http.MethodPost: s.createNote,
+		}, nil
+	case len(segments) == 4 && segments[2] == "notes":
+		return map[string]handlerFunc{
+			http.MethodGet:    s.getNote,
+			http.MethodPut:    s.putNote,
+			http.MethodPatch:  s.editNote,
+			http.MethodDelete: s.removeNote,
+		}, nil
+	case len(segments) == 5 && segments[2] == "notes" && segments[4] == "meta":
+		return map[string]handlerFunc{http.MethodGet: s.getNoteMeta}, nil
+	}
+
+	return nil, errorf(http.StatusNotFound, "unknown path")
+}
+
+// authorized determines whether the request carries a valid bearer token.
+// Tokens are compared by hash so that the comparison takes the same time
+// regardless of token length
+func (s *Server) authorized(r *http.Request) bool {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	var match int
+	for _, t := range s.tokens {
+		match |= subtle.ConstantTimeCompare(hash[:], t[:])
+	}
+	return match == 1
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// readJSON decodes the request body into v, rejecting unknown fields
+func readJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "decode request body: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/subtlepseudonym/notes"
+	"github.com/subtlepseudonym/notes/dal"
+
+	"go.uber.org/zap"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	data, err := dal.NewLocal("notes", "test", nil)
+	if err != nil {
+		t.Fatalf("new local: %s", err)
+	}
+
+	ts := httptest.NewServer(New(data, []string{testToken}, "test", zap.NewNop()))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func request(t *testing.T, ts *httptest.Server, method, target string, body interface{}, headers map[string]string) *http.Response {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&buf).Encode(body)
+		if err != nil {
+			t.Fatalf("encode request body: %s", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, ts.URL+target, &buf)
+	if err != nil {
+		t.Fatalf("new request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %s", method, target, err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func expectStatus(t *testing.T, res *http.Response, status int) {
+	t.Helper()
+	if res.StatusCode != status {
+		t.Fatalf("%s %s returned status %d, expected %d", res.Request.Method, res.Request.URL.Path, res.StatusCode, status)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, header := range []string{"", "Bearer wrong", "Basic " + testToken} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/notebooks", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("get notebooks: %s", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("authorization %q returned status %d, expected %d", header, res.StatusCode, http.StatusUnauthorized)
+		}
+	}
+
+	expectStatus(t, request(t, ts, http.MethodGet, "/v1/notebooks", nil, nil), http.StatusOK)
+}
+
+func TestNoteLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+
+	res := request(t, ts, http.MethodPost, "/v1/notebooks", NotebookRequest{Name: "work"}, nil)
+	expectStatus(t, res, http.StatusCreated)
+
+	res = request(t, ts, http.MethodPost, "/v1/notebooks/work/notes", map[string]interface{}{
+		"title": "todo",
+		"body":  "first\n",
+		"tags":  []string{"list"},
+	}, nil)
+	expectStatus(t, res, http.StatusCreated)
+	if location := res.Header.Get("Location"); location != "/v1/notebooks/work/notes/1" {
+		t.Errorf("location = %q, expected /v1/notebooks/work/notes/1", location)
+	}
+	etag := res.Header.Get("ETag")
+
+	res = request(t, ts, http.MethodGet, "/v1/notebooks/work/notes/1", nil, map[string]string{"If-None-Match": etag})
+	expectStatus(t, res, http.StatusNotModified)
+
+	res = request(t, ts, http.MethodPatch, "/v1/notebooks/work/notes/1", map[string]interface{}{
+		"body":   "second\n",
+		"append": true,
+	}, map[string]string{"If-Match": etag})
+	expectStatus(t, res, http.StatusOK)
+
+	var note notes.Note
+	err := json.NewDecoder(res.Body).Decode(&note)
+	if err != nil {
+		t.Fatalf("decode note: %s", err)
+	}
+	if note.Body != "first\nsecond\n" || note.Meta.Title != "todo" {
+		t.Errorf("edited note has title %q and body %q", note.Meta.Title, note.Body)
+	}
+
+	// the original ETag is stale after editing
+	res = request(t, ts, http.MethodPatch, "/v1/notebooks/work/notes/1", map[string]interface{}{
+		"title": "done",
+	}, map[string]string{"If-Match": etag})
+	expectStatus(t, res, http.StatusPreconditionFailed)
+
+	res = request(t, ts, http.MethodGet, "/v1/notebooks/work/notes?tag=list", nil, nil)
+	expectStatus(t, res, http.StatusOK)
+
+	var metas []notes.NoteMeta
+	err = json.NewDecoder(res.Body).Decode(&metas)
+	if err != nil {
+		t.Fatalf("decode note metas: %s", err)
+	}
+	if len(metas) != 1 {
+		t.Errorf("listed %d notes, expected 1", len(metas))
+	}
+
+	expectStatus(t, request(t, ts, http.MethodDelete, "/v1/notebooks/work/notes/1", nil, nil), http.StatusNoContent)
+	expectStatus(t, request(t, ts, http.MethodGet, "/v1/notebooks/work/notes/2", nil, nil), http.StatusNotFound)
+	expectStatus(t, request(t, ts, http.MethodGet, "/v1/notebooks/missing/notes", nil, nil), http.StatusNotFound)
+	expectStatus(t, request(t, ts, http.MethodPost, "/v1/notebooks/work/notes/1", nil, nil), http.StatusMethodNotAllowed)
+	expectStatus(t, request(t, ts, http.MethodGet, "/v2/notebooks", nil, nil), http.StatusNotFound)
+
+	res = request(t, ts, http.MethodGet, "/v1/notebooks/work/notes", nil, nil)
+	metas = nil
+	json.NewDecoder(res.Body).Decode(&metas)
+	if len(metas) != 0 {
+		t.Errorf("listed %d notes after soft delete, expected 0", len(metas))
+	}
+}
+
+func TestNotebookNameTraversal(t *testing.T) {
+	ts := newTestServer(t)
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("get home directory: %s", err)
+	}
+
+	expectStatus(t, request(t, ts, http.MethodPost, "/v1/notebooks", NotebookRequest{Name: "work"}, nil), http.StatusCreated)
+	for _, name := range []string{"a/../../escaped", "../escaped", "..", `a\b`, "a/b"} {
+		res := request(t, ts, http.MethodPost, "/v1/notebooks", NotebookRequest{Name: name}, nil)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("create notebook %q returned status %d, expected %d", name, res.StatusCode, http.StatusBadRequest)
+		}
+
+		res = request(t, ts, http.MethodPatch, "/v1/notebooks/work", NotebookRequest{Name: name}, nil)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("rename notebook to %q returned status %d, expected %d", name, res.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	if _, err := os.Stat(path.Join(home, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("notebook created outside of the notes directory: %v", err)
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	filename := path.Join(t.TempDir(), "tokens")
+	err := os.WriteFile(filename, []byte("# comment\n\n  abc  \ndef\n"), 0600)
+	if err != nil {
+		t.Fatalf("write token file: %s", err)
+	}
+
+	tokens, err := LoadTokens(filename)
+	if err != nil {
+		t.Fatalf("load tokens: %s", err)
+	}
+	if len(tokens) != 2 || tokens[0] != "abc" || tokens[1] != "def" {
+		t.Errorf("tokens = %q, expected [abc def]", tokens)
+	}
+}